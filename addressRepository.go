@@ -0,0 +1,78 @@
+package golang_gorm
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"golang-gorm/geo"
+
+	"gorm.io/gorm"
+)
+
+type AddressRepository struct {
+	*Repository[Address]
+}
+
+func NewAddressRepository(db *gorm.DB) *AddressRepository {
+	return &AddressRepository{NewRepository[Address](db)}
+}
+
+func (r *AddressRepository) WithTx(tx *gorm.DB) *AddressRepository {
+	return &AddressRepository{r.Repository.WithTx(tx)}
+}
+
+func (r *AddressRepository) FindByUserID(ctx context.Context, userID string) ([]Address, error) {
+	return r.FindAll(ctx, Where("user_id = ?", userID), OrderBy("id"))
+}
+
+// Search returns the addresses whose street or city contains text, with
+// their user loaded.
+func (r *AddressRepository) Search(ctx context.Context, text string) ([]Address, error) {
+	return r.FindAll(ctx, Where("street like ? OR city like ?", "%"+text+"%", "%"+text+"%"), Preload("User"), OrderBy("id"))
+}
+
+// FindPrimary returns the user's primary address, or
+// gorm.ErrRecordNotFound when the user has no addresses.
+func (r *AddressRepository) FindPrimary(ctx context.Context, userID string) (*Address, error) {
+	var address Address
+	err := r.DB(ctx).Where("user_id = ? AND is_primary = ?", userID, true).Take(&address).Error
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// FindByCity returns the addresses in city, ignoring case.
+func (r *AddressRepository) FindByCity(ctx context.Context, city string) ([]Address, error) {
+	return r.FindAll(ctx, Where("LOWER(city) = ?", strings.ToLower(city)), OrderBy("id"))
+}
+
+// FindWithin returns the geocoded addresses within radius meters of
+// center, nearest first. The index on the coordinates narrows the rows
+// down to a box around the circle; the distances are measured here, so
+// the query needs no trigonometry from the database.
+func (r *AddressRepository) FindWithin(ctx context.Context, center geo.Point, radius float64, scopes ...Scope) ([]Address, error) {
+	min, max := geo.Bounds(center, radius)
+	candidates, err := r.FindAll(ctx, append([]Scope{
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", min.Lat, max.Lat, min.Lng, max.Lng),
+		OrderBy("id"),
+	}, scopes...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	within := candidates[:0]
+	distances := map[int64]float64{}
+	for _, address := range candidates {
+		point, _ := address.Location()
+		if distance := geo.Distance(center, point); distance <= radius {
+			within = append(within, address)
+			distances[address.ID] = distance
+		}
+	}
+	sort.SliceStable(within, func(i, j int) bool {
+		return distances[within[i].ID] < distances[within[j].ID]
+	})
+	return within, nil
+}
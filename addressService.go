@@ -0,0 +1,349 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"golang-gorm/geo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// addressColumns are the columns Update may change.
+var addressColumns = []string{"label", "street", "city", "province", "postal_code", "country"}
+
+// locationColumns are the columns geocoding writes.
+var locationColumns = []string{"latitude", "longitude", "geocoded_at", "geocode_error"}
+
+// geocodeBatchSize bounds how many addresses Regeocode reads at a time.
+const geocodeBatchSize = 100
+
+// AddressService manages users' addresses, validating them against their
+// country's format and keeping exactly one primary address per user who
+// has any. Changes to a user's addresses lock the user's row, so two
+// concurrent changes cannot both leave or take away the primary address.
+//
+// Addresses are geocoded when they are added and when their location
+// changes, after the change commits. A failure to geocode never fails the
+// change: the address is stored without coordinates, with the reason, and
+// Regeocode tries again later.
+type AddressService struct {
+	uow      *UnitOfWork
+	geocoder geo.Geocoder
+	now      func() time.Time
+}
+
+// NewAddressService returns a service that geocodes with geocoder; nil
+// leaves addresses without coordinates.
+func NewAddressService(db *gorm.DB, geocoder geo.Geocoder) *AddressService {
+	return &AddressService{uow: NewUnitOfWork(db), geocoder: geocoder, now: time.Now}
+}
+
+// Add validates and stores address. The user's first address becomes
+// primary whatever IsPrimary says; a later one only when IsPrimary is set,
+// taking the place of the previous primary address.
+func (s *AddressService) Add(ctx context.Context, address *Address) error {
+	address.Normalize()
+	if err := address.Validate(); err != nil {
+		return err
+	}
+
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		if err := lockUser(uow.DB(), address.UserId); err != nil {
+			return err
+		}
+		if _, err := uow.Addresses().FindPrimary(ctx, address.UserId); errors.Is(err, gorm.ErrRecordNotFound) {
+			address.IsPrimary = true
+		} else if err != nil {
+			return err
+		} else if address.IsPrimary {
+			if err := clearPrimary(uow.DB(), address.UserId); err != nil {
+				return err
+			}
+		}
+		return uow.Addresses().Create(ctx, address)
+	})
+	if err != nil {
+		return err
+	}
+	s.locateChanged(ctx, address)
+	return nil
+}
+
+// Update changes the address's fields, given by column; only
+// addressColumns can change, and is_primary to true. The result is
+// validated as a whole, so moving an address to another country takes its
+// new postal code along.
+func (s *AddressService) Update(ctx context.Context, id int64, fields map[string]interface{}) (*Address, error) {
+	var address *Address
+	moved := false
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		var err error
+		address, err = uow.Addresses().FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		before := address.geoQuery()
+		makePrimary := false
+		for column, value := range fields {
+			if column == "is_primary" {
+				// an address stops being primary when another one starts
+				if value != true {
+					return fmt.Errorf("%w: make another address primary instead", ErrInvalidAddress)
+				}
+				makePrimary = !address.IsPrimary
+				continue
+			}
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: %s must be text", ErrInvalidAddress, column)
+			}
+			switch column {
+			case "label":
+				address.Label = text
+			case "street":
+				address.Street = text
+			case "city":
+				address.City = text
+			case "province":
+				address.Province = text
+			case "postal_code":
+				address.PostalCode = text
+			case "country":
+				address.Country = text
+			default:
+				return fmt.Errorf("%w: %s cannot be changed", ErrInvalidAddress, column)
+			}
+		}
+		address.Normalize()
+		if err := address.Validate(); err != nil {
+			return err
+		}
+		if makePrimary {
+			if err := makeAddressPrimary(uow.DB(), address); err != nil {
+				return err
+			}
+		}
+
+		columns := addressColumns
+		if moved = address.geoQuery() != before; moved {
+			// the old coordinates are wrong now
+			address.Latitude, address.Longitude, address.GeocodedAt, address.GeocodeError = nil, nil, nil, ""
+			columns = append(append([]string{}, addressColumns...), locationColumns...)
+		}
+		return uow.DB().Model(address).Select(columns).Updates(address).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		s.locateChanged(ctx, address)
+	}
+	return address, nil
+}
+
+// SetPrimary makes the address its user's primary one.
+func (s *AddressService) SetPrimary(ctx context.Context, id int64) (*Address, error) {
+	var address *Address
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		var err error
+		address, err = uow.Addresses().FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return makeAddressPrimary(uow.DB(), address)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// Remove deletes the address. When it was the primary one, the user's
+// oldest remaining address takes its place.
+func (s *AddressService) Remove(ctx context.Context, id int64) error {
+	return s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		addresses := uow.Addresses()
+		address, err := addresses.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := lockUser(uow.DB(), address.UserId); err != nil {
+			return err
+		}
+		// the lock may have waited for another change to the address
+		if err := uow.DB().Where("id = ?", id).Take(address).Error; err != nil {
+			return err
+		}
+		if err := addresses.Delete(ctx, id); err != nil {
+			return err
+		}
+		if !address.IsPrimary {
+			return nil
+		}
+
+		var next Address
+		err = uow.DB().Where("user_id = ?", address.UserId).Order("id").Take(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return uow.DB().Model(&next).Update("is_primary", true).Error
+	})
+}
+
+// locate geocodes the address and stores the outcome on it and its row.
+// The row is only written while it still has the location that was
+// geocoded, so a slow geocoder cannot overwrite the coordinates of a newer
+// change.
+func (s *AddressService) locate(ctx context.Context, address *Address) error {
+	if s.geocoder == nil {
+		return nil
+	}
+	query := address.geoQuery()
+	point, err := s.geocoder.Geocode(ctx, query)
+	if err == nil && !point.Valid() {
+		err = fmt.Errorf("geocoder returned %v", point)
+	}
+
+	now := s.now()
+	fields := map[string]interface{}{"geocoded_at": now}
+	if err != nil {
+		fields["latitude"], fields["longitude"] = nil, nil
+		fields["geocode_error"] = truncate(err.Error(), 255)
+	} else {
+		fields["latitude"], fields["longitude"] = point.Lat, point.Lng
+		fields["geocode_error"] = ""
+	}
+	update := s.uow.DB().WithContext(ctx).Model(&Address{}).
+		Where("id = ?", address.ID).
+		Where("COALESCE(street, '') = ? AND COALESCE(city, '') = ? AND COALESCE(province, '') = ? AND COALESCE(postal_code, '') = ? AND COALESCE(country, '') = ?",
+			query.Street, query.City, query.Province, query.PostalCode, query.Country).
+		UpdateColumns(fields)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		// the address moved or was removed meanwhile
+		return nil
+	}
+
+	address.GeocodedAt = &now
+	if err != nil {
+		address.Latitude, address.Longitude = nil, nil
+		address.GeocodeError = fields["geocode_error"].(string)
+		return nil
+	}
+	address.Latitude, address.Longitude = &point.Lat, &point.Lng
+	address.GeocodeError = ""
+	return nil
+}
+
+// locateChanged geocodes an address whose change has committed. The change
+// stands whatever happens: when the outcome cannot be stored, the error is
+// logged and left in GeocodeError, and as the row has no geocoded_at yet,
+// Regeocode tries again.
+func (s *AddressService) locateChanged(ctx context.Context, address *Address) {
+	if err := s.locate(ctx, address); err != nil {
+		s.uow.DB().Logger.Error(ctx, "geocoding address %d: %v", address.ID, err)
+		address.Latitude, address.Longitude, address.GeocodedAt = nil, nil, nil
+		address.GeocodeError = truncate(err.Error(), 255)
+	}
+}
+
+// Regeocode geocodes the addresses without coordinates that were never
+// tried or were last tried more than retryAfter ago, and returns how many
+// it located and how many failed again.
+func (s *AddressService) Regeocode(ctx context.Context, retryAfter time.Duration) (located, failed int, err error) {
+	if s.geocoder == nil {
+		return 0, 0, nil
+	}
+	cutoff := s.now().Add(-retryAfter)
+
+	var lastID int64
+	for {
+		var addresses []Address
+		err := s.uow.DB().WithContext(ctx).
+			Where("latitude IS NULL AND (geocoded_at IS NULL OR geocoded_at < ?) AND id > ?", cutoff, lastID).
+			Order("id").Limit(geocodeBatchSize).
+			Find(&addresses).Error
+		if err != nil || len(addresses) == 0 {
+			return located, failed, err
+		}
+
+		for i := range addresses {
+			address := &addresses[i]
+			lastID = address.ID
+			if err := s.locate(ctx, address); err != nil {
+				return located, failed, err
+			}
+			if err := ctx.Err(); err != nil {
+				return located, failed, err
+			}
+			if address.Latitude != nil {
+				located++
+			} else {
+				failed++
+			}
+		}
+	}
+}
+
+func truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
+
+// Regeocoder runs Regeocode in the background.
+type Regeocoder struct {
+	Addresses *AddressService
+	// RetryAfter is how long an address that failed to geocode waits
+	// before it is tried again.
+	RetryAfter time.Duration
+	Interval   time.Duration
+	// OnRun, when set, is told the outcome of every run.
+	OnRun func(located, failed int, err error)
+}
+
+// Run geocodes right away and then every Interval until ctx is done, which
+// it returns as its error. It returns ErrInvalidInterval at once when
+// Interval is not positive.
+func (r *Regeocoder) Run(ctx context.Context) error {
+	return runEvery(ctx, r.Interval, func(ctx context.Context) {
+		located, failed, err := r.Addresses.Regeocode(ctx, r.RetryAfter)
+		if r.OnRun != nil && ctx.Err() == nil {
+			r.OnRun(located, failed, err)
+		}
+	})
+}
+
+// lockUser locks the user's row until the transaction ends.
+func lockUser(tx *gorm.DB, userID string) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Take(&User{}, "id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func makeAddressPrimary(tx *gorm.DB, address *Address) error {
+	if err := lockUser(tx, address.UserId); err != nil {
+		return err
+	}
+	if err := clearPrimary(tx, address.UserId); err != nil {
+		return err
+	}
+	address.IsPrimary = true
+	return tx.Model(address).Update("is_primary", true).Error
+}
+
+func clearPrimary(tx *gorm.DB, userID string) error {
+	return tx.Model(&Address{}).Where("user_id = ? AND is_primary = ?", userID, true).Update("is_primary", false).Error
+}
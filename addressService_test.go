@@ -0,0 +1,445 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/geo"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"gorm.io/gorm"
+)
+
+func TestAddressValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := []Address{
+		{Street: "Jl. Braga No. 3", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111", Country: "id"},
+		{Street: "1 Raffles Place", City: "Singapore", PostalCode: "048616", Country: "SG"},
+		{Street: "10 Downing Street", City: "London", PostalCode: "sw1a  2aa", Country: "GB"},
+		{Street: "1-1 Chiyoda", City: "Chiyoda", Province: "Tokyo", PostalCode: "100-0001", Country: "JP"},
+		{Street: "1 Infinite Loop", City: "Cupertino", Province: "CA", PostalCode: "95014-2083", Country: "US"},
+		{Street: "1 Queen's Road", City: "Central", Country: "HK"},
+	}
+	for _, address := range valid {
+		address.Normalize()
+		assert.Nil(t, address.Validate(), address)
+	}
+
+	invalid := []Address{
+		{Street: "Jl. Braga No. 3", City: "Bandung", Province: "Jawa Barat", PostalCode: "4011", Country: "ID"},
+		{Street: "Jl. Braga No. 3", City: "Bandung", PostalCode: "40111", Country: "ID"},
+		{Street: "Jl. Braga No. 3", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111", Country: "XX"},
+		{City: "Bandung", Province: "Jawa Barat", PostalCode: "40111", Country: "ID"},
+		{Street: "1 Raffles Place", PostalCode: "048616", Country: "SG"},
+		{Street: "1-1 Chiyoda", City: "Chiyoda", Province: "Tokyo", PostalCode: "1000001", Country: "JP"},
+		{Street: "1 Queen's Road", City: "Central", PostalCode: "999077", Country: "HK"},
+	}
+	for _, address := range invalid {
+		address.Normalize()
+		assert.True(t, errors.Is(address.Validate(), ErrInvalidAddress), address)
+	}
+}
+
+func bandung(userID string) *Address {
+	return &Address{UserId: userID, Street: "Jl. Braga No. 3", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111", Country: "ID"}
+}
+
+func TestAddressPrimary(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewAddressService(db, nil)
+	addresses := NewAddressRepository(db)
+
+	// the first address is primary whatever it says
+	first := bandung("4")
+	assert.Nil(t, service.Add(ctx, first))
+	assert.True(t, first.IsPrimary)
+
+	second := bandung("4")
+	second.Label = "Office"
+	assert.Nil(t, service.Add(ctx, second))
+	assert.False(t, second.IsPrimary)
+
+	third := bandung("4")
+	third.IsPrimary = true
+	assert.Nil(t, service.Add(ctx, third))
+	primary, err := addresses.FindPrimary(ctx, "4")
+	assert.Nil(t, err)
+	assert.Equal(t, third.ID, primary.ID)
+
+	_, err = service.SetPrimary(ctx, second.ID)
+	assert.Nil(t, err)
+	primary, err = addresses.FindPrimary(ctx, "4")
+	assert.Nil(t, err)
+	assert.Equal(t, second.ID, primary.ID)
+	assertOnePrimary(t, addresses, "4")
+
+	// removing the primary address promotes the oldest remaining one
+	assert.Nil(t, service.Remove(ctx, second.ID))
+	primary, err = addresses.FindPrimary(ctx, "4")
+	assert.Nil(t, err)
+	assert.Equal(t, first.ID, primary.ID)
+
+	assert.Nil(t, service.Remove(ctx, first.ID))
+	assert.Nil(t, service.Remove(ctx, third.ID))
+	_, err = addresses.FindPrimary(ctx, "4")
+	assert.NotNil(t, err)
+
+	err = service.Add(ctx, bandung("404"))
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestAddressUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewAddressService(db, nil)
+
+	address := bandung("4")
+	assert.Nil(t, service.Add(ctx, address))
+
+	// a new country needs a postal code of its own
+	_, err := service.Update(ctx, address.ID, map[string]interface{}{"country": "SG"})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+	moved, err := service.Update(ctx, address.ID, map[string]interface{}{
+		"street": "1 Raffles Place", "city": "Singapore", "province": "", "postal_code": "048616", "country": "sg",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "SG", moved.Country)
+
+	var stored Address
+	assert.Nil(t, db.Take(&stored, "id = ?", address.ID).Error)
+	assert.Equal(t, "Singapore", stored.City)
+	assert.Equal(t, "048616", stored.PostalCode)
+
+	_, err = service.Update(ctx, address.ID, map[string]interface{}{"user_id": "5"})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+	_, err = service.Update(ctx, address.ID, map[string]interface{}{"is_primary": false})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+}
+
+func TestAddressPrimaryConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewAddressService(db, nil)
+
+	var created []*Address
+	for i := 0; i < 5; i++ {
+		address := bandung("5")
+		assert.Nil(t, service.Add(ctx, address))
+		created = append(created, address)
+	}
+
+	var wg sync.WaitGroup
+	for _, address := range created {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			if _, err := service.SetPrimary(ctx, id); err != nil {
+				t.Error(err)
+			}
+		}(address.ID)
+	}
+	wg.Wait()
+
+	assertOnePrimary(t, NewAddressRepository(db), "5")
+}
+
+func TestUsersByPrimaryCity(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	users := NewUserRepository(db)
+
+	inBandung, err := users.FindByPrimaryCity(ctx, "bandung")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, userIDs(inBandung))
+	assert.Len(t, inBandung[0].Addresses, 1)
+	assert.Equal(t, "Bandung", inBandung[0].Addresses[0].City)
+
+	// Padalarang is only user 2's second address
+	inPadalarang, err := users.FindByPrimaryCity(ctx, "Padalarang")
+	assert.Nil(t, err)
+	assert.Empty(t, inPadalarang)
+
+	found, err := NewAddressRepository(db).FindByCity(ctx, "PADALARANG")
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+}
+
+func assertOnePrimary(t *testing.T, addresses *AddressRepository, userID string) {
+	t.Helper()
+	count, err := addresses.Count(context.Background(), Where("user_id = ? AND is_primary = ?", userID, true))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func testGazetteer(t *testing.T) *geo.Gazetteer {
+	t.Helper()
+	gazetteer, err := geo.LoadGazetteer("testdata/gazetteer.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gazetteer
+}
+
+// flakyGeocoder fails until it is told to work.
+type flakyGeocoder struct {
+	mu      sync.Mutex
+	working bool
+	calls   int
+	next    geo.Geocoder
+}
+
+func (g *flakyGeocoder) Geocode(ctx context.Context, q geo.Query) (geo.Point, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	if !g.working {
+		return geo.Point{}, errors.New("geocoder unavailable")
+	}
+	return g.next.Geocode(ctx, q)
+}
+
+func TestAddressGeocoding(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewAddressService(db, testGazetteer(t))
+
+	address := bandung("4")
+	assert.Nil(t, service.Add(ctx, address))
+	point, ok := address.Location()
+	assert.True(t, ok)
+	assert.Equal(t, geo.Point{Lat: -6.9175, Lng: 107.6191}, point)
+
+	var stored Address
+	assert.Nil(t, db.Take(&stored, "id = ?", address.ID).Error)
+	assert.Equal(t, -6.9175, *stored.Latitude)
+	assert.NotNil(t, stored.GeocodedAt)
+
+	// a new label keeps the coordinates, a new city replaces them
+	_, err := service.Update(ctx, address.ID, map[string]interface{}{"label": "Kos"})
+	assert.Nil(t, err)
+	moved, err := service.Update(ctx, address.ID, map[string]interface{}{"city": "Cimahi"})
+	assert.Nil(t, err)
+	assert.Equal(t, -6.8722, *moved.Latitude)
+
+	// a city the gazetteer does not know is stored without coordinates
+	lost, err := service.Update(ctx, address.ID, map[string]interface{}{"city": "Atlantis"})
+	assert.Nil(t, err)
+	assert.Nil(t, lost.Latitude)
+	assert.Nil(t, db.Take(&stored, "id = ?", address.ID).Error)
+	assert.Nil(t, stored.Latitude)
+	assert.Contains(t, stored.GeocodeError, "not found")
+}
+
+func TestRegeocode(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	geocoder := &flakyGeocoder{next: testGazetteer(t)}
+	service := NewAddressService(db, geocoder)
+
+	first, second := bandung("4"), bandung("5")
+	second.City = "Padalarang"
+	assert.Nil(t, service.Add(ctx, first))
+	assert.Nil(t, service.Add(ctx, second))
+	assert.Nil(t, first.Latitude)
+	assert.Equal(t, "geocoder unavailable", first.GeocodeError)
+
+	// failures are not retried before retryAfter passes
+	located, failed, err := service.Regeocode(ctx, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, located+failed)
+
+	geocoder.working = true
+	service.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	located, failed, err = service.Regeocode(ctx, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, located)
+	assert.Equal(t, 0, failed)
+
+	// user 2's fixture address is in Padalarang too
+	nearby, err := NewAddressRepository(db).FindWithin(ctx, geo.Point{Lat: -6.85, Lng: 107.48}, 5000, Where("user_id = ?", "5"))
+	assert.Nil(t, err)
+	assert.Len(t, nearby, 1)
+	assert.Equal(t, second.ID, nearby[0].ID)
+
+	// nothing is left to geocode
+	calls := geocoder.calls
+	located, failed, err = service.Regeocode(ctx, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, located+failed)
+	assert.Equal(t, calls, geocoder.calls)
+}
+
+func TestRegeocoderRun(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	service := NewAddressService(db, testGazetteer(t))
+	assert.Nil(t, db.Model(&Address{}).Where("user_id = ?", "2").
+		UpdateColumns(map[string]interface{}{"latitude": nil, "longitude": nil, "geocoded_at": nil}).Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan int, 1)
+	job := &Regeocoder{
+		Addresses: service,
+		Interval:  time.Hour,
+		OnRun: func(located, failed int, err error) {
+			assert.Nil(t, err)
+			runs <- located
+			cancel()
+		},
+	}
+	assert.True(t, errors.Is(job.Run(ctx), context.Canceled))
+	assert.Equal(t, 2, <-runs)
+
+	var missing int64
+	assert.Nil(t, db.Model(&Address{}).Where("latitude IS NULL").Count(&missing).Error)
+	assert.Equal(t, int64(0), missing)
+
+	job = &Regeocoder{Addresses: service}
+	assert.True(t, errors.Is(job.Run(context.Background()), ErrInvalidInterval))
+}
+
+func TestAddressesWithin(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	addresses := NewAddressRepository(db)
+
+	// Alun-alun Bandung
+	center := geo.Point{Lat: -6.9218, Lng: 107.6070}
+	within, err := addresses.FindWithin(ctx, center, 10000)
+	assert.Nil(t, err)
+	assert.Len(t, within, 1)
+	assert.Equal(t, "Bandung", within[0].City)
+
+	within, err = addresses.FindWithin(ctx, center, 30000)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Bandung", "Padalarang"}, []string{within[0].City, within[1].City})
+
+	primary, err := addresses.FindWithin(ctx, center, 30000, Where("is_primary = ?", true))
+	assert.Nil(t, err)
+	assert.Len(t, primary, 1)
+
+	far, err := addresses.FindWithin(ctx, geo.Point{Lat: 1.29, Lng: 103.85}, 10000)
+	assert.Nil(t, err)
+	assert.Empty(t, far)
+}
+
+// seedLegacyAddresses stores users with free-text addresses the way they were
+// kept before structure_addresses (version 10).
+func seedLegacyAddresses(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	now := time.Now()
+	for _, user := range []map[string]interface{}{
+		{"id": "legacy-1", "first_name": "Asep", "password": "rahasia", "created_at": now, "updated_at": now},
+		{"id": "legacy-2", "first_name": "Euis", "password": "rahasia", "created_at": now, "updated_at": now},
+		{"id": "legacy-3", "first_name": "Budi", "password": "rahasia", "created_at": now, "updated_at": now},
+	} {
+		assert.Nil(t, db.Table("users").Create(user).Error)
+	}
+	for _, address := range []map[string]interface{}{
+		{"user_id": "legacy-1", "address": "Bandung", "created_at": now, "updated_at": now},
+		{"user_id": "legacy-2", "address": "Bandung", "created_at": now, "updated_at": now},
+		{"user_id": "legacy-2", "address": "Jakarta", "created_at": now, "updated_at": now},
+		{"user_id": "legacy-3", "address": "Jakarta", "created_at": now, "updated_at": now},
+	} {
+		assert.Nil(t, db.Table("addresses").Create(address).Error)
+	}
+}
+
+func TestStructureAddressesMigration(t *testing.T) {
+	t.Parallel()
+	db := newLegacyTestDB(t, 9)
+	ctx := context.Background()
+	seedLegacyAddresses(t, db)
+
+	migrator, err := migrate.New(db, migrations.All()...)
+	assert.Nil(t, err)
+	_, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	users, err := NewUserRepository(db).FindByPrimaryCity(ctx, "Bandung")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "legacy-1", users[0].ID)
+	assert.Equal(t, "legacy-2", users[1].ID)
+	assert.Equal(t, "Bandung", users[1].Addresses[0].Street)
+	assert.Equal(t, "ID", users[1].Addresses[0].Country)
+
+	users, err = NewUserRepository(db).FindByPrimaryCity(ctx, "Jakarta")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "legacy-3", users[0].ID)
+}
+
+func TestRegeocodeMigratedAddresses(t *testing.T) {
+	t.Parallel()
+	db := newLegacyTestDB(t, 9)
+	ctx := context.Background()
+	seedLegacyAddresses(t, db)
+
+	migrator, err := migrate.New(db, migrations.All()...)
+	assert.Nil(t, err)
+	_, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	// the migrated rows carry their city and country, which is all the
+	// gazetteer needs
+	located, failed, err := NewAddressService(db, testGazetteer(t)).Regeocode(ctx, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, located)
+	assert.Equal(t, 0, failed)
+
+	nearby, err := NewAddressRepository(db).FindWithin(ctx, geo.Point{Lat: -6.92, Lng: 107.62}, 5000)
+	assert.Nil(t, err)
+	assert.Len(t, nearby, 2)
+	for _, address := range nearby {
+		assert.Equal(t, "Bandung", address.City)
+	}
+}
+
+// cancelingGeocoder finds the address but cancels the request before its
+// outcome can be stored.
+type cancelingGeocoder struct {
+	cancel context.CancelFunc
+	next   geo.Geocoder
+}
+
+func (g *cancelingGeocoder) Geocode(ctx context.Context, q geo.Query) (geo.Point, error) {
+	defer g.cancel()
+	return g.next.Geocode(ctx, q)
+}
+
+func TestAddressGeocodingNotStored(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewAddressService(db, &cancelingGeocoder{cancel: cancel, next: testGazetteer(t)})
+
+	// the address is added all the same
+	address := bandung("4")
+	assert.Nil(t, service.Add(ctx, address))
+	assert.Nil(t, address.Latitude)
+	assert.Contains(t, address.GeocodeError, "canceled")
+
+	var stored Address
+	assert.Nil(t, db.Take(&stored, "id = ?", address.ID).Error)
+	assert.Nil(t, stored.GeocodedAt)
+
+	located, failed, err := NewAddressService(db, testGazetteer(t)).Regeocode(context.Background(), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, located)
+	assert.Equal(t, 0, failed)
+}
@@ -1,16 +1,128 @@
 package golang_gorm
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang-gorm/geo"
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
 
 type Address struct {
-	ID        int64     `gorm:"primary_key;column:id;autoIncrement"`
-	UserId    string    `gorm:"column:user_id"`
-	Address   string    `gorm:"column:address"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreatedTime"`
-	UpdatedAt time.Time `gorm:"column:created_at;autoCreatedTime;autoUpdatedTime"`
-	User      User      `gorm:"foreignKey:user_id;references:id"`
+	ID         int64  `gorm:"primary_key;column:id;autoIncrement"`
+	UserId     string `gorm:"column:user_id"`
+	Label      string `gorm:"column:label;size:50"`
+	Street     string `gorm:"column:street"`
+	City       string `gorm:"column:city;size:100;index:idx_addresses_city"`
+	Province   string `gorm:"column:province;size:100"`
+	PostalCode string `gorm:"column:postal_code;size:20"`
+	// Country is the ISO 3166-1 alpha-2 code, such as ID.
+	Country string `gorm:"column:country;size:2"`
+	// IsPrimary marks the address the user ships to by default;
+	// AddressService keeps exactly one per user who has addresses.
+	IsPrimary bool `gorm:"column:is_primary;not null;default:false"`
+	// Latitude and Longitude are nil until the address is geocoded.
+	Latitude  *float64 `gorm:"column:latitude;index:idx_addresses_location,priority:1"`
+	Longitude *float64 `gorm:"column:longitude;index:idx_addresses_location,priority:2"`
+	// GeocodedAt is when geocoding was last tried and GeocodeError why it
+	// failed, if it did.
+	GeocodedAt   *time.Time `gorm:"column:geocoded_at"`
+	GeocodeError string     `gorm:"column:geocode_error;size:255"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	User         User       `gorm:"foreignKey:user_id;references:id"`
 }
 
 func (w *Address) TableName() string {
 	return "addresses"
 }
+
+// AddressFormat is how addresses are written in a country.
+type AddressFormat struct {
+	// PostalCode matches the country's postal codes, upper-cased; nil
+	// means the country has none.
+	PostalCode *regexp.Regexp
+	// RequireProvince is set where the province, state or prefecture is
+	// part of the address.
+	RequireProvince bool
+}
+
+// AddressFormats holds the countries addresses can be in, by ISO code.
+var AddressFormats = map[string]AddressFormat{
+	"ID": {PostalCode: regexp.MustCompile(`^\d{5}$`), RequireProvince: true},
+	"MY": {PostalCode: regexp.MustCompile(`^\d{5}$`), RequireProvince: true},
+	"SG": {PostalCode: regexp.MustCompile(`^\d{6}$`)},
+	"AU": {PostalCode: regexp.MustCompile(`^\d{4}$`), RequireProvince: true},
+	"JP": {PostalCode: regexp.MustCompile(`^\d{3}-\d{4}$`), RequireProvince: true},
+	"US": {PostalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), RequireProvince: true},
+	"GB": {PostalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`)},
+	"NL": {PostalCode: regexp.MustCompile(`^\d{4} [A-Z]{2}$`)},
+	"HK": {},
+}
+
+// Normalize trims the address's parts, upper-cases the country and postal
+// code and collapses the spaces in the postal code.
+func (a *Address) Normalize() {
+	a.Label = strings.TrimSpace(a.Label)
+	a.Street = strings.TrimSpace(a.Street)
+	a.City = strings.TrimSpace(a.City)
+	a.Province = strings.TrimSpace(a.Province)
+	a.PostalCode = strings.ToUpper(strings.Join(strings.Fields(a.PostalCode), " "))
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+}
+
+// Validate checks the address against the format of its country.
+func (a *Address) Validate() error {
+	format, ok := AddressFormats[a.Country]
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: unsupported country %q", ErrInvalidAddress, a.Country)
+	case a.Street == "":
+		return fmt.Errorf("%w: street is required", ErrInvalidAddress)
+	case a.City == "":
+		return fmt.Errorf("%w: city is required", ErrInvalidAddress)
+	case format.RequireProvince && a.Province == "":
+		return fmt.Errorf("%w: province is required in %s", ErrInvalidAddress, a.Country)
+	case format.PostalCode == nil && a.PostalCode != "":
+		return fmt.Errorf("%w: %s has no postal codes", ErrInvalidAddress, a.Country)
+	case format.PostalCode != nil && !format.PostalCode.MatchString(a.PostalCode):
+		return fmt.Errorf("%w: %q is not a postal code in %s", ErrInvalidAddress, a.PostalCode, a.Country)
+	}
+	for _, part := range []struct {
+		name, value string
+		max         int
+	}{
+		{"label", a.Label, 50},
+		{"street", a.Street, 255},
+		{"city", a.City, 100},
+		{"province", a.Province, 100},
+	} {
+		if utf8.RuneCountInString(part.value) > part.max {
+			return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidAddress, part.name, part.max)
+		}
+	}
+	return nil
+}
+
+// Location returns where the address is, or false before it is geocoded.
+func (a *Address) Location() (geo.Point, bool) {
+	if a.Latitude == nil || a.Longitude == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *a.Latitude, Lng: *a.Longitude}, true
+}
+
+func (a *Address) geoQuery() geo.Query {
+	return geo.Query{
+		Street:     a.Street,
+		City:       a.City,
+		Province:   a.Province,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
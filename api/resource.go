@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	golang_gorm "golang-gorm"
+	"golang-gorm/filter"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+// resource serves one model. build and changes turn a request into a new
+// row and a column map; nil changes make the rows read-only after create.
+type resource[T any] struct {
+	repo    *golang_gorm.Repository[T]
+	filters filter.Fields
+	// keys order lists without a sort parameter
+	keys    []pagination.Key
+	parseID func(id string) (interface{}, bool)
+	view    func(row *T) interface{}
+	build   func(r *http.Request) (*T, error)
+	// insert replaces repo.Create when creating takes more than an INSERT
+	insert  func(ctx context.Context, row *T) error
+	changes func(r *http.Request) (map[string]interface{}, error)
+	// modify and destroy replace repo.UpdateFields and repo.Delete
+	modify  func(ctx context.Context, id interface{}, fields map[string]interface{}) error
+	destroy func(ctx context.Context, id interface{}) error
+
+	// defaults filter lists that do not filter by the same field
+	defaults url.Values
+}
+
+func (res *resource[T]) canUpdate() bool {
+	return res.changes != nil
+}
+
+func (res *resource[T]) id(raw string) (interface{}, error) {
+	if res.parseID == nil {
+		return raw, nil
+	}
+	id, ok := res.parseID(raw)
+	if !ok {
+		// no row can have an id of the wrong type
+		return nil, fmt.Errorf("id %q: %w", raw, gorm.ErrRecordNotFound)
+	}
+	return id, nil
+}
+
+func (res *resource[T]) list(ctx context.Context, query url.Values) (interface{}, error) {
+	query = res.withDefaults(query)
+	params, scopeRefs, err := listParams(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := filter.ParseValues(query)
+	if err != nil {
+		return nil, err
+	}
+	keys := res.keys
+	if len(parsed.Sort) > 0 {
+		// the sort becomes the keyset, which only covers the resource's
+		// own columns
+		keys = make([]pagination.Key, 0, len(parsed.Sort))
+		for _, s := range parsed.Sort {
+			field, ok := res.filters[s.Field]
+			if !ok || field.Table != "" {
+				return nil, fmt.Errorf("%w: cannot sort by %q", filter.ErrInvalid, s.Field)
+			}
+			keys = append(keys, pagination.Key{Column: field.Column, Desc: s.Desc})
+		}
+		parsed.Sort = nil
+	}
+	filterScope, err := res.filters.Compile(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []golang_gorm.Scope{filterScope}
+	for _, ref := range scopeRefs {
+		scope, err := golang_gorm.ParseNamedScope[T](ref)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+
+	page, err := res.repo.Page(ctx, params, keys, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, len(page.Items))
+	for i := range page.Items {
+		items[i] = res.view(&page.Items[i])
+	}
+	return pagination.Page[interface{}]{
+		Items:      items,
+		Total:      page.Total,
+		HasNext:    page.HasNext,
+		HasPrev:    page.HasPrev,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}, nil
+}
+
+// withDefaults adds the default filters on the fields query does not
+// filter by, such as status for "status[ne]=rejected".
+func (res *resource[T]) withDefaults(query url.Values) url.Values {
+	if len(res.defaults) == 0 {
+		return query
+	}
+	merged := make(url.Values, len(query)+len(res.defaults))
+	for key, values := range query {
+		merged[key] = values
+	}
+	for field, values := range res.defaults {
+		filtered := false
+		for key := range query {
+			if key == field || strings.HasPrefix(key, field+"[") {
+				filtered = true
+				break
+			}
+		}
+		if !filtered {
+			merged[field] = values
+		}
+	}
+	return merged
+}
+
+func (res *resource[T]) create(r *http.Request) (interface{}, error) {
+	row, err := res.build(r)
+	if err != nil {
+		return nil, err
+	}
+	insert := res.insert
+	if insert == nil {
+		insert = res.repo.Create
+	}
+	if err := insert(r.Context(), row); err != nil {
+		return nil, err
+	}
+	return res.view(row), nil
+}
+
+func (res *resource[T]) read(ctx context.Context, rawID string) (interface{}, error) {
+	id, err := res.id(rawID)
+	if err != nil {
+		return nil, err
+	}
+	row, err := res.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return res.view(row), nil
+}
+
+func (res *resource[T]) update(r *http.Request, rawID string) (interface{}, error) {
+	id, err := res.id(rawID)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := res.changes(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) > 0 {
+		modify := res.modify
+		if modify == nil {
+			modify = res.repo.UpdateFields
+		}
+		if err := modify(r.Context(), id, fields); err != nil {
+			return nil, err
+		}
+	}
+	return res.read(r.Context(), rawID)
+}
+
+func (res *resource[T]) remove(ctx context.Context, rawID string) error {
+	id, err := res.id(rawID)
+	if err != nil {
+		return err
+	}
+	if res.destroy != nil {
+		return res.destroy(ctx, id)
+	}
+	return res.repo.Delete(ctx, id)
+}
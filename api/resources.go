@@ -0,0 +1,648 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	golang_gorm "golang-gorm"
+	"golang-gorm/geo"
+	"golang-gorm/money"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+const (
+	maxNameLength    = 100
+	maxTextLength    = 255
+	maxMessageLength = 5000
+	// bcrypt only looks at the first 72 bytes of a password
+	maxPasswordBytes = 72
+)
+
+// checks collects the invalid fields of a request.
+type checks map[string]string
+
+func (c checks) check(ok bool, field, message string) {
+	if !ok {
+		if _, seen := c[field]; !seen {
+			c[field] = message
+		}
+	}
+}
+
+func (c checks) required(field, value string) {
+	c.check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+func (c checks) maxLength(field, value string, max int) {
+	c.check(utf8.RuneCountInString(value) <= max, field, "is longer than "+strconv.Itoa(max)+" characters")
+}
+
+func (c checks) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: c}
+}
+
+func parseInt64ID(raw string) (interface{}, bool) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	return id, err == nil && id > 0
+}
+
+type nameJSON struct {
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
+}
+
+type userJSON struct {
+	ID        string    `json:"id"`
+	Name      nameJSON  `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type createUserRequest struct {
+	ID       string   `json:"id"`
+	Password string   `json:"password"`
+	Name     nameJSON `json:"name"`
+}
+
+type updateUserRequest struct {
+	Password *string `json:"password"`
+	Name     *struct {
+		FirstName  *string `json:"first_name"`
+		MiddleName *string `json:"middle_name"`
+		LastName   *string `json:"last_name"`
+	} `json:"name"`
+}
+
+func checkName(c checks, name nameJSON) {
+	c.required("name.first_name", name.FirstName)
+	c.maxLength("name.first_name", name.FirstName, maxNameLength)
+	c.maxLength("name.middle_name", name.MiddleName, maxNameLength)
+	c.maxLength("name.last_name", name.LastName, maxNameLength)
+}
+
+func checkPassword(c checks, password string) {
+	c.required("password", password)
+	c.check(len(password) <= maxPasswordBytes, "password", "is longer than "+strconv.Itoa(maxPasswordBytes)+" bytes")
+}
+
+func usersResource(db *gorm.DB) handler {
+	return &resource[golang_gorm.User]{
+		repo:    golang_gorm.NewUserRepository(db).Repository,
+		filters: golang_gorm.UserFilters,
+		keys:    []pagination.Key{{Column: "id"}},
+		view: func(user *golang_gorm.User) interface{} {
+			return userJSON{
+				ID: user.ID,
+				Name: nameJSON{
+					FirstName:  user.Name.FirstName,
+					MiddleName: user.Name.MiddleName,
+					LastName:   user.Name.LastName,
+				},
+				CreatedAt: user.CreatedAt,
+				UpdatedAt: user.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.User, error) {
+			req, err := decode[createUserRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.maxLength("id", req.ID, maxNameLength)
+			checkPassword(c, req.Password)
+			checkName(c, req.Name)
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			return &golang_gorm.User{
+				ID:       req.ID,
+				Password: req.Password,
+				Name: golang_gorm.Name{
+					FirstName:  req.Name.FirstName,
+					MiddleName: req.Name.MiddleName,
+					LastName:   req.Name.LastName,
+				},
+			}, nil
+		},
+		changes: func(r *http.Request) (map[string]interface{}, error) {
+			req, err := decode[updateUserRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			fields := map[string]interface{}{}
+			if req.Password != nil {
+				checkPassword(c, *req.Password)
+				// hashed on the way in, see hashPasswords
+				fields["password"] = *req.Password
+			}
+			if name := req.Name; name != nil {
+				if name.FirstName != nil {
+					c.required("name.first_name", *name.FirstName)
+					c.maxLength("name.first_name", *name.FirstName, maxNameLength)
+					fields["first_name"] = *name.FirstName
+				}
+				if name.MiddleName != nil {
+					c.maxLength("name.middle_name", *name.MiddleName, maxNameLength)
+					fields["middle_name"] = *name.MiddleName
+				}
+				if name.LastName != nil {
+					c.maxLength("name.last_name", *name.LastName, maxNameLength)
+					fields["last_name"] = *name.LastName
+				}
+			}
+			return fields, c.err()
+		},
+	}
+}
+
+type walletJSON struct {
+	ID        string      `json:"id"`
+	UserId    string      `json:"user_id"`
+	Balance   money.Money `json:"balance"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// createWalletRequest opens a wallet with no money in the default currency
+// when Balance is left out.
+type createWalletRequest struct {
+	ID      string       `json:"id"`
+	UserId  string       `json:"user_id"`
+	Balance *money.Money `json:"balance"`
+}
+
+// walletsResource has no PATCH: balances only change through transfers and
+// the ledger.
+func walletsResource(db *gorm.DB) handler {
+	ledger := golang_gorm.NewLedgerService(db)
+	return &resource[golang_gorm.Wallet]{
+		repo:    golang_gorm.NewWalletRepository(db).Repository,
+		filters: golang_gorm.WalletFilters,
+		keys:    []pagination.Key{{Column: "id"}},
+		view: func(wallet *golang_gorm.Wallet) interface{} {
+			return walletJSON{
+				ID:        wallet.ID,
+				UserId:    wallet.UserId,
+				Balance:   wallet.Balance(),
+				CreatedAt: wallet.CreatedAt,
+				UpdatedAt: wallet.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.Wallet, error) {
+			req, err := decode[createWalletRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.maxLength("id", req.ID, maxNameLength)
+			c.required("user_id", req.UserId)
+			c.check(req.Balance == nil || !req.Balance.IsNegative(), "balance", "cannot be negative")
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			wallet := &golang_gorm.Wallet{ID: req.ID, UserId: req.UserId}
+			if req.Balance != nil {
+				wallet.SetBalance(*req.Balance)
+			}
+			return wallet, nil
+		},
+		insert: ledger.OpenWallet,
+	}
+}
+
+type addressJSON struct {
+	ID         int64     `json:"id"`
+	UserId     string    `json:"user_id"`
+	Label      string    `json:"label"`
+	Street     string    `json:"street"`
+	City       string    `json:"city"`
+	Province   string    `json:"province"`
+	PostalCode string    `json:"postal_code"`
+	Country    string    `json:"country"`
+	Primary    bool      `json:"primary"`
+	Latitude   *float64  `json:"latitude"`
+	Longitude  *float64  `json:"longitude"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type createAddressRequest struct {
+	UserId     string `json:"user_id"`
+	Label      string `json:"label"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	Province   string `json:"province"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Primary    bool   `json:"primary"`
+}
+
+type updateAddressRequest struct {
+	Label      *string `json:"label"`
+	Street     *string `json:"street"`
+	City       *string `json:"city"`
+	Province   *string `json:"province"`
+	PostalCode *string `json:"postal_code"`
+	Country    *string `json:"country"`
+	Primary    *bool   `json:"primary"`
+}
+
+// addressesResource checks addresses against their country's format and
+// keeps one primary address per user through AddressService.
+func addressesResource(db *gorm.DB, geocoder geo.Geocoder) handler {
+	addresses := golang_gorm.NewAddressService(db, geocoder)
+	return &resource[golang_gorm.Address]{
+		repo:    golang_gorm.NewAddressRepository(db).Repository,
+		filters: golang_gorm.AddressFilters,
+		keys:    []pagination.Key{{Column: "id"}},
+		parseID: parseInt64ID,
+		view: func(address *golang_gorm.Address) interface{} {
+			return addressJSON{
+				ID:         address.ID,
+				UserId:     address.UserId,
+				Label:      address.Label,
+				Street:     address.Street,
+				City:       address.City,
+				Province:   address.Province,
+				PostalCode: address.PostalCode,
+				Country:    address.Country,
+				Primary:    address.IsPrimary,
+				Latitude:   address.Latitude,
+				Longitude:  address.Longitude,
+				CreatedAt:  address.CreatedAt,
+				UpdatedAt:  address.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.Address, error) {
+			req, err := decode[createAddressRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.required("user_id", req.UserId)
+			c.required("street", req.Street)
+			c.required("city", req.City)
+			c.required("country", req.Country)
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			return &golang_gorm.Address{
+				UserId:     req.UserId,
+				Label:      req.Label,
+				Street:     req.Street,
+				City:       req.City,
+				Province:   req.Province,
+				PostalCode: req.PostalCode,
+				Country:    req.Country,
+				IsPrimary:  req.Primary,
+			}, nil
+		},
+		insert: addresses.Add,
+		changes: func(r *http.Request) (map[string]interface{}, error) {
+			req, err := decode[updateAddressRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			fields := map[string]interface{}{}
+			for column, value := range map[string]*string{
+				"label":       req.Label,
+				"street":      req.Street,
+				"city":        req.City,
+				"province":    req.Province,
+				"postal_code": req.PostalCode,
+				"country":     req.Country,
+			} {
+				if value != nil {
+					fields[column] = *value
+				}
+			}
+			if req.Primary != nil {
+				c.check(*req.Primary, "primary", "can only be set; make another address primary instead")
+				fields["is_primary"] = *req.Primary
+			}
+			return fields, c.err()
+		},
+		modify: func(ctx context.Context, id interface{}, fields map[string]interface{}) error {
+			_, err := addresses.Update(ctx, id.(int64), fields)
+			return err
+		},
+		destroy: func(ctx context.Context, id interface{}) error {
+			return addresses.Remove(ctx, id.(int64))
+		},
+	}
+}
+
+type productJSON struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// createProductRequest makes a free product in the default currency when
+// Price is left out.
+type createProductRequest struct {
+	ID    string       `json:"id"`
+	Name  string       `json:"name"`
+	Price *money.Money `json:"price"`
+}
+
+type updateProductRequest struct {
+	Name  *string      `json:"name"`
+	Price *money.Money `json:"price"`
+}
+
+func productsResource(db *gorm.DB) handler {
+	return &resource[golang_gorm.Product]{
+		repo:    golang_gorm.NewProductRepository(db).Repository,
+		filters: golang_gorm.ProductFilters,
+		keys:    []pagination.Key{{Column: "name"}},
+		view: func(product *golang_gorm.Product) interface{} {
+			return productJSON{
+				ID:        product.ID,
+				Name:      product.Name,
+				Price:     product.Price(),
+				CreatedAt: product.CreatedAt,
+				UpdatedAt: product.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.Product, error) {
+			req, err := decode[createProductRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.maxLength("id", req.ID, maxNameLength)
+			c.required("name", req.Name)
+			c.maxLength("name", req.Name, maxTextLength)
+			c.check(req.Price == nil || !req.Price.IsNegative(), "price", "cannot be negative")
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			product := &golang_gorm.Product{ID: req.ID, Name: req.Name}
+			if req.Price != nil {
+				product.SetPrice(*req.Price)
+			}
+			return product, nil
+		},
+		changes: func(r *http.Request) (map[string]interface{}, error) {
+			req, err := decode[updateProductRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			fields := map[string]interface{}{}
+			if req.Name != nil {
+				c.required("name", *req.Name)
+				c.maxLength("name", *req.Name, maxTextLength)
+				fields["name"] = *req.Name
+			}
+			if req.Price != nil {
+				c.check(!req.Price.IsNegative(), "price", "cannot be negative")
+				fields["price"] = req.Price.Amount
+				fields["currency"] = req.Price.Currency
+			}
+			return fields, c.err()
+		},
+	}
+}
+
+type todoJSON struct {
+	ID          uint       `json:"id"`
+	UserId      string     `json:"user_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    int        `json:"priority"`
+	DueAt       *time.Time `json:"due_at"`
+	Position    int64      `json:"position"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type createTodoRequest struct {
+	UserId      string     `json:"user_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Priority    int        `json:"priority"`
+	DueAt       *time.Time `json:"due_at"`
+}
+
+// updateTodoRequest leaves out status, whose transitions are checked by
+// TodoRepository.SetStatus.
+type updateTodoRequest struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Priority    *int       `json:"priority"`
+	DueAt       *time.Time `json:"due_at"`
+}
+
+func checkPriority(c checks, priority int) {
+	c.check(golang_gorm.TodoPriority(priority).Valid(), "priority", "must be between 0 and 3")
+}
+
+func todosResource(db *gorm.DB) handler {
+	return &resource[golang_gorm.Todo]{
+		repo:    golang_gorm.NewTodoRepository(db).Repository,
+		filters: golang_gorm.TodoFilters,
+		keys:    []pagination.Key{{Column: "id"}},
+		parseID: parseInt64ID,
+		view: func(todo *golang_gorm.Todo) interface{} {
+			return todoJSON{
+				ID:          todo.ID,
+				UserId:      todo.UserId,
+				Title:       todo.Title,
+				Description: todo.Description,
+				Status:      string(todo.Status),
+				Priority:    int(todo.Priority),
+				DueAt:       todo.DueAt,
+				Position:    todo.Position,
+				CreatedAt:   todo.CreatedAt,
+				UpdatedAt:   todo.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.Todo, error) {
+			req, err := decode[createTodoRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.required("user_id", req.UserId)
+			c.required("title", req.Title)
+			c.maxLength("title", req.Title, maxTextLength)
+			c.maxLength("description", req.Description, maxMessageLength)
+			checkPriority(c, req.Priority)
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			return &golang_gorm.Todo{
+				UserId:      req.UserId,
+				Title:       req.Title,
+				Description: req.Description,
+				Priority:    golang_gorm.TodoPriority(req.Priority),
+				DueAt:       req.DueAt,
+			}, nil
+		},
+		changes: func(r *http.Request) (map[string]interface{}, error) {
+			req, err := decode[updateTodoRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			fields := map[string]interface{}{}
+			if req.Title != nil {
+				c.required("title", *req.Title)
+				c.maxLength("title", *req.Title, maxTextLength)
+				fields["title"] = *req.Title
+			}
+			if req.Description != nil {
+				c.maxLength("description", *req.Description, maxMessageLength)
+				fields["description"] = *req.Description
+			}
+			if req.Priority != nil {
+				checkPriority(c, *req.Priority)
+				fields["priority"] = *req.Priority
+			}
+			if req.DueAt != nil {
+				fields["due_at"] = *req.DueAt
+			}
+			return fields, c.err()
+		},
+	}
+}
+
+type guestBookJSON struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Message   string    `json:"message"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type createGuestBookRequest struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+}
+
+func checkEmail(c checks, email string) {
+	c.required("email", email)
+	address, err := mail.ParseAddress(email)
+	c.check(err == nil && address.Address == email, "email", "is not an email address")
+	c.maxLength("email", email, maxTextLength)
+}
+
+// guestBooksResource lists approved entries unless asked for another
+// status. It has no PATCH: an edited entry would skip the spam checks and
+// moderation its submission went through.
+func guestBooksResource(db *gorm.DB) handler {
+	return &resource[golang_gorm.GuestBook]{
+		repo:     golang_gorm.NewGuestBookRepository(db).Repository,
+		filters:  golang_gorm.GuestBookFilters,
+		keys:     []pagination.Key{{Column: "created_at", Desc: true}},
+		defaults: url.Values{"status": {string(golang_gorm.GuestBookApproved)}},
+		parseID:  parseInt64ID,
+		view: func(entry *golang_gorm.GuestBook) interface{} {
+			return guestBookJSON{
+				ID:        entry.ID,
+				Name:      entry.Name,
+				Email:     entry.Email,
+				Message:   entry.Message,
+				Status:    string(entry.Status),
+				CreatedAt: entry.CreatedAt,
+				UpdatedAt: entry.UpdatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.GuestBook, error) {
+			req, err := decode[createGuestBookRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.required("name", req.Name)
+			c.maxLength("name", req.Name, golang_gorm.MaxGuestBookName)
+			checkEmail(c, req.Email)
+			c.required("message", req.Message)
+			c.maxLength("message", req.Message, golang_gorm.MaxGuestBookMessage)
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			return &golang_gorm.GuestBook{Name: req.Name, Email: req.Email, Message: req.Message}, nil
+		},
+		// entries go through the spam checks into the moderation queue
+		insert: golang_gorm.NewGuestBookService(db).Submit,
+	}
+}
+
+type exchangeRateJSON struct {
+	ID          int64      `json:"id"`
+	Base        string     `json:"base"`
+	Quote       string     `json:"quote"`
+	Rate        money.Rate `json:"rate"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// createExchangeRateRequest takes effect at once when EffectiveAt is left
+// out.
+type createExchangeRateRequest struct {
+	Base        string     `json:"base"`
+	Quote       string     `json:"quote"`
+	Rate        money.Rate `json:"rate"`
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+// exchangeRatesResource has no PATCH: a new rate is posted with the date
+// it takes effect, so conversions keep the rate they were made at.
+func exchangeRatesResource(db *gorm.DB) handler {
+	return &resource[golang_gorm.ExchangeRate]{
+		repo:    golang_gorm.NewExchangeRateRepository(db).Repository,
+		filters: golang_gorm.ExchangeRateFilters,
+		keys:    []pagination.Key{{Column: "id"}},
+		parseID: parseInt64ID,
+		view: func(rate *golang_gorm.ExchangeRate) interface{} {
+			return exchangeRateJSON{
+				ID:          rate.ID,
+				Base:        rate.Base,
+				Quote:       rate.Quote,
+				Rate:        rate.Rate,
+				EffectiveAt: rate.EffectiveAt,
+				CreatedAt:   rate.CreatedAt,
+			}
+		},
+		build: func(r *http.Request) (*golang_gorm.ExchangeRate, error) {
+			req, err := decode[createExchangeRateRequest](r)
+			if err != nil {
+				return nil, err
+			}
+			c := checks{}
+			c.required("base", req.Base)
+			c.required("quote", req.Quote)
+			c.check(!req.Rate.IsZero(), "rate", "is required")
+			if err := c.err(); err != nil {
+				return nil, err
+			}
+			rate := &golang_gorm.ExchangeRate{Base: req.Base, Quote: req.Quote, Rate: req.Rate, EffectiveAt: time.Now()}
+			if req.EffectiveAt != nil {
+				rate.EffectiveAt = *req.EffectiveAt
+			}
+			return rate, nil
+		},
+	}
+}
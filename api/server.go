@@ -0,0 +1,239 @@
+// Package api serves the models over a JSON REST API:
+//
+//	GET    /{resource}       list, see below
+//	POST   /{resource}       create
+//	GET    /{resource}/{id}  read
+//	PATCH  /{resource}/{id}  update the fields present in the body
+//	DELETE /{resource}/{id}  delete
+//
+// for users, wallets, addresses, products, todos and guest_books.
+//
+// Lists are keyset paginated with limit and cursor, and with_total=true adds
+// the total count. A scope parameter applies a named scope, such as
+// scope=balance_between:IDR,0,50000, and may be repeated. Every other parameter
+// is a filter, see package filter; a sort parameter orders the list by
+// fields of the resource's own table.
+//
+// Errors are JSON objects with an "error" message. Missing rows answer 404,
+// duplicate keys and rows still referenced elsewhere 409, malformed
+// requests 400 and bodies failing validation 422, with the offending fields
+// listed under "fields". Guest book entries beyond the rate limit answer 429.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	golang_gorm "golang-gorm"
+	"golang-gorm/filter"
+	"golang-gorm/geo"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+// maxBodyBytes bounds request bodies.
+const maxBodyBytes = 1 << 20
+
+// Server routes requests to the resources. It is an http.Handler.
+type Server struct {
+	mux *http.ServeMux
+	// ErrorLog receives the errors answered with 500; nil uses the log
+	// package's standard logger.
+	ErrorLog *log.Logger
+}
+
+// NewServer serves the models in db. Addresses are geocoded with geocoder
+// when they are created or moved; nil leaves them without coordinates.
+func NewServer(db *gorm.DB, geocoder geo.Geocoder) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.handle("users", usersResource(db))
+	s.handle("wallets", walletsResource(db))
+	s.handle("addresses", addressesResource(db, geocoder))
+	s.handle("products", productsResource(db))
+	s.handle("todos", todosResource(db))
+	s.handle("guest_books", guestBooksResource(db))
+	s.handle("exchange_rates", exchangeRatesResource(db))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handler is the part of a resource the routing needs; resource[T]
+// implements it for every model.
+type handler interface {
+	list(ctx context.Context, query url.Values) (interface{}, error)
+	create(r *http.Request) (interface{}, error)
+	read(ctx context.Context, id string) (interface{}, error)
+	update(r *http.Request, id string) (interface{}, error)
+	remove(ctx context.Context, id string) error
+	canUpdate() bool
+}
+
+func (s *Server) handle(name string, h handler) {
+	s.mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			body, err := h.list(r.Context(), r.URL.Query())
+			s.respond(w, http.StatusOK, body, err)
+		case http.MethodPost:
+			body, err := h.create(r)
+			s.respond(w, http.StatusCreated, body, err)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	})
+
+	s.mux.HandleFunc("/"+name+"/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/"+name+"/")
+		if id == "" || strings.Contains(id, "/") {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet:
+			body, err := h.read(r.Context(), id)
+			s.respond(w, http.StatusOK, body, err)
+		case r.Method == http.MethodPatch && h.canUpdate():
+			body, err := h.update(r, id)
+			s.respond(w, http.StatusOK, body, err)
+		case r.Method == http.MethodDelete:
+			err := h.remove(r.Context(), id)
+			s.respond(w, http.StatusNoContent, nil, err)
+		case h.canUpdate():
+			methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	})
+}
+
+func (s *Server) respond(w http.ResponseWriter, status int, body interface{}, err error) {
+	if err != nil {
+		s.writeErr(w, err)
+		return
+	}
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+// ValidationError is a request body with invalid fields, keyed by their
+// JSON path, such as "name.first_name".
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed"
+}
+
+// errBadRequest marks errors in what the client sent.
+var errBadRequest = errors.New("bad request")
+
+func badRequest(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errBadRequest, fmt.Sprintf(format, args...))
+}
+
+func (s *Server) writeErr(w http.ResponseWriter, err error) {
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": invalid.Error(), "fields": invalid.Fields})
+	case errors.Is(err, golang_gorm.ErrInvalidSubmission),
+		errors.Is(err, golang_gorm.ErrInvalidAddress),
+		errors.Is(err, golang_gorm.ErrInvalidRate):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, golang_gorm.ErrRateLimited):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		writeError(w, http.StatusConflict, "already exists")
+	case errors.Is(err, gorm.ErrForeignKeyViolated),
+		errors.Is(err, golang_gorm.ErrUserNotFound):
+		writeError(w, http.StatusConflict, "conflicts with related records")
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, filter.ErrInvalid),
+		errors.Is(err, pagination.ErrInvalidCursor),
+		errors.Is(err, pagination.ErrUnknownKey),
+		errors.Is(err, golang_gorm.ErrUnknownScope),
+		errors.Is(err, golang_gorm.ErrScopeArgs):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger := s.ErrorLog
+		if logger == nil {
+			logger = log.Default()
+		}
+		logger.Printf("api: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// decode reads a JSON body into a value of R, rejecting unknown fields and
+// trailing data.
+func decode[R any](r *http.Request) (R, error) {
+	var req R
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return req, badRequest("invalid JSON body: %v", err)
+	}
+	if decoder.More() {
+		return req, badRequest("invalid JSON body: more than one value")
+	}
+	return req, nil
+}
+
+// listParams takes the pagination and scope parameters out of query and
+// leaves the filters.
+func listParams(query url.Values) (pagination.Params, []string, error) {
+	var params pagination.Params
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return params, nil, badRequest("limit must be a positive integer")
+		}
+		params.Limit = n
+	}
+	params.Cursor = query.Get("cursor")
+	if withTotal := query.Get("with_total"); withTotal != "" {
+		b, err := strconv.ParseBool(withTotal)
+		if err != nil {
+			return params, nil, badRequest("with_total must be true or false")
+		}
+		params.WithTotal = b
+	}
+	scopes := query["scope"]
+
+	for _, key := range []string{"limit", "cursor", "with_total", "scope"} {
+		query.Del(key)
+	}
+	return params, scopes, nil
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	golang_gorm "golang-gorm"
+	"golang-gorm/fixture"
+	"gorm.io/gorm"
+)
+
+func newTestServer(t *testing.T) (*Server, *gorm.DB) {
+	t.Helper()
+
+	db, err := golang_gorm.Open(golang_gorm.Config{Driver: "sqlite", Database: ":memory:", LogLevel: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := golang_gorm.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	// every fixture file but sample.yaml, whose table only the root
+	// package's tests create
+	var paths []string
+	for _, name := range []string{"users", "wallets", "ledger_entries", "addresses", "products", "user_like_product", "todos"} {
+		paths = append(paths, "../testdata/fixtures/"+name+".yaml")
+	}
+	fixtures, err := fixture.Load(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fixtures.Insert(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewServer(db, nil), db
+}
+
+func do(t *testing.T, server *Server, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+	return body
+}
+
+func itemIDs(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var page struct {
+		Items []struct {
+			ID json.Number `json:"id"`
+		} `json:"items"`
+	}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &page), rec.Body.String())
+	ids := make([]string, len(page.Items))
+	for i, item := range page.Items {
+		ids[i] = item.ID.String()
+	}
+	return ids
+}
+
+func TestUsersCRUD(t *testing.T) {
+	t.Parallel()
+	server, db := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/users", `{"id":"10","password":"secret","name":{"first_name":"Siti","last_name":"Aminah"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	created := decodeBody(t, rec)
+	assert.Equal(t, "10", created["id"])
+	assert.Equal(t, map[string]interface{}{"first_name": "Siti", "middle_name": "", "last_name": "Aminah"}, created["name"])
+	assert.NotContains(t, rec.Body.String(), "password")
+	assert.NotContains(t, rec.Body.String(), "secret")
+
+	rec = do(t, server, http.MethodGet, "/users/10", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Siti", decodeBody(t, rec)["name"].(map[string]interface{})["first_name"])
+
+	rec = do(t, server, http.MethodPatch, "/users/10", `{"password":"changed","name":{"middle_name":"Nur"}}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, map[string]interface{}{"first_name": "Siti", "middle_name": "Nur", "last_name": "Aminah"}, decodeBody(t, rec)["name"])
+
+	// the password went through the model's hashing
+	_, err := golang_gorm.NewAuthService(db).Authenticate(context.Background(), "10", "changed")
+	assert.Nil(t, err)
+
+	rec = do(t, server, http.MethodDelete, "/users/10", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = do(t, server, http.MethodGet, "/users/10", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "not found", decodeBody(t, rec)["error"])
+
+	rec = do(t, server, http.MethodDelete, "/users/10", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = do(t, server, http.MethodPatch, "/users/10", `{"name":{"last_name":"x"}}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestValidation(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/users", `{"password":"","name":{"first_name":" "}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	body := decodeBody(t, rec)
+	assert.Equal(t, "validation failed", body["error"])
+	assert.Equal(t, map[string]interface{}{"password": "is required", "name.first_name": "is required"}, body["fields"])
+
+	rec = do(t, server, http.MethodPost, "/guest_books", `{"name":"Budi","email":"not an email","message":"hi"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, map[string]interface{}{"email": "is not an email address"}, decodeBody(t, rec)["fields"])
+
+	rec = do(t, server, http.MethodPost, "/products", `{"name":"Mango","price":{"amount":-1,"currency":"IDR"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(t, server, http.MethodPatch, "/products/P001", `{"name":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	for _, body := range []string{`{"name":`, `{"name":"Mango","colour":"yellow"}`, `{"name":"Mango"} {}`, `{"price":"cheap"}`, `{"name":"Mango","price":40000}`, `{"name":"Mango","price":{"amount":1,"currency":"XXX"}}`} {
+		rec = do(t, server, http.MethodPost, "/products", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/users", `{"id":"1","password":"x","name":{"first_name":"Budi"}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "already exists", decodeBody(t, rec)["error"])
+
+	rec = do(t, server, http.MethodPost, "/products", `{"id":"P001","name":"Apple","price":{"amount":1,"currency":"IDR"}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// the user still has a wallet and addresses
+	rec = do(t, server, http.MethodDelete, "/users/1", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(t, server, http.MethodPost, "/addresses", `{"user_id":"404","street":"Jl. Pajajaran No. 1","city":"Bogor","province":"Jawa Barat","postal_code":"16143","country":"ID"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestWallets(t *testing.T) {
+	t.Parallel()
+	server, db := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/wallets", `{"user_id":"5","balance":{"amount":250000,"currency":"USD"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	created := decodeBody(t, rec)
+	assert.Equal(t, map[string]interface{}{"amount": float64(250000), "currency": "USD"}, created["balance"])
+
+	rec = do(t, server, http.MethodPost, "/wallets", `{"user_id":"6"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, map[string]interface{}{"amount": float64(0), "currency": "IDR"}, decodeBody(t, rec)["balance"])
+
+	rec = do(t, server, http.MethodGet, "/wallets/"+created["id"].(string), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "5", decodeBody(t, rec)["user_id"])
+
+	reconciliation, err := golang_gorm.NewLedgerService(db).Reconcile(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reconciliation.Clean())
+
+	rec = do(t, server, http.MethodPatch, "/wallets/1", `{"balance":1}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, DELETE", rec.Header().Get("Allow"))
+
+	rec = do(t, server, http.MethodGet, "/wallets?scope=sultan", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"2", "3", "4"}, itemIDs(t, rec))
+
+	// one wallet per currency
+	rec = do(t, server, http.MethodPost, "/wallets", `{"user_id":"5","balance":{"amount":1,"currency":"USD"}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(t, server, http.MethodGet, "/wallets?currency=USD", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	items := decodeBody(t, rec)["items"].([]interface{})
+	assert.Len(t, items, 1)
+	assert.Equal(t, created["id"], items[0].(map[string]interface{})["id"])
+}
+
+func TestProductPrices(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodGet, "/products/P001", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"amount": float64(50000), "currency": "IDR"}, decodeBody(t, rec)["price"])
+
+	rec = do(t, server, http.MethodPatch, "/products/P001", `{"price":{"amount":399,"currency":"USD"}}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, map[string]interface{}{"amount": float64(399), "currency": "USD"}, decodeBody(t, rec)["price"])
+
+	rec = do(t, server, http.MethodGet, "/products?currency=IDR", "")
+	items := decodeBody(t, rec)["items"].([]interface{})
+	assert.Len(t, items, 1)
+	assert.Equal(t, "P002", items[0].(map[string]interface{})["id"])
+}
+
+func TestExchangeRates(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/exchange_rates", `{"base":"USD","quote":"IDR","rate":"15500.25","effective_at":"2026-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	created := decodeBody(t, rec)
+	assert.Equal(t, "15500.25", created["rate"])
+
+	rec = do(t, server, http.MethodPost, "/exchange_rates", `{"base":"USD","quote":"IDR","rate":"15600","effective_at":"2026-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(t, server, http.MethodGet, "/exchange_rates?base=USD&quote=IDR", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"1"}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodPatch, "/exchange_rates/1", `{"rate":"1"}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = do(t, server, http.MethodPost, "/exchange_rates", `{"base":"USD","quote":"USD","rate":"1"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = do(t, server, http.MethodPost, "/exchange_rates", `{"base":"USD","quote":"SGD"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, map[string]interface{}{"rate": "is required"}, decodeBody(t, rec)["fields"])
+	for _, body := range []string{`{"base":"USD","quote":"SGD","rate":1.35}`, `{"base":"USD","quote":"SGD","rate":"-1"}`} {
+		rec = do(t, server, http.MethodPost, "/exchange_rates", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodGet, "/users?first_name[like]=User%25&balance[gt]=1000000&with_total=true", "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"2", "3", "4"}, itemIDs(t, rec))
+	assert.Equal(t, float64(3), decodeBody(t, rec)["total"])
+
+	rec = do(t, server, http.MethodGet, "/users?limit=4&sort=-id", "")
+	assert.Equal(t, []string{"9", "8", "7", "6"}, itemIDs(t, rec))
+	next := decodeBody(t, rec)["next_cursor"].(string)
+	rec = do(t, server, http.MethodGet, "/users?limit=4&sort=-id&cursor="+next, "")
+	assert.Equal(t, []string{"5", "4", "3", "2"}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodGet, "/users?scope=has_address_in:Bandung&scope=liked_product:P001", "")
+	assert.Equal(t, []string{"2"}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodGet, "/todos?title=Learn%20GORM", "")
+	assert.Len(t, itemIDs(t, rec), 1)
+
+	for _, query := range []string{
+		"password=knok",
+		"first_name[regex]=x",
+		"balance[gt]=lots",
+		"sort=-balance",
+		"scope=broke",
+		"limit=0",
+		"cursor=garbage",
+	} {
+		rec = do(t, server, http.MethodGet, "/users?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestTodosAndGuestBooks(t *testing.T) {
+	t.Parallel()
+	server, db := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/todos", `{"user_id":"1","title":"write tests","priority":3,"due_at":"2025-06-01T12:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	created := decodeBody(t, rec)
+	assert.Equal(t, "open", created["status"])
+	assert.Equal(t, float64(3), created["priority"])
+	assert.Equal(t, "2025-06-01T12:00:00Z", created["due_at"])
+	path := fmt.Sprintf("/todos/%v", created["id"])
+
+	rec = do(t, server, http.MethodGet, "/todos?scope=overdue&user_id=1", "")
+	assert.Equal(t, []string{fmt.Sprint(created["id"])}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodPatch, path, `{"priority":7}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(t, server, http.MethodPatch, path, `{"description":"for the api"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "for the api", decodeBody(t, rec)["description"])
+
+	rec = do(t, server, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, server, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = do(t, server, http.MethodPost, "/guest_books", `{"name":"Budi","email":"budi@example.com","message":"Halo"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "pending", decodeBody(t, rec)["status"])
+	entry := decodeBody(t, rec)
+	rec = do(t, server, http.MethodGet, "/guest_books?email=budi@example.com&status=pending", "")
+	assert.Len(t, itemIDs(t, rec), 1)
+
+	// the list shows approved entries unless asked otherwise
+	rec = do(t, server, http.MethodGet, "/guest_books?email=budi@example.com", "")
+	assert.Len(t, itemIDs(t, rec), 0)
+	_, err := golang_gorm.NewGuestBookService(db).Approve(context.Background(), int64(entry["id"].(float64)), "admin")
+	assert.Nil(t, err)
+	rec = do(t, server, http.MethodGet, "/guest_books?email=budi@example.com", "")
+	assert.Len(t, itemIDs(t, rec), 1)
+	rec = do(t, server, http.MethodGet, "/guest_books?email=budi@example.com&status[ne]=approved", "")
+	assert.Len(t, itemIDs(t, rec), 0)
+
+	// entries cannot be edited past moderation
+	rec = do(t, server, http.MethodPatch, fmt.Sprintf("/guest_books/%v", entry["id"]), `{"message":"Halo semua"}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = do(t, server, http.MethodPost, "/guest_books", `{"name":"Budi","email":"budi@example.com","message":"`+strings.Repeat("a", golang_gorm.MaxGuestBookMessage+1)+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, map[string]interface{}{"message": "is longer than 2000 characters"}, decodeBody(t, rec)["fields"])
+
+	for i := 0; i < 2; i++ {
+		rec = do(t, server, http.MethodPost, "/guest_books", `{"name":"Budi","email":"budi@example.com","message":"Halo lagi"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	rec = do(t, server, http.MethodPost, "/guest_books", `{"name":"Budi","email":"budi@example.com","message":"Halo lagi"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestAddresses(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	rec := do(t, server, http.MethodPost, "/addresses", `{"user_id":"4","street":"Jl. Braga No. 3","city":"Bandung","province":"Jawa Barat","postal_code":"4011","country":"id"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(t, server, http.MethodPost, "/addresses", `{"user_id":"4","label":"Home","street":"Jl. Braga No. 3","city":"Bandung","province":"Jawa Barat","postal_code":"40111","country":"id"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	home := decodeBody(t, rec)
+	assert.Equal(t, "ID", home["country"])
+	assert.Equal(t, true, home["primary"])
+
+	rec = do(t, server, http.MethodPost, "/addresses", `{"user_id":"4","label":"Office","street":"1 Raffles Place","city":"Singapore","postal_code":"048616","country":"SG"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	office := decodeBody(t, rec)
+	assert.Equal(t, false, office["primary"])
+	officePath := fmt.Sprintf("/addresses/%v", office["id"])
+
+	rec = do(t, server, http.MethodPatch, officePath, `{"country":"ID"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(t, server, http.MethodPatch, officePath, `{"primary":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, true, decodeBody(t, rec)["primary"])
+
+	rec = do(t, server, http.MethodGet, "/addresses?user_id=4&primary=true", "")
+	assert.Equal(t, []string{fmt.Sprint(office["id"])}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodGet, "/users?scope=primary_address_in:singapore", "")
+	assert.Equal(t, []string{"4"}, itemIDs(t, rec))
+
+	rec = do(t, server, http.MethodDelete, officePath, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, server, http.MethodGet, fmt.Sprintf("/addresses/%v", home["id"]), "")
+	assert.Equal(t, true, decodeBody(t, rec)["primary"])
+}
+
+func TestRouting(t *testing.T) {
+	t.Parallel()
+	server, _ := newTestServer(t)
+
+	for _, path := range []string{"/todos/abc", "/todos/0", "/addresses/-1", "/users/1/wallet", "/users/", "/nothing"} {
+		rec := do(t, server, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+
+	rec := do(t, server, http.MethodPut, "/users", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, POST", rec.Header().Get("Allow"))
+}
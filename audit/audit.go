@@ -0,0 +1,368 @@
+// Package audit is a GORM plugin that records creates, updates and deletes
+// of chosen models in an audit table: who did it, what they did to which
+// row, and the column values before and after.
+//
+// The actor comes from the context of the statement:
+//
+//	db.WithContext(audit.WithActor(ctx, userID)).Save(&wallet)
+//
+// Entries are written in the same transaction as the change they describe,
+// so a rolled back change leaves no entry behind.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context whose statements are recorded as done by
+// actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor WithActor stored in ctx.
+func Actor(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// Changes is what the changes column holds: the values of the changed
+// columns before and after the statement. Before is empty for creates and
+// After for deletes.
+type Changes struct {
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// Entry describes one audited row change.
+type Entry struct {
+	Actor  string
+	Action string
+	Table  string
+	// RecordID is the primary key, comma separated for composite keys.
+	RecordID string
+	Changes  Changes
+}
+
+type Config struct {
+	// Models are the audited models.
+	Models []interface{}
+	// Record stores an entry using tx, which shares the transaction of the
+	// audited statement. The model it writes must not be audited itself.
+	Record func(tx *gorm.DB, entry Entry) error
+	// DefaultActor is recorded when the context carries no actor.
+	DefaultActor string
+	// Redact lists columns whose values are replaced by "[redacted]".
+	Redact []string
+	// Skip, when set, leaves the statements it returns true for unrecorded.
+	Skip func(db *gorm.DB) bool
+}
+
+type Plugin struct {
+	config  Config
+	audited map[reflect.Type]bool
+	// tables maps the audited tables to their models, for statements built
+	// with db.Table rather than db.Model
+	tables map[string]*schema.Schema
+	redact map[string]bool
+}
+
+func New(config Config) *Plugin {
+	plugin := &Plugin{config: config, audited: map[reflect.Type]bool{}, redact: map[string]bool{}}
+	for _, model := range config.Models {
+		plugin.audited[reflect.Indirect(reflect.ValueOf(model)).Type()] = true
+	}
+	for _, column := range config.Redact {
+		plugin.redact[column] = true
+	}
+	return plugin
+}
+
+func (p *Plugin) Name() string {
+	return "audit"
+}
+
+const beforeKey = "audit:before"
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	p.tables = map[string]*schema.Schema{}
+	for _, model := range p.config.Models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		p.tables[stmt.Schema.Table] = stmt.Schema
+	}
+
+	callback := db.Callback()
+
+	err := callback.Create().After("gorm:after_create").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_create", p.afterCreate)
+	if err != nil {
+		return err
+	}
+
+	err = callback.Update().After("gorm:before_update").Before("gorm:update").
+		Register("audit:before_update", p.snapshot)
+	if err != nil {
+		return err
+	}
+	err = callback.Update().After("gorm:after_update").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_update", p.afterUpdate)
+	if err != nil {
+		return err
+	}
+
+	err = callback.Delete().After("gorm:before_delete").Before("gorm:delete").
+		Register("audit:before_delete", p.snapshot)
+	if err != nil {
+		return err
+	}
+	return callback.Delete().After("gorm:after_delete").Before("gorm:commit_or_rollback_transaction").
+		Register("audit:after_delete", p.afterDelete)
+}
+
+// schema returns the audited model the statement changes, or nil. Updates
+// and deletes made through db.Table("wallets") are audited like those made
+// through db.Model(&Wallet{}).
+func (p *Plugin) schema(db *gorm.DB) *schema.Schema {
+	stmt := db.Statement
+	if db.Error != nil || stmt.DryRun || (p.config.Skip != nil && p.config.Skip(db)) {
+		return nil
+	}
+	s := stmt.Schema
+	if s == nil {
+		s = p.tables[stmt.Table]
+	}
+	if s == nil || !p.audited[s.ModelType] || s.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	return s
+}
+
+func (p *Plugin) afterCreate(db *gorm.DB) {
+	if db.Statement.Schema == nil || p.schema(db) == nil || db.Statement.RowsAffected == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	var rows []map[string]interface{}
+	eachStruct(stmt.ReflectValue, func(value reflect.Value) {
+		row := map[string]interface{}{}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			row[field.DBName], _ = field.ValueOf(stmt.Context, value)
+		}
+		rows = append(rows, row)
+	})
+
+	for _, row := range rows {
+		if err := p.write(db, stmt.Schema, ActionCreate, row, nil, row); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// snapshot loads the rows an update or delete is about to touch.
+func (p *Plugin) snapshot(db *gorm.DB) {
+	s := p.schema(db)
+	if s == nil {
+		return
+	}
+
+	stmt := db.Statement
+	var exprs []clause.Expression
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		exprs = append(exprs, where.Exprs...)
+	}
+	if stmt.Schema != nil && stmt.ReflectValue.Kind() == reflect.Struct {
+		primary := stmt.Schema.PrioritizedPrimaryField
+		if value, zero := primary.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: primary.DBName}, Value: value})
+		}
+	}
+	if len(exprs) == 0 && !stmt.AllowGlobalUpdate {
+		// gorm refuses the statement anyway
+		return
+	}
+
+	rows, err := p.load(db, s, exprs)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+func (p *Plugin) load(db *gorm.DB, s *schema.Schema, exprs []clause.Expression) ([]map[string]interface{}, error) {
+	query := db.Session(&gorm.Session{NewDB: true}).Model(reflect.New(s.ModelType).Interface())
+	if len(exprs) > 0 {
+		query = query.Clauses(clause.Where{Exprs: exprs})
+	}
+
+	var rows []map[string]interface{}
+	err := query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName}}).
+		Find(&rows).Error
+	return rows, err
+}
+
+func (p *Plugin) afterUpdate(db *gorm.DB) {
+	s, before, ok := p.before(db)
+	if !ok || len(before) == 0 {
+		return
+	}
+
+	primary := s.PrioritizedPrimaryField.DBName
+	ids := make([]interface{}, len(before))
+	for i, row := range before {
+		ids[i] = row[primary]
+	}
+	after, err := p.load(db, s, []clause.Expression{
+		clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: primary}, Values: ids},
+	})
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	afterByID := map[string]map[string]interface{}{}
+	for _, row := range after {
+		afterByID[fmt.Sprint(row[primary])] = row
+	}
+	for _, old := range before {
+		updated, ok := afterByID[fmt.Sprint(old[primary])]
+		if !ok {
+			continue
+		}
+		changedBefore, changedAfter := diff(s, old, updated)
+		if len(changedAfter) == 0 {
+			continue
+		}
+		if err := p.write(db, s, ActionUpdate, old, changedBefore, changedAfter); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+func (p *Plugin) afterDelete(db *gorm.DB) {
+	s, before, ok := p.before(db)
+	if !ok || db.Statement.RowsAffected == 0 {
+		return
+	}
+	for _, row := range before {
+		if err := p.write(db, s, ActionDelete, row, row, nil); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+func (p *Plugin) before(db *gorm.DB) (*schema.Schema, []map[string]interface{}, bool) {
+	s := p.schema(db)
+	if s == nil {
+		return nil, nil, false
+	}
+	value, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil, nil, false
+	}
+	rows, ok := value.([]map[string]interface{})
+	return s, rows, ok
+}
+
+// diff returns the columns whose value changed, leaving out columns gorm
+// updates by itself.
+func diff(s *schema.Schema, before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	changedBefore, changedAfter := map[string]interface{}{}, map[string]interface{}{}
+	for column, value := range after {
+		if field := s.LookUpField(column); field != nil && field.AutoUpdateTime > 0 {
+			continue
+		}
+		if !reflect.DeepEqual(normalize(before[column]), normalize(value)) {
+			changedBefore[column] = before[column]
+			changedAfter[column] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC()
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	}
+	return value
+}
+
+func (p *Plugin) write(db *gorm.DB, s *schema.Schema, action string, row, before, after map[string]interface{}) error {
+	stmt := db.Statement
+	actor, ok := Actor(stmt.Context)
+	if !ok {
+		actor = p.config.DefaultActor
+	}
+
+	var record []string
+	for _, field := range s.PrimaryFields {
+		record = append(record, fmt.Sprint(normalize(row[field.DBName])))
+	}
+
+	return p.config.Record(db.Session(&gorm.Session{NewDB: true}), Entry{
+		Actor:    actor,
+		Action:   action,
+		Table:    s.Table,
+		RecordID: strings.Join(record, ","),
+		Changes:  Changes{Before: p.redacted(before), After: p.redacted(after)},
+	})
+}
+
+func (p *Plugin) redacted(values map[string]interface{}) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		if p.redact[column] {
+			value = "[redacted]"
+		}
+		out[column] = normalize(value)
+	}
+	return out
+}
+
+func eachStruct(value reflect.Value, fn func(reflect.Value)) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			eachStruct(reflect.Indirect(value.Index(i)), fn)
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
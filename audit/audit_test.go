@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Item struct {
+	ID     int64 `gorm:"primaryKey"`
+	Name   string
+	Secret string
+}
+
+type Note struct {
+	ID   int64 `gorm:"primaryKey"`
+	Text string
+}
+
+func openDB(t *testing.T) (*gorm.DB, *[]Entry) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	entries := &[]Entry{}
+	err = db.Use(New(Config{
+		Models:       []interface{}{&Item{}},
+		DefaultActor: "system",
+		Redact:       []string{"secret"},
+		Record: func(tx *gorm.DB, entry Entry) error {
+			*entries = append(*entries, entry)
+			return nil
+		},
+	}))
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&Item{}, &Note{}))
+	return db, entries
+}
+
+func TestPlugin(t *testing.T) {
+	db, entries := openDB(t)
+	ctx := WithActor(context.Background(), "budi")
+
+	items := []Item{{Name: "a", Secret: "x"}, {Name: "b", Secret: "y"}}
+	assert.Nil(t, db.WithContext(ctx).Create(&items).Error)
+	assert.Nil(t, db.Create(&Note{Text: "not audited"}).Error)
+	assert.Nil(t, db.Model(&items[0]).Updates(map[string]interface{}{"name": "c", "secret": "z"}).Error)
+	assert.Nil(t, db.Where("name = ?", "b").Delete(&Item{}).Error)
+
+	assert.Equal(t, 4, len(*entries))
+	create, update, remove := (*entries)[0], (*entries)[2], (*entries)[3]
+
+	assert.Equal(t, "budi", create.Actor)
+	assert.Equal(t, ActionCreate, create.Action)
+	assert.Equal(t, "items", create.Table)
+	assert.Equal(t, "1", create.RecordID)
+	assert.Equal(t, "[redacted]", create.Changes.After["secret"])
+	assert.Equal(t, "2", (*entries)[1].RecordID)
+
+	assert.Equal(t, "system", update.Actor)
+	assert.Equal(t, Changes{
+		Before: map[string]interface{}{"name": "a", "secret": "[redacted]"},
+		After:  map[string]interface{}{"name": "c", "secret": "[redacted]"},
+	}, update.Changes)
+
+	assert.Equal(t, ActionDelete, remove.Action)
+	assert.Equal(t, "2", remove.RecordID)
+	assert.Equal(t, "b", remove.Changes.Before["name"])
+}
+
+func TestPluginSkipsFailedStatements(t *testing.T) {
+	db, entries := openDB(t)
+
+	assert.Nil(t, db.Create(&Item{ID: 1, Name: "a"}).Error)
+	assert.NotNil(t, db.Create(&Item{ID: 1, Name: "a"}).Error)
+	assert.NotNil(t, db.Model(&Item{}).Update("name", "b").Error)
+	assert.Nil(t, db.Delete(&Item{}, 2).Error)
+
+	assert.Equal(t, 1, len(*entries))
+}
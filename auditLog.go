@@ -0,0 +1,36 @@
+package golang_gorm
+
+import (
+	"golang-gorm/audit"
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// AuditedModels are the models whose changes Open records in user_logs.
+func AuditedModels() []interface{} {
+	return []interface{}{&User{}, &Wallet{}, &Address{}}
+}
+
+// Auditor is the audit plugin Open installs. Statements run without an
+// audit.WithActor context are recorded as done by "system"; password values
+// never reach the log. Migrations are not recorded: they change rows in bulk
+// and may run before user_logs has its current columns.
+func Auditor() *audit.Plugin {
+	return audit.New(audit.Config{
+		Models:       AuditedModels(),
+		DefaultActor: "system",
+		Redact:       []string{"password"},
+		Skip: func(db *gorm.DB) bool {
+			return migrate.Running(db.Statement.Context)
+		},
+		Record: func(tx *gorm.DB, entry audit.Entry) error {
+			return tx.Create(&UserLog{
+				UserId:   entry.Actor,
+				Action:   entry.Action,
+				Table:    entry.Table,
+				RecordId: entry.RecordID,
+				Changes:  entry.Changes,
+			}).Error
+		},
+	})
+}
@@ -0,0 +1,113 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/audit"
+	"gorm.io/gorm"
+)
+
+func auditLogs(t *testing.T, db *gorm.DB, table string) []UserLog {
+	t.Helper()
+	var logs []UserLog
+	err := db.Where("table_name = ?", table).Order("id").Find(&logs).Error
+	assert.Nil(t, err)
+	return logs
+}
+
+func TestAuditCreateUpdateDelete(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := audit.WithActor(context.Background(), "1")
+
+	user := User{ID: "50", Password: "rahasia", Name: Name{FirstName: "User 50"}}
+	assert.Nil(t, db.WithContext(ctx).Create(&user).Error)
+
+	user.Name.LastName = "Lima Puluh"
+	assert.Nil(t, db.WithContext(ctx).Save(&user).Error)
+
+	assert.Nil(t, db.Delete(&User{}, "id = ?", "50").Error)
+
+	logs := auditLogs(t, db, "users")
+	assert.Equal(t, 3, len(logs))
+
+	assert.Equal(t, "1", logs[0].UserId)
+	assert.Equal(t, audit.ActionCreate, logs[0].Action)
+	assert.Equal(t, "50", logs[0].RecordId)
+	assert.Nil(t, logs[0].Changes.Before)
+	assert.Equal(t, "User 50", logs[0].Changes.After["first_name"])
+	assert.Equal(t, "[redacted]", logs[0].Changes.After["password"])
+
+	assert.Equal(t, audit.ActionUpdate, logs[1].Action)
+	assert.Equal(t, map[string]interface{}{"last_name": ""}, logs[1].Changes.Before)
+	assert.Equal(t, map[string]interface{}{"last_name": "Lima Puluh"}, logs[1].Changes.After)
+
+	assert.Equal(t, "system", logs[2].UserId)
+	assert.Equal(t, audit.ActionDelete, logs[2].Action)
+	assert.Equal(t, "Lima Puluh", logs[2].Changes.Before["last_name"])
+	assert.Nil(t, logs[2].Changes.After)
+}
+
+func TestAuditBatchUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	err := db.Model(&Address{}).Where("user_id = ?", "2").Update("city", "Cimahi").Error
+	assert.Nil(t, err)
+
+	logs := auditLogs(t, db, "addresses")
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, "Bandung", logs[0].Changes.Before["city"])
+	assert.Equal(t, "Padalarang", logs[1].Changes.Before["city"])
+	for _, log := range logs {
+		assert.Equal(t, audit.ActionUpdate, log.Action)
+		assert.Equal(t, map[string]interface{}{"city": "Cimahi"}, log.Changes.After)
+	}
+
+	// nothing changed, nothing logged
+	err = db.Model(&Address{}).Where("user_id = ?", "2").Update("city", "Cimahi").Error
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(auditLogs(t, db, "addresses")))
+}
+
+func TestAuditRollbackAndUnaudited(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&Wallet{ID: "50", UserId: "5", BalanceAmount: 0}).Error; err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(auditLogs(t, db, "wallets")))
+
+	assert.Nil(t, db.Create(&Product{ID: "P010", Name: "Mango", PriceAmount: 40000}).Error)
+	assert.Equal(t, 0, len(auditLogs(t, db, "products")))
+}
+
+func TestAuditTransfer(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := audit.WithActor(context.Background(), "2")
+
+	_, err := NewTransferService(db).Transfer(ctx, "2", "1", 500000, "audited")
+	assert.Nil(t, err)
+
+	logs := auditLogs(t, db, "wallets")
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, "1", logs[0].RecordId)
+	assert.Equal(t, map[string]interface{}{"balance": float64(1000000)}, logs[0].Changes.Before)
+	assert.Equal(t, map[string]interface{}{"balance": float64(1500000)}, logs[0].Changes.After)
+	assert.Equal(t, "2", logs[1].RecordId)
+	assert.Equal(t, map[string]interface{}{"balance": float64(5000000)}, logs[1].Changes.Before)
+	assert.Equal(t, map[string]interface{}{"balance": float64(4500000)}, logs[1].Changes.After)
+	for _, log := range logs {
+		assert.Equal(t, "2", log.UserId)
+		assert.Equal(t, audit.ActionUpdate, log.Action)
+	}
+}
@@ -0,0 +1,81 @@
+package golang_gorm
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+
+	"golang-gorm/password"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCredentials does not say whether the user or the password was
+// wrong.
+var ErrInvalidCredentials = errors.New("invalid user id or password")
+
+type AuthService struct {
+	db *gorm.DB
+	// verify checks a password against its hash, see password.Verify.
+	verify func(hash, pw string) error
+
+	unknownOnce sync.Once
+	unknownHash string
+}
+
+func NewAuthService(db *gorm.DB) *AuthService {
+	return &AuthService{db: db, verify: password.Verify}
+}
+
+// Authenticate returns the user when pw is their password. A password still
+// stored in plaintext, or hashed with other settings than PasswordHasher,
+// is rehashed on the way.
+func (s *AuthService) Authenticate(ctx context.Context, userID, pw string) (*User, error) {
+	db := s.db.WithContext(ctx)
+
+	var user User
+	err := db.Take(&user, "id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// take as long as a wrong password does, so the response time
+		// does not tell which user ids exist
+		s.verify(s.unknownUserHash(), pw)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if password.IsHash(user.Password) {
+		err = s.verify(user.Password, pw)
+		if errors.Is(err, password.ErrMismatch) {
+			return nil, ErrInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if user.Password == "" || subtle.ConstantTimeCompare([]byte(user.Password), []byte(pw)) != 1 {
+		// rows written before passwords were hashed
+		return nil, ErrInvalidCredentials
+	}
+
+	if PasswordHasher.NeedsRehash(user.Password) {
+		hash, err := PasswordHasher.Hash(pw)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Model(&user).UpdateColumn("password", hash).Error; err != nil {
+			return nil, err
+		}
+		user.Password = hash
+	}
+	return &user, nil
+}
+
+// unknownUserHash is a PasswordHasher hash for Authenticate to verify
+// against when there is no user to verify against.
+func (s *AuthService) unknownUserHash() string {
+	s.unknownOnce.Do(func() {
+		s.unknownHash, _ = PasswordHasher.Hash("unknown user")
+	})
+	return s.unknownHash
+}
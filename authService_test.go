@@ -0,0 +1,141 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"golang-gorm/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserPasswordHashed(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	user := User{ID: "50", Password: "rahasia", Name: Name{FirstName: "User 50"}}
+	assert.Nil(t, db.Create(&user).Error)
+	assert.True(t, password.IsHash(user.Password))
+	hash := user.Password
+
+	user.Name.LastName = "Lima Puluh"
+	assert.Nil(t, db.Save(&user).Error)
+	assert.Equal(t, hash, user.Password)
+
+	assert.Nil(t, db.Model(&User{}).Where("id = ?", "50").Update("password", "baru").Error)
+	assert.Nil(t, db.Take(&user, "id = ?", "50").Error)
+	assert.Nil(t, password.Verify(user.Password, "baru"))
+
+	assert.Nil(t, db.Where("id = ?", "50").Updates(&User{Password: "lebih baru"}).Error)
+	assert.Nil(t, db.Take(&user, "id = ?", "50").Error)
+	assert.Nil(t, password.Verify(user.Password, "lebih baru"))
+
+	// a User passed by value is hashed too
+	assert.Nil(t, db.Model(&User{}).Where("id = ?", "50").Updates(User{Password: "terbaru"}).Error)
+	assert.Nil(t, db.Take(&user, "id = ?", "50").Error)
+	assert.Nil(t, password.Verify(user.Password, "terbaru"))
+	assert.Nil(t, db.Where("id = ?", "50").Updates(User{Password: "paling baru"}).Error)
+	assert.Nil(t, db.Take(&user, "id = ?", "50").Error)
+	assert.Nil(t, password.Verify(user.Password, "paling baru"))
+
+	users := []User{{ID: "51", Password: "satu"}, {ID: "52", Password: "dua"}}
+	assert.Nil(t, db.Create(&users).Error)
+	assert.Nil(t, db.Where("id in ?", []string{"51", "52"}).Order("id").Find(&users).Error)
+	assert.Nil(t, password.Verify(users[0].Password, "satu"))
+	assert.Nil(t, password.Verify(users[1].Password, "dua"))
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	auth := NewAuthService(db)
+
+	_, err := auth.Authenticate(ctx, "1", "rahasia")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	_, err = auth.Authenticate(ctx, "99", "knok")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+	// the fixture password is still plaintext
+	user, err := auth.Authenticate(ctx, "1", "knok")
+	assert.Nil(t, err)
+	assert.True(t, password.IsHash(user.Password))
+
+	var stored User
+	assert.Nil(t, db.Take(&stored, "id = ?", "1").Error)
+	assert.Equal(t, user.Password, stored.Password)
+
+	_, err = auth.Authenticate(ctx, "1", "knok")
+	assert.Nil(t, err)
+	_, err = auth.Authenticate(ctx, "1", "Knok")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+}
+
+func TestAuthenticateUnknownUser(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	auth := NewAuthService(db)
+	_, err := auth.Authenticate(ctx, "2", "rahasia")
+	assert.Nil(t, err)
+
+	var verified []string
+	auth.verify = func(hash, pw string) error {
+		verified = append(verified, hash)
+		return password.Verify(hash, pw)
+	}
+
+	_, err = auth.Authenticate(ctx, "2", "salah")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	assert.Len(t, verified, 1)
+
+	// an unknown user costs a password check like a wrong password does,
+	// so the response time does not tell which user ids exist
+	_, err = auth.Authenticate(ctx, "99", "salah")
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	assert.Len(t, verified, 2)
+	assert.True(t, password.IsHash(verified[1]))
+	assert.False(t, PasswordHasher.NeedsRehash(verified[1]))
+}
+
+func TestAuthenticateRehash(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	weak, err := password.Bcrypt{Cost: bcrypt.MinCost}.Hash("rahasia")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Model(&User{ID: "2"}).UpdateColumn("password", weak).Error)
+
+	user, err := NewAuthService(db).Authenticate(ctx, "2", "rahasia")
+	assert.Nil(t, err)
+	assert.NotEqual(t, weak, user.Password)
+	assert.False(t, PasswordHasher.NeedsRehash(user.Password))
+	assert.Nil(t, password.Verify(user.Password, "rahasia"))
+}
+
+func TestHashPasswordsMigration(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// rerun hash_passwords (version 5) over the plaintext fixture passwords
+	all := migrations.All()
+	migrator, err := migrate.New(db, all...)
+	assert.Nil(t, err)
+	_, err = migrator.Down(ctx, len(all)-4)
+	assert.Nil(t, err)
+	_, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	var users []User
+	assert.Nil(t, db.Order("id").Find(&users).Error)
+	assert.Equal(t, 9, len(users))
+	assert.Nil(t, password.Verify(users[0].Password, "knok"))
+	for _, user := range users[1:] {
+		assert.Nil(t, password.Verify(user.Password, "rahasia"), user.ID)
+	}
+}
@@ -2,15 +2,39 @@ package golang_gorm
 
 import "time"
 
+type GuestBookStatus string
+
+const (
+	GuestBookPending  GuestBookStatus = "pending"
+	GuestBookApproved GuestBookStatus = "approved"
+	GuestBookRejected GuestBookStatus = "rejected"
+)
+
 type GuestBook struct {
-	ID        int64     `gorm:"primary_key;column:id;autoIncrement"`
-	Name      string    `gorm:"column:name"`
-	Email     string    `gorm:"column:email"`
-	Message   string    `gorm:"column:message"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreatedTime"`
-	UpdatedAt time.Time `gorm:"column:created_at;autoCreatedTime;autoUpdatedTime"`
+	ID      int64           `gorm:"primary_key;column:id;autoIncrement"`
+	Name    string          `gorm:"column:name"`
+	Email   string          `gorm:"column:email"`
+	Message string          `gorm:"column:message"`
+	Status  GuestBookStatus `gorm:"column:status;size:20;not null;default:pending;index:idx_guest_books_status,priority:1"`
+	// SpamReason says why a spam check rejected the entry.
+	SpamReason  string     `gorm:"column:spam_reason;size:255"`
+	ModeratedBy string     `gorm:"column:moderated_by;size:191"`
+	ModeratedAt *time.Time `gorm:"column:moderated_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;index:idx_guest_books_status,priority:2"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
 }
 
 func (g *GuestBook) TableName() string {
 	return "guest_books"
 }
+
+// GuestBookSender is an email address that has signed the guest book. Its
+// row is what submissions from the address lock, see RateLimitCheck.
+type GuestBookSender struct {
+	Email     string    `gorm:"primaryKey;column:email;size:191"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (s *GuestBookSender) TableName() string {
+	return "guest_book_senders"
+}
@@ -0,0 +1,137 @@
+// Command dbctl manages the application database.
+//
+//	dbctl [-config file] migrate up
+//	dbctl [-config file] migrate down [N]
+//	dbctl [-config file] migrate status
+//	dbctl [-config file] migrate dry-run
+//	dbctl [-config file] drift
+//
+// The connection comes from the config file and the DB_* environment
+// variables, see golang_gorm.LoadConfig.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	golang_gorm "golang-gorm"
+	"golang-gorm/drift"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"gorm.io/gorm"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "dbctl:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: dbctl [-config file] migrate up|down [N]|status|dry-run | drift")
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("dbctl", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("DB_CONFIG"), "YAML or JSON config file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	switch {
+	case len(args) == 1 && args[0] == "drift":
+	case len(args) >= 2 && args[0] == "migrate":
+	default:
+		return errUsage
+	}
+
+	cfg, err := golang_gorm.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg.LogLevel = "silent"
+
+	db, err := golang_gorm.Open(cfg)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "drift" {
+		return runDrift(db, out)
+	}
+
+	migrator, err := migrate.New(db, migrations.All()...)
+	if err != nil {
+		return err
+	}
+
+	return runMigrate(context.Background(), migrator, args[1:], out)
+}
+
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string, out io.Writer) error {
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations(out, "applied", done)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("down: %w", err)
+			}
+			steps = parsed
+		}
+		done, err := migrator.Down(ctx, steps)
+		printMigrations(out, "reverted", done)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Missing {
+				state += " (missing)"
+			}
+			fmt.Fprintf(out, "%04d %-30s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	case "dry-run":
+		return migrator.DryRun(ctx, out)
+	default:
+		return errUsage
+	}
+}
+
+// runDrift prints every difference between the models and the database and
+// fails when there is any.
+func runDrift(db *gorm.DB, out io.Writer) error {
+	report, err := drift.Compare(db, golang_gorm.Models()...)
+	if err != nil {
+		return err
+	}
+	if _, err := report.WriteTo(out); err != nil {
+		return err
+	}
+	if !report.Clean() {
+		return fmt.Errorf("%d drift issues", len(report.Issues))
+	}
+	return nil
+}
+
+func printMigrations(out io.Writer, verb string, done []migrate.Migration) {
+	for _, migration := range done {
+		fmt.Fprintf(out, "%s %04d %s\n", verb, migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,124 @@
+// Command server serves the REST API of package api.
+//
+//	server [-config file] [-addr :8080] [-gazetteer file]
+//	       [-trash-retention 720h] [-purge-interval 1h]
+//
+// The connection comes from the config file and the DB_* environment
+// variables, see golang_gorm.LoadConfig. The schema must be up to date; run
+// dbctl migrate up first.
+//
+// With a gazetteer file, see geo.ReadGazetteer, addresses are geocoded as
+// they are saved, and those that failed are retried every 10 minutes.
+//
+// Todos that have been in the trash for longer than the trash retention are
+// deleted for good every purge interval.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	golang_gorm "golang-gorm"
+	"golang-gorm/api"
+	"golang-gorm/geo"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "server:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("DB_CONFIG"), "YAML or JSON config file")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	gazetteerPath := flags.String("gazetteer", "", "CSV gazetteer to geocode addresses with")
+	trashRetention := flags.Duration("trash-retention", 30*24*time.Hour, "how long deleted todos stay in the trash")
+	purgeInterval := flags.Duration("purge-interval", time.Hour, "how often to purge the trash")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *trashRetention < 0 {
+		return fmt.Errorf("trash retention %v is negative", *trashRetention)
+	}
+	if *purgeInterval <= 0 {
+		return fmt.Errorf("purge interval %v: %w", *purgeInterval, golang_gorm.ErrInvalidInterval)
+	}
+
+	cfg, err := golang_gorm.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	db, err := golang_gorm.Open(cfg)
+	if err != nil {
+		return err
+	}
+
+	var geocoder geo.Geocoder
+	if *gazetteerPath != "" {
+		gazetteer, err := geo.LoadGazetteer(*gazetteerPath)
+		if err != nil {
+			return err
+		}
+		geocoder = gazetteer
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           api.NewServer(db, geocoder),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if geocoder != nil {
+		regeocoder := &golang_gorm.Regeocoder{
+			Addresses:  golang_gorm.NewAddressService(db, geocoder),
+			RetryAfter: time.Hour,
+			Interval:   10 * time.Minute,
+			OnRun: func(located, failed int, err error) {
+				if err != nil {
+					log.Printf("geocoding: %v", err)
+				} else if located+failed > 0 {
+					log.Printf("geocoding: located %d addresses, %d failed", located, failed)
+				}
+			},
+		}
+		go regeocoder.Run(ctx)
+	}
+	purger := &golang_gorm.TrashPurger{
+		Trash:     golang_gorm.NewTrashService(db),
+		Retention: *trashRetention,
+		Interval:  *purgeInterval,
+		OnPurge: func(purged int64, err error) {
+			if err != nil {
+				log.Printf("purging trash: %v", err)
+			} else if purged > 0 {
+				log.Printf("purging trash: deleted %d todos", purged)
+			}
+		},
+	}
+	go purger.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("listening on %s", *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package golang_gorm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that reads from "30m" style strings in
+// YAML, JSON and environment variables.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Config struct {
+	// Driver is one of the registered dialects: mysql, postgres or sqlite.
+	Driver   string            `json:"driver" yaml:"driver"`
+	DSN      string            `json:"dsn" yaml:"dsn"`
+	Host     string            `json:"host" yaml:"host"`
+	Port     int               `json:"port" yaml:"port"`
+	User     string            `json:"user" yaml:"user"`
+	Password string            `json:"password" yaml:"password"`
+	Database string            `json:"database" yaml:"database"`
+	Params   map[string]string `json:"params" yaml:"params"`
+
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time" yaml:"conn_max_idle_time"`
+
+	Timeout      Duration `json:"timeout" yaml:"timeout"`
+	ReadTimeout  Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout" yaml:"write_timeout"`
+
+	LogLevel    string `json:"log_level" yaml:"log_level"`
+	PrepareStmt bool   `json:"prepare_stmt" yaml:"prepare_stmt"`
+}
+
+// DefaultConfig mirrors the settings the test suite has always used.
+func DefaultConfig() Config {
+	return Config{
+		Driver:          "mysql",
+		Host:            "localhost",
+		User:            "root",
+		Database:        "golang_gorm2",
+		Params:          map[string]string{"charset": "utf8mb4"},
+		MaxOpenConns:    100,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: Duration(30 * time.Minute),
+		ConnMaxIdleTime: Duration(5 * time.Minute),
+		LogLevel:        "info",
+		PrepareStmt:     true,
+	}
+}
+
+// LoadConfig starts from DefaultConfig, applies the file at path (if any)
+// and then the DB_* environment variables.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.LoadEnv(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// LoadFile overlays the YAML or JSON file at path onto c. The format is
+// picked from the file extension.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config %s: unsupported file type", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// LoadEnv overlays every DB_* environment variable that is set onto c.
+func (c *Config) LoadEnv() error {
+	strs := map[string]*string{
+		"DB_DRIVER":    &c.Driver,
+		"DB_DSN":       &c.DSN,
+		"DB_HOST":      &c.Host,
+		"DB_USER":      &c.User,
+		"DB_PASSWORD":  &c.Password,
+		"DB_NAME":      &c.Database,
+		"DB_LOG_LEVEL": &c.LogLevel,
+	}
+	for key, field := range strs {
+		if value, ok := os.LookupEnv(key); ok {
+			*field = value
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_PORT":           &c.Port,
+		"DB_MAX_OPEN_CONNS": &c.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.MaxIdleConns,
+	}
+	for key, field := range ints {
+		if value, ok := os.LookupEnv(key); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*field = parsed
+		}
+	}
+
+	durations := map[string]*Duration{
+		"DB_CONN_MAX_LIFETIME":  &c.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": &c.ConnMaxIdleTime,
+		"DB_TIMEOUT":            &c.Timeout,
+		"DB_READ_TIMEOUT":       &c.ReadTimeout,
+		"DB_WRITE_TIMEOUT":      &c.WriteTimeout,
+	}
+	for key, field := range durations {
+		if value, ok := os.LookupEnv(key); ok {
+			if err := field.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+
+	if value, ok := os.LookupEnv("DB_PREPARE_STMT"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("DB_PREPARE_STMT: %w", err)
+		}
+		c.PrepareStmt = parsed
+	}
+
+	if value, ok := os.LookupEnv("DB_PARAMS"); ok {
+		params, err := parseParams(value)
+		if err != nil {
+			return fmt.Errorf("DB_PARAMS: %w", err)
+		}
+		c.Params = params
+	}
+
+	return nil
+}
+
+// parseParams reads "key=value&key=value" connection parameters.
+func parseParams(value string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, pair := range strings.Split(value, "&") {
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q", pair)
+		}
+		params[key] = val
+	}
+	return params, nil
+}
@@ -0,0 +1,108 @@
+package golang_gorm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultConfigDSN(t *testing.T) {
+	cfg := DefaultConfig()
+	dsn := cfg.MySQLDSN()
+	assert.Contains(t, dsn, "root@tcp(localhost:3306)/golang_gorm2")
+	assert.Contains(t, dsn, "parseTime=true")
+	assert.Contains(t, dsn, "charset=utf8mb4")
+}
+
+// unsetDBEnv unsets every DB_* variable for the rest of the test, so what
+// LoadConfig reads does not depend on the environment the tests run in.
+func unsetDBEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, "DB_") {
+			// t.Setenv restores the variable once the test is done
+			t.Setenv(key, "")
+			assert.Nil(t, os.Unsetenv(key))
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	unsetDBEnv(t)
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "db.yaml")
+	err := os.WriteFile(yamlPath, []byte("host: db.internal\nport: 3307\nmax_open_conns: 20\nconn_max_lifetime: 1h\nlog_level: warn\n"), 0o600)
+	assert.Nil(t, err)
+
+	cfg, err := LoadConfig(yamlPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "db.internal", cfg.Host)
+	assert.Equal(t, 3307, cfg.Port)
+	assert.Equal(t, 20, cfg.MaxOpenConns)
+	assert.Equal(t, Duration(time.Hour), cfg.ConnMaxLifetime)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, "root", cfg.User)
+
+	jsonPath := filepath.Join(dir, "db.json")
+	err = os.WriteFile(jsonPath, []byte(`{"database":"shop","timeout":"5s","prepare_stmt":false}`), 0o600)
+	assert.Nil(t, err)
+
+	cfg, err = LoadConfig(jsonPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "shop", cfg.Database)
+	assert.Equal(t, Duration(5*time.Second), cfg.Timeout)
+	assert.False(t, cfg.PrepareStmt)
+	assert.Contains(t, cfg.MySQLDSN(), "timeout=5s")
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	unsetDBEnv(t)
+	t.Setenv("DB_HOST", "10.0.0.5")
+	t.Setenv("DB_MAX_IDLE_CONNS", "3")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "90s")
+	t.Setenv("DB_PREPARE_STMT", "false")
+	t.Setenv("DB_PARAMS", "charset=utf8&collation=utf8_general_ci")
+
+	cfg, err := LoadConfig("")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.5", cfg.Host)
+	assert.Equal(t, 3, cfg.MaxIdleConns)
+	assert.Equal(t, Duration(90*time.Second), cfg.ConnMaxIdleTime)
+	assert.False(t, cfg.PrepareStmt)
+	assert.Equal(t, "utf8_general_ci", cfg.Params["collation"])
+
+	t.Setenv("DB_PORT", "abc")
+	_, err = LoadConfig("")
+	assert.NotNil(t, err)
+}
+
+func TestDialectDSN(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Driver = "postgres"
+	cfg.Password = "it's secret"
+	cfg.Params = map[string]string{"charset": "utf8mb4", "sslmode": "disable"}
+	assert.Equal(t, `host=localhost port=5432 user=root dbname=golang_gorm2 password='it\'s secret' sslmode=disable`, cfg.PostgresDSN())
+
+	cfg.Driver = "sqlite"
+	cfg.Database = ":memory:"
+	cfg.Params = nil
+	assert.True(t, cfg.IsMemory())
+	assert.Equal(t, "file::memory:?_busy_timeout=5000&_foreign_keys=1&_txlock=immediate", cfg.SQLiteDSN())
+
+	cfg.Database = "/tmp/app.db"
+	assert.False(t, cfg.IsMemory())
+	assert.Equal(t, "file:/tmp/app.db?_busy_timeout=5000&_foreign_keys=1&_txlock=immediate", cfg.SQLiteDSN())
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Driver = "oracle"
+	_, err := Open(cfg)
+	assert.NotNil(t, err)
+}
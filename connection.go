@@ -0,0 +1,268 @@
+package golang_gorm
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DialectFunc builds the GORM dialector for a Config.
+type DialectFunc func(cfg Config) gorm.Dialector
+
+var dialects = map[string]DialectFunc{
+	"mysql": func(cfg Config) gorm.Dialector {
+		return mysql.Open(cfg.MySQLDSN())
+	},
+	"postgres": func(cfg Config) gorm.Dialector {
+		return postgres.Open(cfg.PostgresDSN())
+	},
+	"sqlite": func(cfg Config) gorm.Dialector {
+		return sqlite.Open(cfg.SQLiteDSN())
+	},
+}
+
+// RegisterDialect makes a driver available to Open under name. Registering
+// an existing name replaces it.
+func RegisterDialect(name string, fn DialectFunc) {
+	dialects[strings.ToLower(name)] = fn
+}
+
+// Open connects to the database described by cfg, applies its pool
+// settings, installs the Auditor and password hashing and sets up the
+// custom join tables.
+func Open(cfg Config) (*gorm.DB, error) {
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, ok := dialects[cfg.driver()]
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q (registered: %s)", cfg.Driver, strings.Join(registeredDialects(), ", "))
+	}
+
+	db, err := gorm.Open(dialect(cfg), &gorm.Config{
+		Logger:      logger.Default.LogMode(level),
+		PrepareStmt: cfg.PrepareStmt,
+		// report duplicate keys and foreign key violations as
+		// gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated on every driver
+		TranslateError: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	if err := setup(db); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	if cfg.IsMemory() {
+		// every new connection to :memory: is a new, empty database, so the
+		// pool must hold on to exactly one connection forever
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+		return db, nil
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
+	return db, nil
+}
+
+// setup installs the callbacks and join tables every connection needs.
+func setup(db *gorm.DB) error {
+	if err := db.Use(Auditor()); err != nil {
+		return err
+	}
+	if err := registerPasswordHashing(db); err != nil {
+		return err
+	}
+	return setupJoinTables(db)
+}
+
+func registeredDialects() []string {
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c Config) driver() string {
+	switch driver := strings.ToLower(c.Driver); driver {
+	case "":
+		return "mysql"
+	case "postgresql", "pgx":
+		return "postgres"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return driver
+	}
+}
+
+// IsMemory reports whether cfg points at an in-memory SQLite database.
+func (c Config) IsMemory() bool {
+	return c.driver() == "sqlite" && c.DSN == "" && (c.Database == "" || c.Database == ":memory:")
+}
+
+func (c Config) port(fallback int) int {
+	if c.Port == 0 {
+		return fallback
+	}
+	return c.Port
+}
+
+// MySQLDSN returns cfg.DSN when set, otherwise it builds one from the
+// individual connection fields.
+func (c Config) MySQLDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	dsn := mysqlDriver.NewConfig()
+	dsn.User = c.User
+	dsn.Passwd = c.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.port(3306)))
+	dsn.DBName = c.Database
+	dsn.ParseTime = true
+	dsn.Loc = time.Local
+	dsn.Timeout = time.Duration(c.Timeout)
+	dsn.ReadTimeout = time.Duration(c.ReadTimeout)
+	dsn.WriteTimeout = time.Duration(c.WriteTimeout)
+	if len(c.Params) > 0 {
+		dsn.Params = make(map[string]string, len(c.Params))
+		for key, value := range c.Params {
+			dsn.Params[key] = value
+		}
+	}
+
+	return dsn.FormatDSN()
+}
+
+// PostgresDSN returns cfg.DSN when set, otherwise a key/value DSN built from
+// the individual connection fields. Params are passed through as-is, so
+// sslmode and friends go there.
+func (c Config) PostgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	parts := []string{
+		"host=" + quotePostgres(c.Host),
+		"port=" + strconv.Itoa(c.port(5432)),
+		"user=" + quotePostgres(c.User),
+		"dbname=" + quotePostgres(c.Database),
+	}
+	if c.Password != "" {
+		parts = append(parts, "password="+quotePostgres(c.Password))
+	}
+	if c.Timeout > 0 {
+		seconds := int(time.Duration(c.Timeout) / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		parts = append(parts, "connect_timeout="+strconv.Itoa(seconds))
+	}
+	for _, key := range sortedKeys(c.Params) {
+		if key == "charset" {
+			// a MySQL-only parameter carried over from DefaultConfig
+			continue
+		}
+		parts = append(parts, key+"="+quotePostgres(c.Params[key]))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// SQLiteDSN returns cfg.DSN when set, otherwise Database (a file path, or
+// empty/":memory:" for an in-memory database) with foreign keys and a busy
+// timeout switched on so SQLite enforces the same constraints as MySQL.
+// Transactions begin IMMEDIATE: SQLite ignores SELECT ... FOR UPDATE, and
+// taking the write lock up front is what keeps two read-then-write
+// transactions from failing with "database is locked".
+func (c Config) SQLiteDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	database := c.Database
+	if c.IsMemory() {
+		database = ":memory:"
+	}
+
+	params := map[string]string{
+		"_foreign_keys": "1",
+		"_busy_timeout": "5000",
+		"_txlock":       "immediate",
+	}
+	for key, value := range c.Params {
+		if key == "charset" {
+			continue
+		}
+		params[key] = value
+	}
+
+	query := make([]string, 0, len(params))
+	for _, key := range sortedKeys(params) {
+		query = append(query, key+"="+params[key])
+	}
+
+	return "file:" + database + "?" + strings.Join(query, "&")
+}
+
+func quotePostgres(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "", "info":
+		return logger.Info, nil
+	case "warn":
+		return logger.Warn, nil
+	case "error":
+		return logger.Error, nil
+	case "silent":
+		return logger.Silent, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+}
@@ -0,0 +1,40 @@
+package golang_gorm
+
+import (
+	"time"
+
+	"golang-gorm/money"
+)
+
+// Conversion is money moved between two wallets holding different
+// currencies. FromAmount is in minor units of FromCurrency and ToAmount in
+// minor units of ToCurrency; Rate is the rate applied, taken from
+// ExchangeRate.
+type Conversion struct {
+	ID             int64         `gorm:"primary_key;column:id;autoIncrement"`
+	IdempotencyKey string        `gorm:"column:idempotency_key;size:100;uniqueIndex"`
+	FromWalletId   string        `gorm:"column:from_wallet_id"`
+	ToWalletId     string        `gorm:"column:to_wallet_id"`
+	FromAmount     int64         `gorm:"column:from_amount"`
+	FromCurrency   string        `gorm:"column:from_currency;size:3"`
+	ToAmount       int64         `gorm:"column:to_amount"`
+	ToCurrency     string        `gorm:"column:to_currency;size:3"`
+	ExchangeRateId int64         `gorm:"column:exchange_rate_id"`
+	Rate           money.Rate    `gorm:"column:rate;type:varchar(40)"`
+	CreatedAt      time.Time     `gorm:"column:created_at;autoCreateTime"`
+	FromWallet     *Wallet       `gorm:"foreignKey:from_wallet_id;references:id"`
+	ToWallet       *Wallet       `gorm:"foreignKey:to_wallet_id;references:id"`
+	ExchangeRate   *ExchangeRate `gorm:"foreignKey:exchange_rate_id;references:id"`
+}
+
+func (c *Conversion) From() money.Money {
+	return money.New(c.FromAmount, c.FromCurrency)
+}
+
+func (c *Conversion) To() money.Money {
+	return money.New(c.ToAmount, c.ToCurrency)
+}
+
+func (c *Conversion) TableName() string {
+	return "conversions"
+}
@@ -0,0 +1,199 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+var ErrSameCurrency = errors.New("wallets hold the same currency")
+
+// ExchangeAccount is the outside account conversions are booked through:
+// it takes in the source currency and pays out the target currency.
+const ExchangeAccount = "exchange"
+
+type ConversionService struct {
+	uow *UnitOfWork
+	now func() time.Time
+}
+
+func NewConversionService(db *gorm.DB) *ConversionService {
+	return &ConversionService{uow: NewUnitOfWork(db), now: time.Now}
+}
+
+// Convert moves amount, in minor units of the source wallet's currency,
+// into a wallet holding another currency. The amount is converted at the
+// rate in effect when the conversion runs and rounded half to even to the
+// target currency. Like Transfer, it is recorded under idempotencyKey and
+// repeating it with the same key and arguments returns the recorded
+// conversion.
+func (s *ConversionService) Convert(ctx context.Context, fromWalletID, toWalletID string, amount int64, idempotencyKey string) (*Conversion, error) {
+	switch {
+	case amount <= 0:
+		return nil, ErrInvalidAmount
+	case fromWalletID == toWalletID:
+		return nil, ErrSameWallet
+	case idempotencyKey == "":
+		return nil, ErrMissingIdempotencyKey
+	}
+
+	var conversion *Conversion
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+
+		previous, err := findConversion(tx, idempotencyKey)
+		if err != nil || previous != nil {
+			conversion = previous
+			return err
+		}
+
+		wallets, err := lockWallets(tx, fromWalletID, toWalletID)
+		if err != nil {
+			return err
+		}
+		from, to := wallets[fromWalletID], wallets[toWalletID]
+		if from.Currency == to.Currency {
+			return fmt.Errorf("%w: wallets %s and %s both hold %s", ErrSameCurrency, fromWalletID, toWalletID, from.Currency)
+		}
+		sent := money.New(amount, from.Currency)
+		if err := checkBalance(from, sent); err != nil {
+			return err
+		}
+
+		at := s.now()
+		rate, err := uow.ExchangeRates().RateAt(ctx, from.Currency, to.Currency, at)
+		if err != nil {
+			return err
+		}
+		applied := rate.In(from.Currency, to.Currency)
+		converted, err := sent.Convert(to.Currency, applied, money.HalfEven)
+		if err != nil {
+			return err
+		}
+		if converted.Amount <= 0 {
+			return fmt.Errorf("%w: %s is less than %s's smallest unit", ErrInvalidAmount, sent, to.Currency)
+		}
+
+		conversion = &Conversion{
+			IdempotencyKey: idempotencyKey,
+			FromWalletId:   fromWalletID,
+			ToWalletId:     toWalletID,
+			FromAmount:     sent.Amount,
+			FromCurrency:   sent.Currency,
+			ToAmount:       converted.Amount,
+			ToCurrency:     to.Currency,
+			ExchangeRateId: rate.ID,
+			Rate:           applied,
+			CreatedAt:      at,
+		}
+		err = NewLedgerService(tx).Post(ctx, Journal{
+			ID:          "conversion:" + idempotencyKey,
+			Description: fmt.Sprintf("convert %s from %s to %s at %s", conversion.From(), fromWalletID, toWalletID, applied),
+			Lines: []JournalLine{
+				{WalletId: fromWalletID, Debit: sent},
+				{Account: ExchangeAccount, Credit: sent},
+				{Account: ExchangeAccount, Debit: converted},
+				{WalletId: toWalletID, Credit: converted},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Create(conversion).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// a concurrent call with the same key won the race
+		conversion, err = findConversion(s.uow.DB().WithContext(ctx), idempotencyKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if conversion.FromWalletId != fromWalletID || conversion.ToWalletId != toWalletID || conversion.FromAmount != amount {
+		return nil, fmt.Errorf("%w: %s", ErrIdempotencyConflict, idempotencyKey)
+	}
+	return conversion, nil
+}
+
+func findConversion(tx *gorm.DB, idempotencyKey string) (*Conversion, error) {
+	var conversion Conversion
+	err := tx.Where("idempotency_key = ?", idempotencyKey).Take(&conversion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conversion, nil
+}
+
+// CurrencyTotal is what the wallets hold in one currency and what that is
+// worth in the report's currency.
+type CurrencyTotal struct {
+	Total     money.Money
+	Rate      money.Rate
+	Converted money.Money
+}
+
+// BalanceReport totals wallet balances in one currency at a moment.
+type BalanceReport struct {
+	Currency   string
+	At         time.Time
+	Currencies []CurrencyTotal
+	Total      money.Money
+}
+
+// Report totals the balances of the wallets matching scopes in currency.
+// The balances are added up per currency first, and each subtotal is
+// converted at the rate in effect at the moment and rounded half to even.
+// Balances already in currency are taken as they are.
+func (s *ConversionService) Report(ctx context.Context, currency string, at time.Time, scopes ...Scope) (*BalanceReport, error) {
+	if !money.Known(currency) {
+		return nil, fmt.Errorf("%w: %q", money.ErrUnknownCurrency, currency)
+	}
+
+	var rows []struct {
+		Currency string
+		Total    int64
+	}
+	err := s.uow.DB().WithContext(ctx).Model(&Wallet{}).
+		Scopes(scopes...).
+		Select("currency", "sum(balance) as total").
+		Group("currency").
+		Order("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rates := NewExchangeRateRepository(s.uow.DB())
+	report := &BalanceReport{Currency: currency, At: at, Total: money.New(0, currency)}
+	for _, row := range rows {
+		line := CurrencyTotal{Total: money.New(row.Total, row.Currency)}
+		if row.Currency == currency {
+			line.Rate = money.MustParseRate("1")
+			line.Converted = line.Total
+		} else {
+			rate, err := rates.RateAt(ctx, row.Currency, currency, at)
+			if err != nil {
+				return nil, err
+			}
+			line.Rate = rate.In(row.Currency, currency)
+			line.Converted, err = line.Total.Convert(currency, line.Rate, money.HalfEven)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		report.Total, err = report.Total.Add(line.Converted)
+		if err != nil {
+			return nil, err
+		}
+		report.Currencies = append(report.Currencies, line)
+	}
+	return report, nil
+}
@@ -0,0 +1,204 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+// seedRates stores USD/IDR at 15000 a day ago and 15500 from now on, and
+// EUR/USD at 1.08.
+func seedRates(t *testing.T, db *gorm.DB, now time.Time) {
+	t.Helper()
+	rates := []ExchangeRate{
+		{Base: "USD", Quote: "IDR", Rate: money.MustParseRate("15000"), EffectiveAt: now.Add(-24 * time.Hour)},
+		{Base: "USD", Quote: "IDR", Rate: money.MustParseRate("15500"), EffectiveAt: now},
+		{Base: "EUR", Quote: "USD", Rate: money.MustParseRate("1.08"), EffectiveAt: now.Add(-24 * time.Hour)},
+	}
+	assert.Nil(t, db.Create(&rates).Error)
+}
+
+func TestExchangeRateAt(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	seedRates(t, db, now)
+	rates := NewExchangeRateRepository(db)
+
+	rate, err := rates.RateAt(ctx, "USD", "IDR", now.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, "15000", rate.In("USD", "IDR").String())
+
+	rate, err = rates.RateAt(ctx, "USD", "IDR", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "15500", rate.In("USD", "IDR").String())
+
+	// quoted the other way round
+	rate, err = rates.RateAt(ctx, "IDR", "USD", now)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rate.In("IDR", "USD").Cmp(money.MustParseRate("15500").Inverse()))
+
+	_, err = rates.RateAt(ctx, "USD", "IDR", now.Add(-48*time.Hour))
+	assert.True(t, errors.Is(err, ErrRateNotFound))
+	_, err = rates.RateAt(ctx, "EUR", "IDR", now)
+	assert.True(t, errors.Is(err, ErrRateNotFound))
+
+	history, err := rates.History(ctx, "USD", "IDR")
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+
+	for _, invalid := range []ExchangeRate{
+		{Base: "USD", Quote: "USD", Rate: money.MustParseRate("1"), EffectiveAt: now},
+		{Base: "USD", Quote: "XXX", Rate: money.MustParseRate("1"), EffectiveAt: now},
+		{Base: "USD", Quote: "SGD", EffectiveAt: now},
+		{Base: "USD", Quote: "SGD", Rate: money.MustParseRate("1.35")},
+	} {
+		err := db.Create(&invalid).Error
+		assert.True(t, errors.Is(err, ErrInvalidRate), err)
+	}
+
+	err = db.Create(&ExchangeRate{Base: "USD", Quote: "IDR", Rate: money.MustParseRate("15600"), EffectiveAt: now}).Error
+	assert.True(t, errors.Is(err, gorm.ErrDuplicatedKey), err)
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	seedRates(t, db, now)
+	ledger := NewLedgerService(db)
+	service := NewConversionService(db)
+	service.now = func() time.Time { return now.Add(time.Minute) }
+
+	dollars := &Wallet{ID: "10", UserId: "2", Currency: "USD"}
+	assert.Nil(t, ledger.OpenWallet(ctx, dollars))
+
+	// IDR 1,000,000 buys USD 64.516..., rounded to USD 64.52
+	conversion, err := service.Convert(ctx, "2", "10", 1000000, "fx-1")
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(1000000, "IDR"), conversion.From())
+	assert.Equal(t, money.New(6452, "USD"), conversion.To())
+	assert.Equal(t, 0, conversion.Rate.Cmp(money.MustParseRate("15500").Inverse()))
+	assert.Equal(t, now.Add(time.Minute), conversion.CreatedAt)
+
+	again, err := service.Convert(ctx, "2", "10", 1000000, "fx-1")
+	assert.Nil(t, err)
+	assert.Equal(t, conversion.ID, again.ID)
+
+	// and back at the same rate
+	back, err := service.Convert(ctx, "10", "2", 1000, "fx-2")
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(155000, "IDR"), back.To())
+
+	wallets := NewWalletRepository(db)
+	rupiah, err := wallets.FindByID(ctx, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(4155000, "IDR"), rupiah.Balance())
+	dollars, err = wallets.FindByID(ctx, "10")
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(5452, "USD"), dollars.Balance())
+
+	reconciliation, err := ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, reconciliation.Clean())
+
+	stored, err := NewRepository[Conversion](db).FindByID(ctx, conversion.ID, Preload("ExchangeRate"))
+	assert.Nil(t, err)
+	assert.Equal(t, "15500", stored.ExchangeRate.Rate.String())
+	assert.Equal(t, conversion.Rate.String(), stored.Rate.String())
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	seedRates(t, db, now)
+	service := NewConversionService(db)
+
+	ledger := NewLedgerService(db)
+	assert.Nil(t, ledger.OpenWallet(ctx, &Wallet{ID: "10", UserId: "2", Currency: "USD"}))
+	assert.Nil(t, ledger.OpenWallet(ctx, &Wallet{ID: "11", UserId: "2", Currency: "SGD"}))
+
+	_, err := service.Convert(ctx, "2", "10", 0, "a")
+	assert.True(t, errors.Is(err, ErrInvalidAmount))
+	_, err = service.Convert(ctx, "2", "2", 100, "a")
+	assert.True(t, errors.Is(err, ErrSameWallet))
+	_, err = service.Convert(ctx, "2", "10", 100, "")
+	assert.True(t, errors.Is(err, ErrMissingIdempotencyKey))
+	_, err = service.Convert(ctx, "2", "1", 100, "a")
+	assert.True(t, errors.Is(err, ErrSameCurrency))
+	_, err = service.Convert(ctx, "2", "99", 100, "a")
+	assert.True(t, errors.Is(err, ErrWalletNotFound))
+	_, err = service.Convert(ctx, "10", "2", 100, "a")
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+	_, err = service.Convert(ctx, "2", "11", 100000, "a")
+	assert.True(t, errors.Is(err, ErrRateNotFound))
+
+	// IDR 10 is less than a cent
+	_, err = service.Convert(ctx, "2", "10", 10, "a")
+	assert.True(t, errors.Is(err, ErrInvalidAmount))
+
+	// before the first rate
+	service.now = func() time.Time { return now.Add(-48 * time.Hour) }
+	_, err = service.Convert(ctx, "2", "10", 100000, "a")
+	assert.True(t, errors.Is(err, ErrRateNotFound))
+	service.now = time.Now
+
+	_, err = service.Convert(ctx, "2", "10", 100000, "a")
+	assert.Nil(t, err)
+	_, err = service.Convert(ctx, "2", "10", 200000, "a")
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+}
+
+func TestBalanceReport(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	seedRates(t, db, now)
+	ledger := NewLedgerService(db)
+	service := NewConversionService(db)
+
+	for _, wallet := range []*Wallet{
+		{ID: "10", UserId: "2", BalanceAmount: 10000, Currency: "USD"},
+		{ID: "11", UserId: "3", BalanceAmount: 5000, Currency: "USD"},
+		{ID: "12", UserId: "2", BalanceAmount: 1000, Currency: "EUR"},
+	} {
+		assert.Nil(t, ledger.OpenWallet(ctx, wallet))
+	}
+
+	// IDR 12,000,000 and USD 150.00 at 15500, EUR 10.00 only through USD
+	_, err := service.Report(ctx, "IDR", now)
+	assert.True(t, errors.Is(err, ErrRateNotFound))
+
+	report, err := service.Report(ctx, "USD", now)
+	assert.Nil(t, err)
+	assert.Equal(t, []money.Money{money.New(1000, "EUR"), money.New(12000000, "IDR"), money.New(15000, "USD")},
+		[]money.Money{report.Currencies[0].Total, report.Currencies[1].Total, report.Currencies[2].Total})
+	assert.Equal(t, money.New(1080, "USD"), report.Currencies[0].Converted)
+	assert.Equal(t, money.New(77419, "USD"), report.Currencies[1].Converted)
+	assert.Equal(t, money.New(15000, "USD"), report.Currencies[2].Converted)
+	assert.Equal(t, money.New(93499, "USD"), report.Total)
+
+	// a day ago the dollar bought less
+	report, err = service.Report(ctx, "USD", now.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(80000, "USD"), report.Currencies[1].Converted)
+
+	// one user's wallets
+	report, err = service.Report(ctx, "IDR", now, Where("user_id = ?", "3"))
+	assert.Nil(t, err)
+	assert.Len(t, report.Currencies, 2)
+	assert.Equal(t, money.New(3000000+775000, "IDR"), report.Total)
+
+	_, err = service.Report(ctx, "XXX", now)
+	assert.True(t, errors.Is(err, money.ErrUnknownCurrency))
+}
@@ -0,0 +1,339 @@
+// Package drift compares GORM models with the live database and points out
+// tag configurations that are almost certainly mistakes.
+package drift
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type Kind string
+
+const (
+	// tag problems, found without looking at the database
+	DuplicateColumn Kind = "duplicate_column"
+	UnknownTag      Kind = "unknown_tag"
+
+	// differences between the models and the database
+	MissingTable      Kind = "missing_table"
+	MissingColumn     Kind = "missing_column"
+	ExtraColumn       Kind = "extra_column"
+	TypeMismatch      Kind = "type_mismatch"
+	MissingIndex      Kind = "missing_index"
+	MissingForeignKey Kind = "missing_foreign_key"
+)
+
+type Issue struct {
+	Kind    Kind
+	Table   string
+	Column  string
+	Model   string
+	Field   string
+	Message string
+}
+
+func (i Issue) String() string {
+	location := i.Table
+	if i.Column != "" {
+		location += "." + i.Column
+	}
+	if i.Model != "" {
+		location += " (" + i.Model
+		if i.Field != "" {
+			location += "." + i.Field
+		}
+		location += ")"
+	}
+	return fmt.Sprintf("%-20s %s: %s", i.Kind, location, i.Message)
+}
+
+type Report struct {
+	Issues []Issue
+}
+
+// Clean reports whether nothing was found.
+func (r Report) Clean() bool {
+	return len(r.Issues) == 0
+}
+
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, issue := range r.Issues {
+		n, err := fmt.Fprintln(w, issue.String())
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// knownTags are the gorm tag keys GORM understands, after upper-casing.
+var knownTags = map[string]bool{
+	"-": true, "->": true, "<-": true,
+	"COLUMN": true, "TYPE": true, "SIZE": true, "PRECISION": true, "SCALE": true,
+	"PRIMARYKEY": true, "PRIMARY_KEY": true, "UNIQUE": true, "DEFAULT": true,
+	"NOT NULL": true, "NOTNULL": true, "NULL": true,
+	"AUTOINCREMENT": true, "AUTOINCREMENTINCREMENT": true,
+	"EMBEDDED": true, "EMBEDDEDPREFIX": true,
+	"AUTOCREATETIME": true, "AUTOUPDATETIME": true,
+	"INDEX": true, "UNIQUEINDEX": true, "CHECK": true, "COMMENT": true, "SERIALIZER": true,
+	"FOREIGNKEY": true, "REFERENCES": true, "POLYMORPHIC": true, "POLYMORPHICVALUE": true,
+	"MANY2MANY": true, "JOINFOREIGNKEY": true, "JOINREFERENCES": true, "CONSTRAINT": true,
+	"IGNOREMIGRATION": true,
+}
+
+// CheckTags parses the models and reports suspicious tag configurations
+// without touching the database.
+func CheckTags(db *gorm.DB, models ...interface{}) ([]Issue, error) {
+	var issues []Issue
+	for _, model := range models {
+		s, err := parse(db, model)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, checkSchema(s)...)
+	}
+	return issues, nil
+}
+
+func checkSchema(s *schema.Schema) []Issue {
+	var issues []Issue
+
+	byColumn := map[string][]*schema.Field{}
+	var columns []string
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if _, ok := byColumn[field.DBName]; !ok {
+			columns = append(columns, field.DBName)
+		}
+		byColumn[field.DBName] = append(byColumn[field.DBName], field)
+	}
+	for _, column := range columns {
+		fields := byColumn[column]
+		if len(fields) < 2 {
+			continue
+		}
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Name
+		}
+		issues = append(issues, Issue{
+			Kind:    DuplicateColumn,
+			Table:   s.Table,
+			Column:  column,
+			Model:   s.Name,
+			Field:   names[1],
+			Message: fmt.Sprintf("fields %s all map to column %s", strings.Join(names, ", "), column),
+		})
+	}
+
+	for _, field := range s.Fields {
+		keys := make([]string, 0, len(field.TagSettings))
+		for key := range field.TagSettings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !knownTags[key] {
+				issues = append(issues, Issue{
+					Kind:    UnknownTag,
+					Table:   s.Table,
+					Column:  field.DBName,
+					Model:   s.Name,
+					Field:   field.Name,
+					Message: fmt.Sprintf("gorm ignores tag %q", strings.ToLower(key)),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// Compare checks the models against the live database: tables, columns,
+// column types, indexes and foreign keys, plus everything CheckTags finds.
+func Compare(db *gorm.DB, models ...interface{}) (Report, error) {
+	var report Report
+	migrator := db.Migrator()
+	// has-one and has-many relations show up on both models
+	checkedConstraints := map[string]bool{}
+
+	for _, model := range models {
+		s, err := parse(db, model)
+		if err != nil {
+			return report, err
+		}
+		report.Issues = append(report.Issues, checkSchema(s)...)
+
+		if !migrator.HasTable(s.Table) {
+			report.Issues = append(report.Issues, Issue{Kind: MissingTable, Table: s.Table, Model: s.Name, Message: "table does not exist"})
+			continue
+		}
+
+		columnIssues, err := compareColumns(db, s.Table, s)
+		if err != nil {
+			return report, err
+		}
+		report.Issues = append(report.Issues, columnIssues...)
+
+		for _, index := range s.ParseIndexes() {
+			if !migrator.HasIndex(s.Table, index.Name) {
+				report.Issues = append(report.Issues, Issue{Kind: MissingIndex, Table: s.Table, Model: s.Name, Message: "index " + index.Name + " does not exist"})
+			}
+		}
+
+		for _, rel := range s.Relationships.Relations {
+			if rel.JoinTable != nil {
+				if !migrator.HasTable(rel.JoinTable.Table) {
+					report.Issues = append(report.Issues, Issue{Kind: MissingTable, Table: rel.JoinTable.Table, Model: s.Name, Field: rel.Name, Message: "join table does not exist"})
+					continue
+				}
+				joinIssues, err := compareColumns(db, rel.JoinTable.Table, rel.JoinTable)
+				if err != nil {
+					return report, err
+				}
+				report.Issues = append(report.Issues, joinIssues...)
+				continue
+			}
+
+			constraint := rel.ParseConstraint()
+			if constraint == nil || constraint.Schema == nil || rel.Field.IgnoreMigration {
+				continue
+			}
+			key := constraint.Schema.Table + "." + constraint.Name
+			if checkedConstraints[key] {
+				continue
+			}
+			checkedConstraints[key] = true
+			if !migrator.HasConstraint(constraint.Schema.Table, constraint.Name) {
+				report.Issues = append(report.Issues, Issue{
+					Kind:    MissingForeignKey,
+					Table:   constraint.Schema.Table,
+					Model:   s.Name,
+					Field:   rel.Name,
+					Message: "foreign key " + constraint.Name + " does not exist",
+				})
+			}
+		}
+	}
+
+	report.Issues = dedupe(report.Issues)
+	return report, nil
+}
+
+func compareColumns(db *gorm.DB, table string, s *schema.Schema) ([]Issue, error) {
+	migrator := db.Migrator()
+	columnTypes, err := migrator.ColumnTypes(table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", table, err)
+	}
+
+	live := map[string]gorm.ColumnType{}
+	for _, columnType := range columnTypes {
+		live[columnType.Name()] = columnType
+	}
+
+	var issues []Issue
+	for _, column := range s.DBNames {
+		field := s.FieldsByDBName[column]
+		columnType, ok := live[column]
+		if !ok {
+			issues = append(issues, Issue{Kind: MissingColumn, Table: table, Column: column, Model: s.Name, Field: field.Name, Message: "column does not exist"})
+			continue
+		}
+		delete(live, column)
+
+		want := baseType(migrator.FullDataTypeOf(field).SQL)
+		got := baseType(columnType.DatabaseTypeName())
+		if want != "" && got != "" && want != got {
+			issues = append(issues, Issue{
+				Kind:    TypeMismatch,
+				Table:   table,
+				Column:  column,
+				Model:   s.Name,
+				Field:   field.Name,
+				Message: fmt.Sprintf("model wants %s, database has %s", want, got),
+			})
+		}
+	}
+
+	extra := make([]string, 0, len(live))
+	for column := range live {
+		extra = append(extra, column)
+	}
+	sort.Strings(extra)
+	for _, column := range extra {
+		issues = append(issues, Issue{Kind: ExtraColumn, Table: table, Column: column, Model: s.Name, Message: "column is not mapped by any field"})
+	}
+
+	return issues, nil
+}
+
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"serial":                      "integer",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"int2":                        "smallint",
+	"bool":                        "boolean",
+	"float8":                      "double precision",
+	"double":                      "double precision",
+	"float4":                      "real",
+	"numeric":                     "decimal",
+	"character varying":           "varchar",
+	"timestamp with time zone":    "timestamptz",
+	"timestamp without time zone": "timestamp",
+}
+
+var typeModifiers = regexp.MustCompile(`\(.*?\)`)
+
+// baseType reduces a column definition such as "varchar(191) NOT NULL" or
+// "integer PRIMARY KEY AUTOINCREMENT" to a comparable type name.
+func baseType(definition string) string {
+	definition = strings.ToLower(typeModifiers.ReplaceAllString(definition, ""))
+	for _, multiWord := range []string{"double precision", "character varying", "timestamp with time zone", "timestamp without time zone"} {
+		if strings.HasPrefix(definition, multiWord) {
+			definition = multiWord
+		}
+	}
+	if alias, ok := typeAliases[definition]; ok {
+		return alias
+	}
+	if fields := strings.Fields(definition); len(fields) > 0 {
+		if alias, ok := typeAliases[fields[0]]; ok {
+			return alias
+		}
+		return fields[0]
+	}
+	return ""
+}
+
+func parse(db *gorm.DB, model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+func dedupe(issues []Issue) []Issue {
+	seen := map[Issue]bool{}
+	out := issues[:0]
+	for _, issue := range issues {
+		if !seen[issue] {
+			seen[issue] = true
+			out = append(out, issue)
+		}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package drift
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type author struct {
+	ID        string    `gorm:"primaryKey"`
+	Name      string    `gorm:"index"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:created_at;autoCreatedTime"`
+	Books     []book
+}
+
+type book struct {
+	ID       int64 `gorm:"primaryKey"`
+	AuthorID string
+	Pages    int64
+	Title    string
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(t, err)
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+func kinds(issues []Issue) map[Kind]int {
+	counts := map[Kind]int{}
+	for _, issue := range issues {
+		counts[issue.Kind]++
+	}
+	return counts
+}
+
+func TestCheckTags(t *testing.T) {
+	db := openDB(t)
+
+	issues, err := CheckTags(db, &author{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[Kind]int{DuplicateColumn: 1, UnknownTag: 1}, kinds(issues))
+	assert.Equal(t, "created_at", issues[0].Column)
+	assert.Equal(t, "UpdatedAt", issues[1].Field)
+}
+
+func TestCompare(t *testing.T) {
+	db := openDB(t)
+
+	err := db.Exec("CREATE TABLE authors (id text PRIMARY KEY, name text, created_at datetime)").Error
+	assert.Nil(t, err)
+	err = db.Exec("CREATE TABLE books (id integer PRIMARY KEY, author_id text, pages text, isbn text)").Error
+	assert.Nil(t, err)
+
+	report, err := Compare(db, &author{}, &book{})
+	assert.Nil(t, err)
+	assert.False(t, report.Clean())
+	assert.Equal(t, map[Kind]int{
+		DuplicateColumn:   1,
+		UnknownTag:        1,
+		MissingIndex:      1,
+		MissingForeignKey: 1,
+		MissingColumn:     1,
+		TypeMismatch:      1,
+		ExtraColumn:       1,
+	}, kinds(report.Issues))
+
+	var out bytes.Buffer
+	_, err = report.WriteTo(&out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "books.pages (book.Pages): model wants integer, database has text")
+	assert.Contains(t, out.String(), "books.isbn (book): column is not mapped by any field")
+	assert.Contains(t, out.String(), "books.title (book.Title): column does not exist")
+	assert.Contains(t, out.String(), "foreign key fk_authors_books does not exist")
+}
+
+func TestCompareMissingTable(t *testing.T) {
+	db := openDB(t)
+
+	report, err := Compare(db, &book{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(report.Issues))
+	assert.Equal(t, MissingTable, report.Issues[0].Kind)
+}
+
+func TestCompareClean(t *testing.T) {
+	db := openDB(t)
+
+	err := db.AutoMigrate(&book{})
+	assert.Nil(t, err)
+
+	report, err := Compare(db, &book{})
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report.Issues)
+}
+
+func TestBaseType(t *testing.T) {
+	assert.Equal(t, "varchar", baseType("varchar(191) NOT NULL"))
+	assert.Equal(t, "integer", baseType("integer PRIMARY KEY AUTOINCREMENT"))
+	assert.Equal(t, "bigint", baseType("INT8"))
+	assert.Equal(t, "bigint", baseType("bigserial"))
+	assert.Equal(t, "timestamptz", baseType("timestamp with time zone"))
+	assert.Equal(t, "double precision", baseType("double precision"))
+	assert.Equal(t, "datetime", baseType("datetime(3)"))
+}
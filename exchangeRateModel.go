@@ -0,0 +1,51 @@
+package golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// ExchangeRate is how much one unit of Base buys in Quote from EffectiveAt
+// until the next rate for the pair takes effect. A rate also prices the
+// pair the other way round, see In.
+type ExchangeRate struct {
+	ID          int64      `gorm:"primary_key;column:id;autoIncrement"`
+	Base        string     `gorm:"column:base;size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	Quote       string     `gorm:"column:quote;size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	Rate        money.Rate `gorm:"column:rate;type:varchar(40);not null"`
+	EffectiveAt time.Time  `gorm:"column:effective_at;not null;uniqueIndex:idx_exchange_rates_pair"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (e *ExchangeRate) BeforeCreate(db *gorm.DB) error {
+	switch {
+	case !money.Known(e.Base) || !money.Known(e.Quote):
+		return fmt.Errorf("%w: unknown currency in %s/%s", ErrInvalidRate, e.Base, e.Quote)
+	case e.Base == e.Quote:
+		return fmt.Errorf("%w: %s to itself", ErrInvalidRate, e.Base)
+	case e.Rate.IsZero():
+		return fmt.Errorf("%w: %s/%s has no rate", ErrInvalidRate, e.Base, e.Quote)
+	case e.EffectiveAt.IsZero():
+		return fmt.Errorf("%w: %s/%s has no effective date", ErrInvalidRate, e.Base, e.Quote)
+	}
+	return nil
+}
+
+// In returns the rate from base to quote, inverting the stored rate when
+// it is quoted the other way round.
+func (e *ExchangeRate) In(base, quote string) money.Rate {
+	if e.Base == quote && e.Quote == base {
+		return e.Rate.Inverse()
+	}
+	return e.Rate
+}
+
+func (e *ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
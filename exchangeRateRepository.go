@@ -0,0 +1,47 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+type ExchangeRateRepository struct {
+	*Repository[ExchangeRate]
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{NewRepository[ExchangeRate](db)}
+}
+
+func (r *ExchangeRateRepository) WithTx(tx *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{r.Repository.WithTx(tx)}
+}
+
+// RateAt returns the rate between base and quote in effect at the moment:
+// the latest one effective at or before it, quoted either way round. Use
+// In on the result for the rate from base to quote.
+func (r *ExchangeRateRepository) RateAt(ctx context.Context, base, quote string, at time.Time) (*ExchangeRate, error) {
+	var rate ExchangeRate
+	err := r.DB(ctx).
+		Where("((base = ? AND quote = ?) OR (base = ? AND quote = ?)) AND effective_at <= ?", base, quote, quote, base, at).
+		Order("effective_at DESC, id DESC").
+		Take(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s to %s at %s", ErrRateNotFound, base, quote, at.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// History returns the rates quoted from base to quote, oldest first.
+func (r *ExchangeRateRepository) History(ctx context.Context, base, quote string) ([]ExchangeRate, error) {
+	return r.FindAll(ctx, Where("base = ? AND quote = ?", base, quote), OrderBy("effective_at"))
+}
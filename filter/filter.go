@@ -0,0 +1,307 @@
+// Package filter turns query strings such as
+//
+//	first_name[like]=User%&balance[gt]=50000&sort=-created_at
+//
+// into GORM scopes.
+//
+// Parse only checks the syntax and returns a Query; Fields.Compile checks
+// that query against the fields a model exposes and converts every value to
+// the field's type. Column names in the SQL come from Fields alone and values
+// are always bound as parameters, so nothing from the query string is ever
+// spliced into SQL.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalid = errors.New("invalid filter")
+
+type Op string
+
+const (
+	Eq    Op = "eq"
+	Ne    Op = "ne"
+	Gt    Op = "gt"
+	Gte   Op = "gte"
+	Lt    Op = "lt"
+	Lte   Op = "lte"
+	Like  Op = "like"
+	In    Op = "in"
+	NotIn Op = "nin"
+	// Null takes true or false.
+	Null Op = "null"
+)
+
+var ops = map[Op]bool{Eq: true, Ne: true, Gt: true, Gte: true, Lt: true, Lte: true, Like: true, In: true, NotIn: true, Null: true}
+
+// SortParam is the query string key holding the comma separated sort
+// fields, each prefixed with "-" for descending order.
+const SortParam = "sort"
+
+const (
+	maxConditions = 20
+	maxValues     = 100
+)
+
+// Condition is one field[op]=value pair. In and NotIn carry every comma
+// separated value, the other operators exactly one.
+type Condition struct {
+	Field  string
+	Op     Op
+	Values []string
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Query is a parsed filter: all conditions must hold.
+type Query struct {
+	Conditions []Condition
+	Sort       []Sort
+}
+
+var paramPattern = regexp.MustCompile(`^([a-z][a-z0-9_]*(?:\.[a-z][a-z0-9_]*)?)(?:\[([a-z]+)\])?$`)
+
+// Parse reads a raw query string.
+func Parse(query string) (*Query, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return ParseValues(values)
+}
+
+// ParseValues reads already decoded query parameters. Callers sharing the
+// query string with other parameters, such as a page cursor, remove those
+// first.
+func ParseValues(values url.Values) (*Query, error) {
+	query := &Query{}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == SortParam {
+			for _, value := range values[key] {
+				sorts, err := parseSort(value)
+				if err != nil {
+					return nil, err
+				}
+				query.Sort = append(query.Sort, sorts...)
+			}
+			continue
+		}
+
+		match := paramPattern.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrInvalid, key)
+		}
+		op := Op(match[2])
+		if op == "" {
+			op = Eq
+		}
+		if !ops[op] {
+			return nil, fmt.Errorf("%w: unknown operator %q on %s", ErrInvalid, op, match[1])
+		}
+
+		for _, value := range values[key] {
+			condition := Condition{Field: match[1], Op: op, Values: []string{value}}
+			if op == In || op == NotIn {
+				condition.Values = strings.Split(value, ",")
+				if len(condition.Values) > maxValues {
+					return nil, fmt.Errorf("%w: more than %d values for %s", ErrInvalid, maxValues, match[1])
+				}
+			}
+			query.Conditions = append(query.Conditions, condition)
+		}
+	}
+
+	if len(query.Conditions) > maxConditions {
+		return nil, fmt.Errorf("%w: more than %d conditions", ErrInvalid, maxConditions)
+	}
+	return query, nil
+}
+
+func parseSort(value string) ([]Sort, error) {
+	var sorts []Sort
+	for _, field := range strings.Split(value, ",") {
+		s := Sort{Field: field}
+		if strings.HasPrefix(field, "-") {
+			s = Sort{Field: field[1:], Desc: true}
+		}
+		if match := paramPattern.FindStringSubmatch(s.Field); match == nil || match[2] != "" {
+			return nil, fmt.Errorf("%w: malformed sort field %q", ErrInvalid, field)
+		}
+		sorts = append(sorts, s)
+	}
+	return sorts, nil
+}
+
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Field is a column a model lets clients filter and sort by.
+type Field struct {
+	// Table is the table or join alias; empty means the model's own table.
+	Table  string
+	Column string
+	Type   Type
+	// Join is what to join for this field, as passed to gorm's Joins: an
+	// association such as "Wallet" or a JOIN clause, whose placeholders
+	// JoinArgs fill.
+	Join     string
+	JoinArgs []interface{}
+}
+
+// Fields maps the names clients use to the fields they stand for.
+type Fields map[string]Field
+
+// Compile validates query against f and returns a scope applying it.
+func (f Fields) Compile(query *Query) (func(db *gorm.DB) *gorm.DB, error) {
+	var (
+		exprs []clause.Expression
+		order []clause.OrderByColumn
+		joins []Field
+	)
+	joined := map[string]bool{}
+	use := func(field Field) {
+		if field.Join != "" && !joined[field.Join] {
+			joined[field.Join] = true
+			joins = append(joins, field)
+		}
+	}
+
+	for _, condition := range query.Conditions {
+		field, ok := f[condition.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalid, condition.Field)
+		}
+		expr, err := field.compile(condition)
+		if err != nil {
+			return nil, err
+		}
+		use(field)
+		exprs = append(exprs, expr)
+	}
+
+	for _, s := range query.Sort {
+		field, ok := f[s.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalid, s.Field)
+		}
+		use(field)
+		order = append(order, clause.OrderByColumn{Column: field.column(), Desc: s.Desc})
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, join := range joins {
+			db = db.Joins(join.Join, join.JoinArgs...)
+		}
+		if len(exprs) > 0 {
+			db = db.Where(clause.And(exprs...))
+		}
+		for _, column := range order {
+			db = db.Order(column)
+		}
+		return db
+	}, nil
+}
+
+func (f Field) column() clause.Column {
+	table := f.Table
+	if table == "" {
+		table = clause.CurrentTable
+	}
+	return clause.Column{Table: table, Name: f.Column}
+}
+
+func (f Field) compile(condition Condition) (clause.Expression, error) {
+	column := f.column()
+
+	if condition.Op == Null {
+		isNull, err := strconv.ParseBool(condition.Values[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s[null] takes true or false", ErrInvalid, condition.Field)
+		}
+		if isNull {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	}
+	if condition.Op == Like && f.Type != String {
+		return nil, fmt.Errorf("%w: %s does not support like", ErrInvalid, condition.Field)
+	}
+
+	values := make([]interface{}, len(condition.Values))
+	for i, raw := range condition.Values {
+		value, err := f.convert(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, condition.Field, err)
+		}
+		values[i] = value
+	}
+
+	switch condition.Op {
+	case Eq:
+		return clause.Eq{Column: column, Value: values[0]}, nil
+	case Ne:
+		return clause.Neq{Column: column, Value: values[0]}, nil
+	case Gt:
+		return clause.Gt{Column: column, Value: values[0]}, nil
+	case Gte:
+		return clause.Gte{Column: column, Value: values[0]}, nil
+	case Lt:
+		return clause.Lt{Column: column, Value: values[0]}, nil
+	case Lte:
+		return clause.Lte{Column: column, Value: values[0]}, nil
+	case Like:
+		return clause.Like{Column: column, Value: values[0]}, nil
+	case In:
+		return clause.IN{Column: column, Values: values}, nil
+	case NotIn:
+		return clause.Not(clause.IN{Column: column, Values: values}), nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalid, condition.Op)
+}
+
+func (f Field) convert(raw string) (interface{}, error) {
+	switch f.Type {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an RFC 3339 time or a date", raw)
+	}
+	return raw, nil
+}
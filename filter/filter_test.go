@@ -0,0 +1,186 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Owner struct {
+	ID    int64 `gorm:"primaryKey"`
+	Name  string
+	Badge Badge
+}
+
+type Badge struct {
+	ID      int64 `gorm:"primaryKey"`
+	OwnerID int64
+	Points  int64
+}
+
+type Item struct {
+	ID        int64 `gorm:"primaryKey"`
+	Name      string
+	Score     int64
+	Weight    float64
+	Active    bool
+	Note      *string
+	CreatedAt time.Time
+}
+
+var itemFields = Fields{
+	"id":         {Column: "id", Type: Int},
+	"name":       {Column: "name", Type: String},
+	"score":      {Column: "score", Type: Int},
+	"weight":     {Column: "weight", Type: Float},
+	"active":     {Column: "active", Type: Bool},
+	"note":       {Column: "note", Type: String},
+	"created_at": {Column: "created_at", Type: Time},
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	assert.Nil(t, db.AutoMigrate(&Item{}, &Owner{}, &Badge{}))
+	note := "fragile"
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []Item{
+		{ID: 1, Name: "apple", Score: 10, Weight: 0.5, Active: true, CreatedAt: base},
+		{ID: 2, Name: "apricot", Score: 20, Weight: 1.5, Note: &note, CreatedAt: base.AddDate(0, 1, 0)},
+		{ID: 3, Name: "banana", Score: 30, Weight: 2.5, Active: true, CreatedAt: base.AddDate(0, 2, 0)},
+	}
+	assert.Nil(t, db.Create(&items).Error)
+	owners := []Owner{
+		{ID: 1, Name: "ann", Badge: Badge{Points: 5}},
+		{ID: 2, Name: "bob", Badge: Badge{Points: 50}},
+	}
+	assert.Nil(t, db.Create(&owners).Error)
+	return db
+}
+
+func find(t *testing.T, db *gorm.DB, fields Fields, query string) []int64 {
+	t.Helper()
+	parsed, err := Parse(query)
+	assert.Nil(t, err)
+	scope, err := fields.Compile(parsed)
+	assert.Nil(t, err)
+
+	var items []Item
+	assert.Nil(t, db.Scopes(scope).Find(&items).Error)
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func TestParse(t *testing.T) {
+	query, err := Parse("name[like]=ap%25&score[gt]=5&id[in]=1,2&sort=-created_at,name")
+	assert.Nil(t, err)
+	assert.Equal(t, []Condition{
+		{Field: "id", Op: In, Values: []string{"1", "2"}},
+		{Field: "name", Op: Like, Values: []string{"ap%"}},
+		{Field: "score", Op: Gt, Values: []string{"5"}},
+	}, query.Conditions)
+	assert.Equal(t, []Sort{{Field: "created_at", Desc: true}, {Field: "name"}}, query.Sort)
+
+	query, err = Parse("name=apple")
+	assert.Nil(t, err)
+	assert.Equal(t, []Condition{{Field: "name", Op: Eq, Values: []string{"apple"}}}, query.Conditions)
+}
+
+func TestParseRejectsMalformed(t *testing.T) {
+	for _, query := range []string{
+		"name[regex]=x",
+		"name[like=x",
+		"name;drop table items=1",
+		"Name=x",
+		"sort=name[asc]",
+		"sort=-",
+		"%zz",
+	} {
+		_, err := Parse(query)
+		assert.True(t, errors.Is(err, ErrInvalid), query)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	db := openDB(t)
+
+	assert.Equal(t, []int64{1, 2}, find(t, db, itemFields, "name[like]=ap%25&sort=id"))
+	assert.Equal(t, []int64{3, 2}, find(t, db, itemFields, "score[gte]=20&sort=-score"))
+	assert.Equal(t, []int64{1}, find(t, db, itemFields, "score[lt]=20"))
+	assert.Equal(t, []int64{1, 3}, find(t, db, itemFields, "score[ne]=20&sort=id"))
+	assert.Equal(t, []int64{2}, find(t, db, itemFields, "weight[gt]=1&weight[lte]=2"))
+	assert.Equal(t, []int64{1, 3}, find(t, db, itemFields, "active=true&sort=id"))
+	assert.Equal(t, []int64{1, 3}, find(t, db, itemFields, "id[in]=1,3,9&sort=id"))
+	assert.Equal(t, []int64{2}, find(t, db, itemFields, "id[nin]=1,3"))
+	assert.Equal(t, []int64{2}, find(t, db, itemFields, "note[null]=false"))
+	assert.Equal(t, []int64{1, 3}, find(t, db, itemFields, "note[null]=true&sort=id"))
+	assert.Equal(t, []int64{2, 3}, find(t, db, itemFields, "created_at[gt]=2025-01-15&sort=id"))
+	assert.Equal(t, []int64{3}, find(t, db, itemFields, "created_at[gte]=2025-03-01T00:00:00Z"))
+}
+
+func TestCompileRejects(t *testing.T) {
+	for _, query := range []string{
+		"password=x",
+		"sort=password",
+		"score=ten",
+		"score[like]=1%25",
+		"active=maybe",
+		"created_at[lt]=yesterday",
+		"note[null]=perhaps",
+	} {
+		parsed, err := Parse(query)
+		assert.Nil(t, err, query)
+		_, err = itemFields.Compile(parsed)
+		assert.True(t, errors.Is(err, ErrInvalid), query)
+	}
+}
+
+func TestCompileBindsValues(t *testing.T) {
+	db := openDB(t)
+
+	// values are bound, never spliced into the SQL
+	assert.Empty(t, find(t, db, itemFields, "name=x'%20OR%20'1'='1"))
+	assert.Empty(t, find(t, db, itemFields, "name[in]=a'),(1"))
+
+	parsed, err := Parse("name[like]=%25'%20OR%201=1--&sort=-id")
+	assert.Nil(t, err)
+	scope, err := itemFields.Compile(parsed)
+	assert.Nil(t, err)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(scope).Find(&[]Item{})
+	})
+	assert.Contains(t, sql, "`items`.`name` LIKE \"%' OR 1=1--\"")
+	assert.Contains(t, sql, "ORDER BY `items`.`id` DESC")
+}
+
+func TestCompileJoins(t *testing.T) {
+	db := openDB(t)
+	fields := Fields{
+		"name":   {Column: "name", Type: String},
+		"points": {Table: "Badge", Column: "points", Type: Int, Join: "Badge"},
+	}
+
+	parsed, err := Parse("points[gt]=10&sort=-points")
+	assert.Nil(t, err)
+	scope, err := fields.Compile(parsed)
+	assert.Nil(t, err)
+
+	var owners []Owner
+	assert.Nil(t, db.Scopes(scope).Find(&owners).Error)
+	assert.Len(t, owners, 1)
+	assert.Equal(t, "bob", owners[0].Name)
+	assert.Equal(t, int64(50), owners[0].Badge.Points)
+}
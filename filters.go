@@ -0,0 +1,109 @@
+package golang_gorm
+
+import (
+	"golang-gorm/filter"
+	"gorm.io/gorm/clause"
+)
+
+// The Filters below are the fields each model lets clients filter and sort
+// by through the query string DSL, see package filter. A column left out
+// here cannot be queried, which is how passwords stay out of reach.
+
+var UserFilters = filter.Fields{
+	"id":          {Column: "id", Type: filter.String},
+	"first_name":  {Column: "first_name", Type: filter.String},
+	"middle_name": {Column: "middle_name", Type: filter.String},
+	"last_name":   {Column: "last_name", Type: filter.String},
+	"created_at":  {Column: "created_at", Type: filter.Time},
+	"updated_at":  {Column: "updated_at", Type: filter.Time},
+	// the balance of the user's wallet in the default currency
+	"balance":        {Table: "Wallet", Column: "balance", Type: filter.Int, Join: defaultWalletJoin, JoinArgs: defaultWalletJoinArgs},
+	"wallet.balance": {Table: "Wallet", Column: "balance", Type: filter.Int, Join: defaultWalletJoin, JoinArgs: defaultWalletJoinArgs},
+}
+
+// defaultWalletJoin joins users to their wallet in DefaultCurrency, which
+// a user has at most one of. The identifiers go in as arguments so that
+// the Wallet alias is quoted the way the filters refer to it.
+const defaultWalletJoin = "LEFT JOIN ? ON ? = ? AND ? = ?"
+
+var defaultWalletJoinArgs = []interface{}{
+	clause.Table{Name: "wallets", Alias: "Wallet"},
+	clause.Column{Table: "Wallet", Name: "user_id"},
+	clause.Column{Table: clause.CurrentTable, Name: "id"},
+	clause.Column{Table: "Wallet", Name: "currency"},
+	DefaultCurrency,
+}
+
+var WalletFilters = filter.Fields{
+	"id":         {Column: "id", Type: filter.String},
+	"user_id":    {Column: "user_id", Type: filter.String},
+	"balance":    {Column: "balance", Type: filter.Int},
+	"currency":   {Column: "currency", Type: filter.String},
+	"created_at": {Column: "created_at", Type: filter.Time},
+	"updated_at": {Column: "updated_at", Type: filter.Time},
+}
+
+var AddressFilters = filter.Fields{
+	"id":          {Column: "id", Type: filter.Int},
+	"user_id":     {Column: "user_id", Type: filter.String},
+	"label":       {Column: "label", Type: filter.String},
+	"street":      {Column: "street", Type: filter.String},
+	"city":        {Column: "city", Type: filter.String},
+	"province":    {Column: "province", Type: filter.String},
+	"postal_code": {Column: "postal_code", Type: filter.String},
+	"country":     {Column: "country", Type: filter.String},
+	"primary":     {Column: "is_primary", Type: filter.Bool},
+	"latitude":    {Column: "latitude", Type: filter.Float},
+	"longitude":   {Column: "longitude", Type: filter.Float},
+	"created_at":  {Column: "created_at", Type: filter.Time},
+	"updated_at":  {Column: "updated_at", Type: filter.Time},
+}
+
+var ProductFilters = filter.Fields{
+	"id":         {Column: "id", Type: filter.String},
+	"name":       {Column: "name", Type: filter.String},
+	"price":      {Column: "price", Type: filter.Int},
+	"currency":   {Column: "currency", Type: filter.String},
+	"created_at": {Column: "created_at", Type: filter.Time},
+	"updated_at": {Column: "updated_at", Type: filter.Time},
+}
+
+var TodoFilters = filter.Fields{
+	"id":          {Column: "id", Type: filter.Int},
+	"user_id":     {Column: "user_id", Type: filter.String},
+	"title":       {Column: "title", Type: filter.String},
+	"description": {Column: "description", Type: filter.String},
+	"status":      {Column: "status", Type: filter.String},
+	"priority":    {Column: "priority", Type: filter.Int},
+	"due_at":      {Column: "due_at", Type: filter.Time},
+	"position":    {Column: "position", Type: filter.Int},
+	"created_at":  {Column: "created_at", Type: filter.Time},
+	"updated_at":  {Column: "updated_at", Type: filter.Time},
+}
+
+var GuestBookFilters = filter.Fields{
+	"id":         {Column: "id", Type: filter.Int},
+	"name":       {Column: "name", Type: filter.String},
+	"email":      {Column: "email", Type: filter.String},
+	"message":    {Column: "message", Type: filter.String},
+	"status":     {Column: "status", Type: filter.String},
+	"created_at": {Column: "created_at", Type: filter.Time},
+}
+
+var ExchangeRateFilters = filter.Fields{
+	"id":           {Column: "id", Type: filter.Int},
+	"base":         {Column: "base", Type: filter.String},
+	"quote":        {Column: "quote", Type: filter.String},
+	"effective_at": {Column: "effective_at", Type: filter.Time},
+	"created_at":   {Column: "created_at", Type: filter.Time},
+}
+
+// FilterScope parses query with the filter DSL and compiles it against
+// fields, for use with FindAll, Count and Page.
+func FilterScope(fields filter.Fields, query string) (Scope, error) {
+	parsed, err := filter.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return fields.Compile(parsed)
+}
@@ -0,0 +1,69 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/filter"
+	"golang-gorm/pagination"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestUserFilters(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	users := NewUserRepository(db)
+	ctx := context.Background()
+
+	// a second wallet in another currency neither matches nor repeats the user
+	assert.Nil(t, db.Create(&Wallet{ID: "20", UserId: "2", Currency: "USD", BalanceAmount: 9000000}).Error)
+
+	scope, err := FilterScope(UserFilters, "first_name[like]=User%25&balance[gt]=1000000&sort=-balance,id")
+	assert.Nil(t, err)
+	found, err := users.FindAll(ctx, scope)
+	assert.Nil(t, err)
+	assert.Len(t, found, 3)
+	assert.Equal(t, "2", found[0].ID)
+	assert.Equal(t, "3", found[1].ID)
+	assert.Equal(t, "4", found[2].ID)
+
+	scope, err = FilterScope(UserFilters, "last_name=Fatir&middle_name[ne]=")
+	assert.Nil(t, err)
+	count, err := users.Count(ctx, scope)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	scope, err = FilterScope(UserFilters, "wallet.balance[lte]=1000000")
+	assert.Nil(t, err)
+	page, err := users.Page(ctx, pagination.Params{}, []pagination.Key{{Column: "id"}}, scope)
+	assert.Nil(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, "1", page.Items[0].ID)
+}
+
+func TestUserFiltersPostgres(t *testing.T) {
+	t.Parallel()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+
+	scope, err := FilterScope(UserFilters, "balance[gt]=1000000&sort=-wallet.balance")
+	assert.Nil(t, err)
+	stmt := db.Scopes(scope).Find(&[]User{}).Statement
+	assert.Contains(t, stmt.SQL.String(),
+		`LEFT JOIN "wallets" "Wallet" ON "Wallet"."user_id" = "users"."id" AND "Wallet"."currency" = $1`)
+	assert.Contains(t, stmt.SQL.String(), `"Wallet"."balance" > $2`)
+	assert.Contains(t, stmt.SQL.String(), `ORDER BY "Wallet"."balance" DESC`)
+	assert.Equal(t, []interface{}{DefaultCurrency, int64(1000000)}, stmt.Vars[:2])
+}
+
+func TestFiltersRejectUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	for _, query := range []string{"password=knok", "sort=password", "balance[like]=1%25"} {
+		_, err := FilterScope(UserFilters, query)
+		assert.True(t, errors.Is(err, filter.ErrInvalid), query)
+	}
+}
@@ -0,0 +1,421 @@
+// Package fixture loads table rows from YAML or JSON files and inserts them
+// in dependency order.
+//
+// A fixture file maps table names to named rows:
+//
+//	users:
+//	  budi:
+//	    id: "1"
+//	    first_name: Budi
+//	wallets:
+//	  budi_wallet:
+//	    id: "1"
+//	    user_id: "@users.budi"
+//	    balance: 1000000
+//
+// A string value "@table.row" is replaced by the id column of that row and
+// "@table.row.column" by any other column; "@@" escapes a literal "@". Rows
+// may also be given as a list when nothing refers to them by name. JSON files
+// use the same shape.
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Row struct {
+	Name    string
+	Columns []string
+	Values  map[string]interface{}
+}
+
+type Table struct {
+	Name string
+	Rows []*Row
+}
+
+// Set is a collection of fixture tables, in the order they were loaded.
+type Set struct {
+	tables []*Table
+	index  map[string]*Table
+}
+
+type reference struct {
+	table  string
+	row    string
+	column string
+}
+
+func (r reference) String() string {
+	return r.table + "." + r.row + "." + r.column
+}
+
+// Load reads fixture files. A directory argument loads every .yaml, .yml
+// and .json file inside it in name order.
+func Load(paths ...string) (*Set, error) {
+	set := &Set{index: map[string]*Table{}}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".yaml", ".yml", ".json":
+					if !entry.IsDir() {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if err := set.parse(data); err != nil {
+				return nil, fmt.Errorf("fixture %s: %w", file, err)
+			}
+		}
+	}
+
+	return set, nil
+}
+
+// Parse reads fixtures from YAML or JSON content.
+func Parse(data []byte) (*Set, error) {
+	set := &Set{index: map[string]*Table{}}
+	if err := set.parse(data); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *Set) parse(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("top level must map table names to rows")
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		name := root.Content[i].Value
+		table := s.index[name]
+		if table == nil {
+			table = &Table{Name: name}
+			s.index[name] = table
+			s.tables = append(s.tables, table)
+		}
+
+		rows := root.Content[i+1]
+		switch rows.Kind {
+		case yaml.MappingNode:
+			for j := 0; j < len(rows.Content); j += 2 {
+				row, err := parseRow(rows.Content[j].Value, rows.Content[j+1])
+				if err != nil {
+					return fmt.Errorf("%s.%s: %w", name, rows.Content[j].Value, err)
+				}
+				if table.row(row.Name) != nil {
+					return fmt.Errorf("%s.%s: defined twice", name, row.Name)
+				}
+				table.Rows = append(table.Rows, row)
+			}
+		case yaml.SequenceNode:
+			for _, node := range rows.Content {
+				rowName := fmt.Sprintf("#%d", len(table.Rows))
+				row, err := parseRow(rowName, node)
+				if err != nil {
+					return fmt.Errorf("%s[%d]: %w", name, len(table.Rows), err)
+				}
+				table.Rows = append(table.Rows, row)
+			}
+		default:
+			return fmt.Errorf("%s: rows must be a mapping or a list", name)
+		}
+	}
+
+	return nil
+}
+
+func parseRow(name string, node *yaml.Node) (*Row, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("row must map column names to values")
+	}
+
+	row := &Row{Name: name, Values: map[string]interface{}{}}
+	for i := 0; i < len(node.Content); i += 2 {
+		column := node.Content[i].Value
+		var value interface{}
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return nil, fmt.Errorf("%s: %w", column, err)
+		}
+		if _, ok := row.Values[column]; !ok {
+			row.Columns = append(row.Columns, column)
+		}
+		row.Values[column] = value
+	}
+	return row, nil
+}
+
+func (t *Table) row(name string) *Row {
+	for _, row := range t.Rows {
+		if row.Name == name {
+			return row
+		}
+	}
+	return nil
+}
+
+// Tables returns the table names in the order they were loaded.
+func (s *Set) Tables() []string {
+	names := make([]string, len(s.tables))
+	for i, table := range s.tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// Table returns the named table, or nil.
+func (s *Set) Table(name string) *Table {
+	return s.index[name]
+}
+
+// Order returns the table names sorted so that every table comes after the
+// tables its rows refer to. Unrelated tables keep their load order.
+func (s *Set) Order() ([]string, error) {
+	deps := map[string]map[string]bool{}
+	for _, table := range s.tables {
+		deps[table.Name] = map[string]bool{}
+		for _, row := range table.Rows {
+			for _, column := range row.Columns {
+				ref, ok, err := parseReference(row.Values[column])
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s.%s: %w", table.Name, row.Name, column, err)
+				}
+				if ok && ref.table != table.Name {
+					deps[table.Name][ref.table] = true
+				}
+			}
+		}
+	}
+
+	var order []string
+	state := map[string]int{} // 1 visiting, 2 done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("reference cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case 2:
+			return nil
+		}
+		if s.index[name] == nil {
+			return fmt.Errorf("%s referenced by %s is not a fixture table", name, path[len(path)-1])
+		}
+
+		state[name] = 1
+		children := make([]string, 0, len(deps[name]))
+		for dep := range deps[name] {
+			children = append(children, dep)
+		}
+		sort.Slice(children, func(i, j int) bool {
+			return s.position(children[i]) < s.position(children[j])
+		})
+		for _, dep := range children {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, name)
+		return nil
+	}
+
+	for _, table := range s.tables {
+		if err := visit(table.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (s *Set) position(name string) int {
+	for i, table := range s.tables {
+		if table.Name == name {
+			return i
+		}
+	}
+	return len(s.tables)
+}
+
+// Insert writes every row in dependency order inside one transaction.
+// created_at and updated_at are filled with the current time when the table
+// has them as date/time columns and the fixture leaves them out.
+func (s *Set) Insert(db *gorm.DB) error {
+	order, err := s.Order()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range order {
+			table := s.index[name]
+			timestamps, err := timestampColumns(tx, name)
+			if err != nil {
+				return err
+			}
+
+			for _, row := range table.Rows {
+				values := make(map[string]interface{}, len(row.Values)+len(timestamps))
+				for _, column := range row.Columns {
+					value, err := s.resolve(row.Values[column])
+					if err != nil {
+						return fmt.Errorf("%s.%s.%s: %w", name, row.Name, column, err)
+					}
+					values[column] = value
+				}
+				for _, column := range timestamps {
+					if _, ok := values[column]; !ok {
+						values[column] = now
+					}
+				}
+
+				if err := tx.Table(name).Create(values).Error; err != nil {
+					return fmt.Errorf("insert %s.%s: %w", name, row.Name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func timestampColumns(db *gorm.DB, table string) ([]string, error) {
+	columnTypes, err := db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", table, err)
+	}
+
+	var columns []string
+	for _, columnType := range columnTypes {
+		switch columnType.Name() {
+		case "created_at", "updated_at":
+			kind := strings.ToLower(columnType.DatabaseTypeName())
+			if strings.Contains(kind, "time") || strings.Contains(kind, "date") {
+				columns = append(columns, columnType.Name())
+			}
+		}
+	}
+	return columns, nil
+}
+
+// Reset deletes every row from the fixture tables, children first.
+func (s *Set) Reset(db *gorm.DB) error {
+	order, err := s.Order()
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := len(order) - 1; i >= 0; i-- {
+			if err := tx.Exec("DELETE FROM ?", clause.Table{Name: order[i]}).Error; err != nil {
+				return fmt.Errorf("reset %s: %w", order[i], err)
+			}
+		}
+		return nil
+	})
+}
+
+// Reload resets the fixture tables and inserts the fixtures again.
+func (s *Set) Reload(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := s.Reset(tx); err != nil {
+			return err
+		}
+		return s.Insert(tx)
+	})
+}
+
+func (s *Set) resolve(value interface{}) (interface{}, error) {
+	return s.follow(value, nil)
+}
+
+// follow resolves value, where path holds the references that led to it.
+func (s *Set) follow(value interface{}, path []reference) (interface{}, error) {
+	ref, ok, err := parseReference(value)
+	if err != nil || !ok {
+		if str, isStr := value.(string); isStr && strings.HasPrefix(str, "@@") {
+			return str[1:], err
+		}
+		return value, err
+	}
+
+	path = append(path, ref)
+	for _, seen := range path[:len(path)-1] {
+		if seen == ref {
+			steps := make([]string, len(path))
+			for i, step := range path {
+				steps[i] = step.String()
+			}
+			return nil, fmt.Errorf("reference cycle: %s", strings.Join(steps, " -> "))
+		}
+	}
+
+	table := s.index[ref.table]
+	if table == nil {
+		return nil, fmt.Errorf("unknown table %q", ref.table)
+	}
+	row := table.row(ref.row)
+	if row == nil {
+		return nil, fmt.Errorf("unknown fixture %s.%s", ref.table, ref.row)
+	}
+	target, ok := row.Values[ref.column]
+	if !ok {
+		return nil, fmt.Errorf("fixture %s.%s has no %s column", ref.table, ref.row, ref.column)
+	}
+	return s.follow(target, path)
+}
+
+func parseReference(value interface{}) (reference, bool, error) {
+	str, ok := value.(string)
+	if !ok || !strings.HasPrefix(str, "@") || strings.HasPrefix(str, "@@") {
+		return reference{}, false, nil
+	}
+
+	parts := strings.Split(str[1:], ".")
+	switch len(parts) {
+	case 2:
+		return reference{table: parts[0], row: parts[1], column: "id"}, true, nil
+	case 3:
+		return reference{table: parts[0], row: parts[1], column: parts[2]}, true, nil
+	default:
+		return reference{}, false, fmt.Errorf("invalid reference %q", str)
+	}
+}
@@ -0,0 +1,153 @@
+package fixture
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type author struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt int64 `gorm:"autoUpdateTime:false"`
+}
+
+type book struct {
+	ID       int64 `gorm:"primaryKey;autoIncrement"`
+	AuthorID string
+	Title    string
+	Author   author
+}
+
+const library = `
+books:
+  gorm_book:
+    author_id: "@authors.eko"
+    title: Belajar GORM
+  handle:
+    author_id: "@authors.eko.handle"
+    title: "@@eko"
+authors:
+  eko:
+    id: "A1"
+    name: Eko
+    handle: A1
+`
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:?_foreign_keys=1"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(t, err)
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	err = db.AutoMigrate(&author{}, &book{})
+	assert.Nil(t, err)
+	err = db.Exec("ALTER TABLE authors ADD COLUMN handle text").Error
+	assert.Nil(t, err)
+
+	return db
+}
+
+func TestOrder(t *testing.T) {
+	set, err := Parse([]byte(library))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"books", "authors"}, set.Tables())
+
+	order, err := set.Order()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"authors", "books"}, order)
+}
+
+func TestOrderErrors(t *testing.T) {
+	set, err := Parse([]byte("a:\n  x: {b_id: \"@b.y\"}\nb:\n  y: {a_id: \"@a.x\"}\n"))
+	assert.Nil(t, err)
+	_, err = set.Order()
+	assert.ErrorContains(t, err, "reference cycle")
+
+	set, err = Parse([]byte("a:\n  x: {b_id: \"@missing.y\"}\n"))
+	assert.Nil(t, err)
+	_, err = set.Order()
+	assert.ErrorContains(t, err, "not a fixture table")
+
+	_, err = Parse([]byte("a:\n  x: {id: 1}\n  x: {id: 2}\n"))
+	assert.NotNil(t, err)
+}
+
+func TestInsertAndReset(t *testing.T) {
+	db := openDB(t)
+
+	set, err := Parse([]byte(library))
+	assert.Nil(t, err)
+
+	err = set.Insert(db)
+	assert.Nil(t, err)
+
+	var books []book
+	err = db.Preload("Author").Order("id").Find(&books).Error
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(books))
+	assert.Equal(t, "Eko", books[0].Author.Name)
+	assert.Equal(t, "A1", books[1].AuthorID)
+	assert.Equal(t, "@eko", books[1].Title)
+	assert.False(t, books[0].Author.CreatedAt.IsZero())
+	assert.Zero(t, books[0].Author.UpdatedAt)
+
+	err = set.Reload(db)
+	assert.Nil(t, err)
+
+	var count int64
+	db.Model(&book{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	err = set.Reset(db)
+	assert.Nil(t, err)
+	db.Model(&author{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestInsertUnknownRow(t *testing.T) {
+	db := openDB(t)
+
+	set, err := Parse([]byte("authors:\n  eko: {id: A1, name: Eko}\nbooks:\n  - {author_id: \"@authors.budi\", title: x}\n"))
+	assert.Nil(t, err)
+
+	err = set.Insert(db)
+	assert.ErrorContains(t, err, "unknown fixture authors.budi")
+}
+
+func TestInsertReferenceCycle(t *testing.T) {
+	db := openDB(t)
+
+	set, err := Parse([]byte("authors:\n  eko: {id: A1, name: \"@authors.budi.name\"}\n  budi: {id: A2, name: \"@authors.eko.name\"}\n"))
+	assert.Nil(t, err)
+
+	err = set.Insert(db)
+	assert.ErrorContains(t, err, "reference cycle: authors.budi.name -> authors.eko.name -> authors.budi.name")
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "authors.json"), []byte(`{"authors": {"eko": {"id": "A1", "name": "Eko"}}}`), 0o600)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "books.yaml"), []byte("books:\n  - {author_id: \"@authors.eko\", title: x}\n"), 0o600)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a fixture"), 0o600)
+	assert.Nil(t, err)
+
+	set, err := Load(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"authors", "books"}, set.Tables())
+	assert.Equal(t, 1, len(set.Table("books").Rows))
+}
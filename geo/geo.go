@@ -0,0 +1,152 @@
+// Package geo turns addresses into coordinates and measures the distances
+// between them.
+package geo
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EarthRadius is the mean radius of the Earth in meters.
+const EarthRadius = 6371008.8
+
+// ErrNotFound is returned by a Geocoder that does not know the address.
+var ErrNotFound = errors.New("geo: address not found")
+
+// Point is a position in degrees.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Query is the address to geocode. Country is an ISO 3166-1 alpha-2 code.
+type Query struct {
+	Street     string
+	City       string
+	Province   string
+	PostalCode string
+	Country    string
+}
+
+// Geocoder finds the position of an address.
+type Geocoder interface {
+	Geocode(ctx context.Context, q Query) (Point, error)
+}
+
+// Distance is the great-circle distance between a and b in meters.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLng := lat2-lat1, radians(b.Lng-a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Bounds returns the corners of a box holding every point within radius
+// meters of center, for narrowing a search down with an index before
+// measuring Distance. Near the poles and across the antimeridian the box
+// spans all longitudes.
+func Bounds(center Point, radius float64) (min, max Point) {
+	dLat := degrees(radius / EarthRadius)
+	min.Lat = math.Max(center.Lat-dLat, -90)
+	max.Lat = math.Min(center.Lat+dLat, 90)
+
+	min.Lng, max.Lng = -180, 180
+	if min.Lat > -90 && max.Lat < 90 {
+		dLng := degrees(math.Asin(math.Min(1, math.Sin(radius/EarthRadius)/math.Cos(radians(center.Lat)))))
+		if center.Lng-dLng >= -180 && center.Lng+dLng <= 180 {
+			min.Lng, max.Lng = center.Lng-dLng, center.Lng+dLng
+		}
+	}
+	return min, max
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Gazetteer geocodes addresses to the position of their city, from a list
+// of places kept in memory. It suits tests and offline use; a city it does
+// not list is ErrNotFound.
+type Gazetteer struct {
+	places map[string]Point
+}
+
+// LoadGazetteer reads a gazetteer file, see ReadGazetteer.
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := ReadGazetteer(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// ReadGazetteer reads places as CSV records of country code, city,
+// latitude and longitude. Lines starting with # are comments.
+func ReadGazetteer(r io.Reader) (*Gazetteer, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	g := &Gazetteer{places: map[string]Point{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		lat, latErr := strconv.ParseFloat(record[2], 64)
+		lng, lngErr := strconv.ParseFloat(record[3], 64)
+		point := Point{Lat: lat, Lng: lng}
+		if latErr != nil || lngErr != nil || !point.Valid() {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: invalid position %s,%s", line, record[2], record[3])
+		}
+		g.Add(record[0], record[1], point)
+	}
+}
+
+// Add lists the city at point.
+func (g *Gazetteer) Add(country, city string, point Point) {
+	if g.places == nil {
+		g.places = map[string]Point{}
+	}
+	g.places[placeKey(country, city)] = point
+}
+
+func (g *Gazetteer) Geocode(ctx context.Context, q Query) (Point, error) {
+	if err := ctx.Err(); err != nil {
+		return Point{}, err
+	}
+	point, ok := g.places[placeKey(q.Country, q.City)]
+	if !ok {
+		return Point{}, fmt.Errorf("%w: %s, %s", ErrNotFound, q.City, q.Country)
+	}
+	return point, nil
+}
+
+func placeKey(country, city string) string {
+	return strings.ToUpper(strings.TrimSpace(country)) + "|" + strings.ToLower(strings.TrimSpace(city))
+}
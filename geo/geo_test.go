@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	bandung    = Point{Lat: -6.9175, Lng: 107.6191}
+	padalarang = Point{Lat: -6.8430, Lng: 107.4767}
+	jakarta    = Point{Lat: -6.2088, Lng: 106.8456}
+)
+
+func TestDistance(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0.0, Distance(bandung, bandung))
+	assert.InDelta(t, 17700, Distance(bandung, padalarang), 300)
+	assert.InDelta(t, 116000, Distance(bandung, jakarta), 2000)
+	assert.InDelta(t, Distance(bandung, jakarta), Distance(jakarta, bandung), 1e-6)
+	// half way around the equator
+	assert.InDelta(t, 20015114, Distance(Point{0, 0}, Point{0, 180}), 1)
+}
+
+func TestBounds(t *testing.T) {
+	t.Parallel()
+
+	min, max := Bounds(bandung, 20000)
+	for _, p := range []Point{bandung, padalarang} {
+		assert.True(t, p.Lat >= min.Lat && p.Lat <= max.Lat && p.Lng >= min.Lng && p.Lng <= max.Lng, p)
+	}
+	assert.False(t, jakarta.Lng >= min.Lng)
+
+	// the box holds the circle: points due east and north at the radius
+	min, max = Bounds(Point{Lat: 60, Lng: 10}, 100000)
+	assert.InDelta(t, 100000, Distance(Point{60, 10}, Point{max.Lat, 10}), 1)
+	assert.True(t, Distance(Point{60, 10}, Point{60, max.Lng}) >= 100000)
+
+	min, max = Bounds(Point{Lat: 89.9, Lng: 0}, 50000)
+	assert.Equal(t, 90.0, max.Lat)
+	assert.Equal(t, -180.0, min.Lng)
+	assert.Equal(t, 180.0, max.Lng)
+
+	min, max = Bounds(Point{Lat: 0, Lng: 179.99}, 50000)
+	assert.Equal(t, -180.0, min.Lng)
+	assert.Equal(t, 180.0, max.Lng)
+}
+
+func TestGazetteer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	g, err := LoadGazetteer("../testdata/gazetteer.csv")
+	assert.Nil(t, err)
+
+	point, err := g.Geocode(ctx, Query{City: " BANDUNG ", Country: "id"})
+	assert.Nil(t, err)
+	assert.Equal(t, bandung, point)
+
+	_, err = g.Geocode(ctx, Query{City: "Bandung", Country: "SG"})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	g.Add("ID", "Bekasi", Point{Lat: -6.2383, Lng: 106.9756})
+	_, err = g.Geocode(ctx, Query{City: "Bekasi", Country: "ID"})
+	assert.Nil(t, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = g.Geocode(canceled, Query{City: "Bandung", Country: "ID"})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, err = ReadGazetteer(strings.NewReader("ID,Bandung,-6.9\n"))
+	assert.NotNil(t, err)
+	_, err = ReadGazetteer(strings.NewReader("# comment\nID,Bandung,-96.9,107.6\n"))
+	assert.EqualError(t, err, "line 2: invalid position -96.9,107.6")
+
+	var empty Gazetteer
+	empty.Add("ID", "Bandung", bandung)
+	_, err = empty.Geocode(ctx, Query{City: "Bandung", Country: "ID"})
+	assert.Nil(t, err)
+}
@@ -3,59 +3,41 @@ package golang_gorm
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/mysql"
+	"golang-gorm/drift"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"golang-gorm/money"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
-	"gorm.io/gorm/logger"
 )
 
-func OpenConnection() *gorm.DB {
-	dialect := mysql.Open("root:@tcp(localhost:3306)/golang_gorm2?charset=utf8mb4&parseTime=True&loc=Local")
-	db, err := gorm.Open(dialect, &gorm.Config{
-		Logger:      logger.Default.LogMode(logger.Info),
-		PrepareStmt: true,
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	sqlDB, err := db.DB()
-
-	if err != nil {
-		panic(err)
-	}
-
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetConnMaxLifetime(30 * time.Minute)
-	sqlDB.SetConnMaxIdleTime(5 * time.Minute)
-
-	return db
-}
-
-var db = OpenConnection()
-
 func TestOpenConnection(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
 	assert.NotNil(t, db)
 }
 
 // execute sql
 func TestExecuteSQL(t *testing.T) {
-	err := db.Exec("insert into sample(id,name) values (?,?)", "1", "Budi").Error
+	t.Parallel()
+	db := newTestDB(t)
+
+	err := db.Exec("insert into sample(id,name) values (?,?)", "5", "Budi").Error
 	assert.Nil(t, err)
 
-	err = db.Exec("insert into sample(id,name) values (?,?)", "2", "Fatir").Error
+	err = db.Exec("insert into sample(id,name) values (?,?)", "6", "Fatir").Error
 	assert.Nil(t, err)
 
-	err = db.Exec("insert into sample(id,name) values (?,?)", "3", "Abdur").Error
+	err = db.Exec("insert into sample(id,name) values (?,?)", "7", "Abdur").Error
 	assert.Nil(t, err)
 
-	err = db.Exec("insert into sample(id,name) values (?,?)", "4", "Eko").Error
+	err = db.Exec("insert into sample(id,name) values (?,?)", "8", "Eko").Error
 	assert.Nil(t, err)
 }
 
@@ -65,6 +47,9 @@ type Sample struct {
 }
 
 func TestRawSQL(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var sample Sample
 	err := db.Raw("select id, name from sample where id = ?", "1").Scan(&sample).Error
 	assert.Nil(t, err)
@@ -78,6 +63,9 @@ func TestRawSQL(t *testing.T) {
 }
 
 func TestSQLRow(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	rows, err := db.Raw("select id, name from sample").Rows()
 	assert.Nil(t, err)
 	defer rows.Close()
@@ -99,6 +87,9 @@ func TestSQLRow(t *testing.T) {
 }
 
 func TestScanRow(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	rows, err := db.Raw("select id, name from sample").Rows()
 	assert.Nil(t, err)
 	defer rows.Close()
@@ -112,8 +103,11 @@ func TestScanRow(t *testing.T) {
 }
 
 func TestCreateUser(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
-		ID:       "1",
+		ID:       "30",
 		Password: "knok",
 		Name: Name{
 			FirstName:  "Budi",
@@ -129,8 +123,11 @@ func TestCreateUser(t *testing.T) {
 }
 
 func TestBatchInsert(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
-	for i := 2; i < 10; i++ {
+	for i := 31; i < 39; i++ {
 		users = append(users, User{
 			ID:       strconv.Itoa(i),
 			Password: "rahasia",
@@ -146,6 +143,9 @@ func TestBatchInsert(t *testing.T) {
 }
 
 func TestTransactionSuccess(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	err := db.Transaction(func(tx *gorm.DB) error {
 		err := tx.Create(&User{
 			ID:       "10",
@@ -184,6 +184,9 @@ func TestTransactionSuccess(t *testing.T) {
 }
 
 func TestTransactionError(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	err := db.Transaction(func(tx *gorm.DB) error {
 		err := tx.Create(&User{
 			ID:       "13",
@@ -196,9 +199,9 @@ func TestTransactionError(t *testing.T) {
 		}
 
 		err = tx.Create(&User{
-			ID:       "11",
+			ID:       "1",
 			Password: "rahasia",
-			Name:     Name{FirstName: "User 11"},
+			Name:     Name{FirstName: "User 1"},
 		}).Error
 
 		if err != nil {
@@ -209,9 +212,17 @@ func TestTransactionError(t *testing.T) {
 	})
 
 	assert.NotNil(t, err)
+
+	var count int64
+	err = db.Model(&User{}).Where("id = ?", "13").Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
 }
 
 func TestManuakTransactionSuccess(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	tx := db.Begin()
 	defer tx.Rollback()
 
@@ -235,6 +246,9 @@ func TestManuakTransactionSuccess(t *testing.T) {
 }
 
 func TestManuakTransactionError(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	tx := db.Begin()
 	defer tx.Rollback()
 
@@ -246,11 +260,11 @@ func TestManuakTransactionError(t *testing.T) {
 	assert.Nil(t, err)
 
 	err = tx.Create(&User{
-		ID:       "14",
+		ID:       "1",
 		Password: "rahasia",
-		Name:     Name{FirstName: "User 14"},
+		Name:     Name{FirstName: "User 1"},
 	}).Error
-	assert.Nil(t, err)
+	assert.NotNil(t, err)
 
 	if err == nil {
 		tx.Commit()
@@ -258,6 +272,9 @@ func TestManuakTransactionError(t *testing.T) {
 }
 
 func TestQuerySingleObject(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{}
 	err := db.First(&user).Error
 	assert.Nil(t, err)
@@ -271,6 +288,9 @@ func TestQuerySingleObject(t *testing.T) {
 }
 
 func TestQueryInlineCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{}
 	err := db.Take(&user, "id = ?", "5").Error
 	assert.Nil(t, err)
@@ -279,6 +299,9 @@ func TestQueryInlineCondition(t *testing.T) {
 }
 
 func TestQueryAllObject(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Find(&users, "id in ?", []string{"1", "2", "3", "6", "8"}).Error
 	assert.Nil(t, err)
@@ -286,20 +309,29 @@ func TestQueryAllObject(t *testing.T) {
 }
 
 func TestQueryCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Where("first_name like ?", "%User%").Where("password = ?", "rahasia").Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 13, len((users)))
+	assert.Equal(t, 8, len((users)))
 }
 
 func TestOrOperator(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Where("first_name = ?", "Budi").Or("password like ?", "rahasia").Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 14, len((users)))
+	assert.Equal(t, 9, len((users)))
 }
 
 func TestNotOperator(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Not("first_name like ?", "%User%").Not("password = ?", "rahasia").Find(&users).Error
 	assert.Nil(t, err)
@@ -307,6 +339,9 @@ func TestNotOperator(t *testing.T) {
 }
 
 func TestSelectField(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Select("id", "first_name").Find(&users).Error
 	assert.Nil(t, err)
@@ -315,10 +350,13 @@ func TestSelectField(t *testing.T) {
 		assert.NotNil(t, user.ID)
 		assert.NotEqual(t, "", user.Name.FirstName)
 	}
-	assert.Equal(t, 14, len(users))
+	assert.Equal(t, 9, len(users))
 }
 
 func TestStructCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	userCondition := User{
 		Name: Name{
 			FirstName: "User 8",
@@ -332,6 +370,9 @@ func TestStructCondition(t *testing.T) {
 }
 
 func TestMapCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	mapCondition := map[string]interface{}{
 		"middle_name": "",
 		"last_name":   "",
@@ -339,14 +380,17 @@ func TestMapCondition(t *testing.T) {
 	var users []User
 	err := db.Where(mapCondition).Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 13, len(users))
+	assert.Equal(t, 8, len(users))
 }
 
 func TestOrderLimitOffset(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Order("id asc, first_name desc").Limit(5).Offset(5).Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 5, len(users))
+	assert.Equal(t, 4, len(users))
 }
 
 type UserResponse struct {
@@ -356,14 +400,20 @@ type UserResponse struct {
 }
 
 func TestQueryNonModel(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []UserResponse
 	err := db.Model(&User{}).Select("id", "first_name", "last_name").Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 14, len(users))
+	assert.Equal(t, 9, len(users))
 	fmt.Println(users)
 }
 
 func TestUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{}
 	err := db.Take(&user, "id = ?", "1").Error
 
@@ -380,6 +430,9 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestUpdateSelectedColumn(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	result := db.Model(&User{}).Where("id =?", "1").Updates(map[string]interface{}{
 		"middle_name": "",
 		"last_name":   "Dika",
@@ -402,6 +455,9 @@ func TestUpdateSelectedColumn(t *testing.T) {
 }
 
 func TestAutoIncrement(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	for i := 0; i < 10; i++ {
 		userLog := UserLog{
 			UserId: strconv.Itoa(i + 1),
@@ -416,6 +472,9 @@ func TestAutoIncrement(t *testing.T) {
 }
 
 func TestSaveOrUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	userLog := UserLog{
 		UserId: "1",
 		Action: "Test Action",
@@ -431,6 +490,9 @@ func TestSaveOrUpdate(t *testing.T) {
 }
 
 func TestSaveOrUpdateNonAutoIncrement(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		ID: "99",
 		Name: Name{
@@ -447,6 +509,9 @@ func TestSaveOrUpdateNonAutoIncrement(t *testing.T) {
 }
 
 func TestCoflict(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		ID: "88",
 		Name: Name{
@@ -459,8 +524,17 @@ func TestCoflict(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	err := db.Create(&[]User{
+		{ID: "99", Name: Name{FirstName: "User 99"}},
+		{ID: "88", Name: Name{FirstName: "User 88"}},
+	}).Error
+	assert.Nil(t, err)
+
 	var user User
-	err := db.Take(&user, "id=?", "99").Error
+	err = db.Take(&user, "id=?", "99").Error
 	assert.Nil(t, err)
 
 	err = db.Delete(&user).Error
@@ -474,6 +548,9 @@ func TestDelete(t *testing.T) {
 }
 
 func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	todo := Todo{
 		UserId:      "1",
 		Title:       "test",
@@ -487,12 +564,15 @@ func TestSoftDelete(t *testing.T) {
 	assert.NotNil(t, todo.DeletedAt)
 
 	var todos []Todo
-	err = db.Find(&todos).Error
+	err = db.Find(&todos, "id = ?", todo.ID).Error
 	assert.Nil(t, err)
 	assert.Equal(t, 0, len(todos))
 }
 
 func TestUncoped(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var todo Todo
 
 	err := db.Unscoped().First(&todo, "id=?", "2").Error
@@ -509,6 +589,9 @@ func TestUncoped(t *testing.T) {
 }
 
 func TestLock(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	err := db.Transaction(func(tx *gorm.DB) error {
 		var user User
 		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&user, "id=?", "1").Error
@@ -526,10 +609,13 @@ func TestLock(t *testing.T) {
 }
 
 func TestCreateWallet(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	wallet := Wallet{
-		ID:      "1",
-		UserId:  "1",
-		Balance: 1000000,
+		ID:            "5",
+		UserId:        "5",
+		BalanceAmount: 1000000,
 	}
 
 	err := db.Create(&wallet).Error
@@ -539,34 +625,46 @@ func TestCreateWallet(t *testing.T) {
 //! relasi itu bersifat lazy load maka kita harus mencoba preload /joins
 
 func TestRetrieveRelation(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
-	err := db.Model(&User{}).Preload("Wallet").Take(&user, "id = ?", "1").Error
+	err := db.Model(&User{}).Preload("Wallets").Take(&user, "id = ?", "1").Error
 	assert.Nil(t, err)
 
 	assert.Equal(t, "1", user.ID)
-	assert.Equal(t, "1", user.Wallet.ID)
+	assert.Equal(t, "1", user.Wallets[0].ID)
 }
 
 func TestJoins(t *testing.T) {
-	var user User
-	err := db.Model(&User{}).Joins("Wallet").Take(&user, "users.id = ?", "1").Error
+	t.Parallel()
+	db := newTestDB(t)
+
+	//! Joins only works for has one and belongs to, so join from the wallet side
+	var wallet Wallet
+	err := db.Model(&Wallet{}).Joins("User").Take(&wallet, "User.id = ? AND wallets.currency = ?", "1", "IDR").Error
 	assert.Nil(t, err)
 
-	assert.Equal(t, "1", user.ID)
-	assert.Equal(t, "1", user.Wallet.ID)
+	assert.Equal(t, "1", wallet.ID)
+	assert.Equal(t, "1", wallet.User.ID)
 }
 
 func TestAutoCreateUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		ID:       "20",
 		Password: "rahasia",
 		Name: Name{
 			FirstName: "User 20",
 		},
-		Wallet: Wallet{
-			ID:      "20",
-			Balance: 1000000,
-			UserId:  "20",
+		Wallets: []Wallet{
+			{
+				ID:            "20",
+				BalanceAmount: 1000000,
+				UserId:        "20",
+			},
 		},
 	}
 
@@ -576,16 +674,21 @@ func TestAutoCreateUpdate(t *testing.T) {
 }
 
 func TestSkipCreateUpdate(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		ID:       "21",
 		Password: "rahasia",
 		Name: Name{
 			FirstName: "User 21",
 		},
-		Wallet: Wallet{
-			ID:      "21",
-			Balance: 1000000,
-			UserId:  "21",
+		Wallets: []Wallet{
+			{
+				ID:            "21",
+				BalanceAmount: 1000000,
+				UserId:        "21",
+			},
 		},
 	}
 
@@ -595,25 +698,30 @@ func TestSkipCreateUpdate(t *testing.T) {
 }
 
 func TestUserAndAddresses(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		ID:       "2",
 		Password: "rahasia",
 		Name: Name{
 			FirstName: "User 2",
 		},
-		Wallet: Wallet{
-			ID:      "2",
-			UserId:  "2",
-			Balance: 5000000,
+		Wallets: []Wallet{
+			{
+				ID:            "2",
+				UserId:        "2",
+				BalanceAmount: 5000000,
+			},
 		},
 		Addresses: []Address{
 			{
-				UserId:  "2",
-				Address: "Bandung",
+				UserId: "2",
+				City:   "Bandung",
 			},
 			{
-				UserId:  "2",
-				Address: "Padalarang",
+				UserId: "2",
+				City:   "Padalarang",
 			},
 		},
 	}
@@ -623,23 +731,32 @@ func TestUserAndAddresses(t *testing.T) {
 }
 
 func TestPreloadJoinOneToMany(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 
-	err := db.Model(&User{}).Preload("Addresses").Joins("Wallet").Find(&users).Error
+	err := db.Model(&User{}).Preload("Addresses").Preload("Wallets").Find(&users).Error
 	assert.Nil(t, err)
 
 }
 
 func TestTake(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
 
-	err := db.Model(&User{}).Preload("Addresses").Joins("Wallet").Take(&user, "users.id=?", "50").Error
+	err := db.Model(&User{}).Preload("Addresses").Preload("Wallets").Take(&user, "users.id=?", "2").Error
 	assert.Nil(t, err)
 
 }
 
 // ! belongs to many to one
 func TestBelongsToAddress(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	fmt.Println("Preload")
 	var addresses []Address
 	err := db.Model(&Address{}).Preload("User").Find(&addresses).Error
@@ -654,6 +771,9 @@ func TestBelongsToAddress(t *testing.T) {
 
 // ! belongs to one to one
 func TestBelongsToWallet(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	fmt.Println("Preload")
 	var wallets []Wallet
 	err := db.Model(&Wallet{}).Preload("User").Find(&wallets).Error
@@ -666,31 +786,40 @@ func TestBelongsToWallet(t *testing.T) {
 }
 
 func TestCreateManyToMany(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	product := Product{
-		ID:    "P001",
-		Name:  "Apple",
-		Price: 50000,
+		ID:          "P003",
+		Name:        "Mango",
+		PriceAmount: 40000,
 	}
 	err := db.Create(&product).Error
 	assert.Nil(t, err)
 
 	err = db.Table("user_like_product").Create((map[string]interface{}{
 		"user_id":    "1",
-		"product_id": "P001",
+		"product_id": "P003",
 	})).Error
 	assert.Nil(t, err)
 
 }
 
 func TestPreloadManyToMany(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var product Product
-	err := db.Preload("LikeByUsers").Find(&product).Error
+	err := db.Preload("LikeByUsers").Find(&product, "id = ?", "P001").Error
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(product.LikeByUsers))
 
 }
 
 func TestPreloadManyToManyUser(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
 	err := db.Preload("LikeProducts").Take(&user, "id = ?", "1").Error
 	assert.Nil(t, err)
@@ -699,6 +828,9 @@ func TestPreloadManyToManyUser(t *testing.T) {
 }
 
 func TestAssociatinFInd(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	// !ambil product
 	var product Product
 
@@ -713,6 +845,9 @@ func TestAssociatinFInd(t *testing.T) {
 }
 
 func TestAssosiationAppend(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
 
 	err := db.Take(&user, "id = ?", "3").Error
@@ -729,24 +864,31 @@ func TestAssosiationAppend(t *testing.T) {
 }
 
 func TestAssociationReplace(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	err := db.Transaction(func(tx *gorm.DB) error {
 		var user User
 		err := tx.Take(&user, "id = ?", "1").Error
 		assert.Nil(t, err)
 
 		wallet := Wallet{
-			ID:      "01",
-			UserId:  user.ID,
-			Balance: 1000000,
+			ID:            "01",
+			UserId:        user.ID,
+			BalanceAmount: 1000000,
+			Currency:      "USD",
 		}
-		err = tx.Model(&user).Association("Wallet").Replace(&wallet)
+		err = tx.Model(&user).Association("Wallets").Replace(&wallet)
 
 		return err
-	}).Error()
+	})
 	assert.Nil(t, err)
 }
 
 func TestAssosiationDelete(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
 
 	err := db.Take(&user, "id = ?", "3").Error
@@ -764,6 +906,9 @@ func TestAssosiationDelete(t *testing.T) {
 
 // ? clear relation
 func TestAssosiationClear(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var product Product
 	err := db.Take(&product, "id = ?", "P001").Error
 	assert.Nil(t, err)
@@ -774,13 +919,19 @@ func TestAssosiationClear(t *testing.T) {
 }
 
 func TestPreloadingWithCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
-	err := db.Preload("Wallet", "balance > ?", 100000).Take(&user, "id=?", "1").Error
+	err := db.Preload("Wallets", "balance > ?", 100000).Take(&user, "id=?", "1").Error
 	assert.Nil(t, err)
 	fmt.Println(user)
 }
 
 func TestNestedPreloading(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var wallet Wallet
 
 	err := db.Preload("User.Addresses").Take(&wallet, "id=?", "2").Error
@@ -792,86 +943,111 @@ func TestNestedPreloading(t *testing.T) {
 }
 
 func TestPreloadingAll(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var user User
 	err := db.Preload(clause.Associations).Take(&user, "id =?", "1").Error
 	assert.Nil(t, err)
 }
 
 func TestJoinQuery(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Joins("join wallets on wallets.user_id=users.id").Find(&users).Error
 	assert.Nil(t, err)
 	assert.Equal(t, 4, len(users))
 
 	users = []User{}
-	err = db.Joins("Wallet").Find(&users).Error //! left join
+	err = db.Joins("left join wallets on wallets.user_id=users.id").Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 17, len(users))
+	assert.Equal(t, 9, len(users))
 }
 
 func TestJoinsWithCondition(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var users []User
 	err := db.Joins("join wallets on wallets.user_id=users.id AND wallets.balance > ?", 50000).Find(&users).Error
 	assert.Nil(t, err)
 	assert.Equal(t, 4, len(users))
 
 	users = []User{}
-	err = db.Joins("Wallet").Where("Wallet.balance > ?", 50000).Find(&users).Error
+	err = db.Where("EXISTS (SELECT 1 FROM wallets WHERE wallets.user_id = users.id AND wallets.balance > ?)", 50000).Find(&users).Error
 	assert.Nil(t, err)
 	assert.Equal(t, 4, len(users))
 }
 
 func TestCount(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var count int64
-	err := db.Model(&User{}).Joins("Wallet").Where("Wallet.balance > ?", 50000).Count(&count).Error
+	err := db.Model(&User{}).Where("EXISTS (SELECT 1 FROM wallets WHERE wallets.user_id = users.id AND wallets.balance > ?)", 50000).Count(&count).Error
 	assert.Nil(t, err)
 	assert.Equal(t, int64(4), count)
 }
 
 type AggregationResult struct {
+	Currency     string
+	WalletCount  int64
 	TotalBalance int64
 	MinBalance   int64
 	MaxBalance   int64
-	AvgBalance   float64
+}
+
+// AvgBalance averages in minor units, rounding half to even, rather than
+// trusting the database's floating point avg.
+func (r AggregationResult) AvgBalance() (money.Money, error) {
+	return money.New(r.TotalBalance, r.Currency).Div(r.WalletCount, money.HalfEven)
 }
 
 func TestAggregation(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var result AggregationResult
-	err := db.Model(&Wallet{}).Select("sum(balance) as total_balance", "min(balance) as min_balance", "max(balance) as max_balance", "avg(balance) as avg_balance").Take(&result).Error
+	err := db.Model(&Wallet{}).Select("currency", "count(*) as wallet_count", "sum(balance) as total_balance", "min(balance) as min_balance", "max(balance) as max_balance").Group("currency").Take(&result).Error
 
 	assert.Nil(t, err)
 	assert.Equal(t, int64(12000000), result.TotalBalance)
 	assert.Equal(t, int64(1000000), result.MinBalance)
 	assert.Equal(t, int64(5000000), result.MaxBalance)
-	assert.Equal(t, float64(3000000), result.AvgBalance)
+	avg, err := result.AvgBalance()
+	assert.Nil(t, err)
+	assert.Equal(t, money.New(3000000, "IDR"), avg)
 }
 
 func TestGroupByAndHaving(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var result []AggregationResult
-	err := db.Model(&Wallet{}).Select("sum(balance) as total_balance", "min(balance) as min_balance", "max(balance) as max_balance", "avg(balance) as avg_balance").Joins("User").Group("User.id").Having("sum(balance) > ?", 1000000).Find(&result).Error
+	err := db.Model(&Wallet{}).Select("count(*) as wallet_count", "sum(balance) as total_balance", "min(balance) as min_balance", "max(balance) as max_balance").Joins("User").Group("User.id").Having("sum(balance) > ?", 1000000).Find(&result).Error
 
 	assert.Nil(t, err)
-	assert.Equal(t, 2, len(result))
+	assert.Equal(t, 3, len(result))
 }
 
 func TestContext(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	ctx := context.Background()
 
 	var users []User
 	err := db.WithContext(ctx).Find(&users).Error
 	assert.Nil(t, err)
-	assert.Equal(t, 17, len(users))
-}
-
-func BrokeWalletBalance(db *gorm.DB) *gorm.DB {
-	return db.Where("balance =?", 0)
-}
-
-func SultanWalletBalance(db *gorm.DB) *gorm.DB {
-	return db.Where("balance >?", 2000000)
+	assert.Equal(t, 9, len(users))
 }
 
 func TestScope(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	var wallets []Wallet
 	err := db.Scopes(BrokeWalletBalance).Find(&wallets).Error
 	assert.Nil(t, err)
@@ -883,11 +1059,17 @@ func TestScope(t *testing.T) {
 }
 
 func TestMigrator(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	err := db.Migrator().AutoMigrate(&GuestBook{})
 	assert.Nil(t, err)
 }
 
 func TestHooks(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
 	user := User{
 		Password: "Rahasia",
 		Name: Name{
@@ -901,3 +1083,76 @@ func TestHooks(t *testing.T) {
 
 	fmt.Println(user.ID)
 }
+
+func TestHooksConcurrentIDs(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	const workers, perWorker = 8, 250
+	ids := make([][]string, workers)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				user := User{Name: Name{FirstName: fmt.Sprintf("Worker %d User %d", worker, i)}}
+				if err := db.Create(&user).Error; err != nil {
+					t.Error(err)
+					return
+				}
+				ids[worker] = append(ids[worker], user.ID)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, created := range ids {
+		assert.True(t, sort.StringsAreSorted(created))
+		for _, id := range created {
+			assert.False(t, seen[id], id)
+			seen[id] = true
+		}
+	}
+	assert.Equal(t, workers*perWorker, len(seen))
+
+	var count int64
+	err := db.Model(&User{}).Where("first_name like ?", "Worker %").Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(workers*perWorker), count)
+
+	wallet := Wallet{UserId: ids[0][0]}
+	assert.Nil(t, db.Create(&wallet).Error)
+	product := Product{Name: "Durian"}
+	assert.Nil(t, db.Create(&product).Error)
+	assert.Less(t, ids[0][len(ids[0])-1], wallet.ID)
+	assert.Less(t, wallet.ID, product.ID)
+}
+
+func TestMigrationStatus(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	migrator, err := migrate.New(db, migrations.All()...)
+	assert.Nil(t, err)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.Nil(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, status.Name)
+	}
+
+	pending, err := migrator.Pending(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(pending))
+}
+
+func TestSchemaDrift(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	report, err := drift.Compare(db, Models()...)
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report.Issues)
+}
@@ -0,0 +1,36 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+type GuestBookRepository struct {
+	*Repository[GuestBook]
+}
+
+func NewGuestBookRepository(db *gorm.DB) *GuestBookRepository {
+	return &GuestBookRepository{NewRepository[GuestBook](db)}
+}
+
+func (r *GuestBookRepository) WithTx(tx *gorm.DB) *GuestBookRepository {
+	return &GuestBookRepository{r.Repository.WithTx(tx)}
+}
+
+// List pages through the guest book, newest entries first.
+func (r *GuestBookRepository) List(ctx context.Context, params pagination.Params) (pagination.Page[GuestBook], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "created_at", Desc: true}})
+}
+
+// ListApproved pages through the entries moderators approved, newest
+// first; these are the ones to show visitors.
+func (r *GuestBookRepository) ListApproved(ctx context.Context, params pagination.Params) (pagination.Page[GuestBook], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "created_at", Desc: true}}, Where("status = ?", GuestBookApproved))
+}
+
+// ListPending pages through the moderation queue, oldest first.
+func (r *GuestBookRepository) ListPending(ctx context.Context, params pagination.Params) (pagination.Page[GuestBook], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "created_at"}}, Where("status = ?", GuestBookPending))
+}
@@ -0,0 +1,210 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxGuestBookName    = 100
+	MaxGuestBookMessage = 2000
+)
+
+var (
+	ErrInvalidSubmission = errors.New("invalid guest book entry")
+	ErrRateLimited       = errors.New("too many guest book entries, try again later")
+)
+
+// SpamCheck looks at an entry before it is stored and returns why it is
+// spam, or "" when it is not. An error stops the submission; the entry is
+// not stored.
+type SpamCheck interface {
+	CheckSpam(ctx context.Context, db *gorm.DB, entry *GuestBook) (reason string, err error)
+}
+
+// SpamCheckFunc adapts a function to SpamCheck.
+type SpamCheckFunc func(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error)
+
+func (f SpamCheckFunc) CheckSpam(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error) {
+	return f(ctx, db, entry)
+}
+
+// KeywordCheck flags entries whose name or message contains one of the
+// keywords, ignoring case.
+type KeywordCheck struct {
+	Keywords []string
+}
+
+func (c KeywordCheck) CheckSpam(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error) {
+	text := strings.ToLower(entry.Name + "\n" + entry.Message)
+	for _, keyword := range c.Keywords {
+		if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+			return fmt.Sprintf("contains %q", keyword), nil
+		}
+	}
+	return "", nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)`)
+
+// LinkCheck flags messages with more than MaxLinks links.
+type LinkCheck struct {
+	MaxLinks int
+}
+
+func (c LinkCheck) CheckSpam(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error) {
+	if links := len(linkPattern.FindAllStringIndex(entry.Message, -1)); links > c.MaxLinks {
+		return fmt.Sprintf("%d links", links), nil
+	}
+	return "", nil
+}
+
+// RateLimitCheck refuses a submission with ErrRateLimited once the email
+// address has made Limit entries within Window. The entries already in the
+// guest book are the count, so the limit holds across every server sharing
+// the database. Run inside the submission's transaction, as Submit does, it
+// locks the address's GuestBookSender row before counting, so concurrent
+// submissions from one address are counted one after the other.
+type RateLimitCheck struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (c RateLimitCheck) CheckSpam(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error) {
+	if err := lockSender(db.WithContext(ctx), entry.Email); err != nil {
+		return "", err
+	}
+
+	var recent int64
+	err := db.WithContext(ctx).Model(&GuestBook{}).
+		Where("email = ? AND created_at >= ?", entry.Email, db.NowFunc().Add(-c.Window)).
+		Count(&recent).Error
+	if err != nil {
+		return "", err
+	}
+	if recent >= int64(c.Limit) {
+		return "", ErrRateLimited
+	}
+	return "", nil
+}
+
+// lockSender locks the row of the email address until the transaction
+// ends, creating it on the address's first submission.
+func lockSender(tx *gorm.DB, email string) error {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&GuestBookSender{Email: email}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&GuestBookSender{}, "email = ?", email).Error
+}
+
+// DefaultSpamChecks is what NewGuestBookService uses when given no checks:
+// at most 3 entries per address an hour, at most 2 links, and a short list
+// of keywords.
+func DefaultSpamChecks() []SpamCheck {
+	return []SpamCheck{
+		RateLimitCheck{Limit: 3, Window: time.Hour},
+		LinkCheck{MaxLinks: 2},
+		KeywordCheck{Keywords: []string{"viagra", "casino", "crypto giveaway", "free money"}},
+	}
+}
+
+// GuestBookService takes guest book submissions through validation and the
+// spam checks into the moderation queue, and carries out the moderators'
+// decisions.
+type GuestBookService struct {
+	uow    *UnitOfWork
+	checks []SpamCheck
+}
+
+func NewGuestBookService(db *gorm.DB, checks ...SpamCheck) *GuestBookService {
+	if len(checks) == 0 {
+		checks = DefaultSpamChecks()
+	}
+	return &GuestBookService{uow: NewUnitOfWork(db), checks: checks}
+}
+
+// Submit validates entry and stores it for moderation. Entries a spam
+// check flags are stored rejected, with the reason, so a moderator can
+// still approve a false positive. Entries a check refuses are not stored.
+func (s *GuestBookService) Submit(ctx context.Context, entry *GuestBook) error {
+	entry.Name = strings.TrimSpace(entry.Name)
+	entry.Email = strings.ToLower(strings.TrimSpace(entry.Email))
+	entry.Message = strings.TrimSpace(entry.Message)
+	if err := validateGuestBook(entry); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		entry.Status = GuestBookPending
+		entry.SpamReason = ""
+		for _, check := range s.checks {
+			reason, err := check.CheckSpam(ctx, uow.DB(), entry)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				entry.Status = GuestBookRejected
+				entry.SpamReason = reason
+				break
+			}
+		}
+		return uow.GuestBooks().Create(ctx, entry)
+	})
+}
+
+func validateGuestBook(entry *GuestBook) error {
+	switch {
+	case entry.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidSubmission)
+	case utf8.RuneCountInString(entry.Name) > MaxGuestBookName:
+		return fmt.Errorf("%w: name is longer than %d characters", ErrInvalidSubmission, MaxGuestBookName)
+	case entry.Message == "":
+		return fmt.Errorf("%w: message is required", ErrInvalidSubmission)
+	case utf8.RuneCountInString(entry.Message) > MaxGuestBookMessage:
+		return fmt.Errorf("%w: message is longer than %d characters", ErrInvalidSubmission, MaxGuestBookMessage)
+	}
+	address, err := mail.ParseAddress(entry.Email)
+	if err != nil || address.Address != entry.Email {
+		return fmt.Errorf("%w: %q is not an email address", ErrInvalidSubmission, entry.Email)
+	}
+	return nil
+}
+
+// Approve publishes the entry, also one that was rejected before.
+func (s *GuestBookService) Approve(ctx context.Context, id int64, moderator string) (*GuestBook, error) {
+	return s.moderate(ctx, id, moderator, GuestBookApproved, "")
+}
+
+// Reject hides the entry, also one that was approved before.
+func (s *GuestBookService) Reject(ctx context.Context, id int64, moderator, reason string) (*GuestBook, error) {
+	return s.moderate(ctx, id, moderator, GuestBookRejected, reason)
+}
+
+func (s *GuestBookService) moderate(ctx context.Context, id int64, moderator string, status GuestBookStatus, reason string) (*GuestBook, error) {
+	var entry *GuestBook
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		guestBooks := uow.GuestBooks()
+		err := guestBooks.UpdateFields(ctx, id, map[string]interface{}{
+			"status":       status,
+			"spam_reason":  reason,
+			"moderated_by": moderator,
+			"moderated_at": uow.DB().NowFunc(),
+		})
+		if err != nil {
+			return err
+		}
+		entry, err = guestBooks.FindByID(ctx, id)
+		return err
+	})
+	return entry, err
+}
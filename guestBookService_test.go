@@ -0,0 +1,173 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+func TestGuestBookSubmit(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	guestBook := NewGuestBookService(db)
+
+	entry := &GuestBook{Name: " Budi ", Email: "Budi@Example.com", Message: "Halo semua"}
+	assert.Nil(t, guestBook.Submit(ctx, entry))
+	assert.Equal(t, GuestBookPending, entry.Status)
+	assert.Equal(t, "Budi", entry.Name)
+	assert.Equal(t, "budi@example.com", entry.Email)
+
+	spam := &GuestBook{Name: "Joko", Email: "joko@example.com", Message: "Win at the CASINO today"}
+	assert.Nil(t, guestBook.Submit(ctx, spam))
+	assert.Equal(t, GuestBookRejected, spam.Status)
+	assert.Equal(t, `contains "casino"`, spam.SpamReason)
+
+	links := &GuestBook{Name: "Joko", Email: "joko2@example.com", Message: "http://a.example www.b.example https://c.example"}
+	assert.Nil(t, guestBook.Submit(ctx, links))
+	assert.Equal(t, GuestBookRejected, links.Status)
+	assert.Equal(t, "3 links", links.SpamReason)
+
+	for _, invalid := range []*GuestBook{
+		{Name: "Budi", Email: "not an email", Message: "Halo"},
+		{Name: "Budi", Email: "Budi <budi@example.com>", Message: "Halo"},
+		{Name: "", Email: "budi@example.com", Message: "Halo"},
+		{Name: "Budi", Email: "budi@example.com", Message: "   "},
+		{Name: "Budi", Email: "budi@example.com", Message: strings.Repeat("a", MaxGuestBookMessage+1)},
+	} {
+		err := guestBook.Submit(ctx, invalid)
+		assert.True(t, errors.Is(err, ErrInvalidSubmission), err)
+		assert.Zero(t, invalid.ID)
+	}
+}
+
+func TestGuestBookRateLimit(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	guestBook := NewGuestBookService(db, RateLimitCheck{Limit: 2, Window: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, guestBook.Submit(ctx, &GuestBook{Name: "Budi", Email: "budi@example.com", Message: "Halo"}))
+	}
+	// the limit is per address, whatever its case
+	err := guestBook.Submit(ctx, &GuestBook{Name: "Budi", Email: "BUDI@example.com", Message: "Halo"})
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Nil(t, guestBook.Submit(ctx, &GuestBook{Name: "Joko", Email: "joko@example.com", Message: "Halo"}))
+
+	var count int64
+	assert.Nil(t, db.Model(&GuestBook{}).Where("email = ?", "budi@example.com").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	// entries older than the window no longer count
+	assert.Nil(t, db.Model(&GuestBook{}).Where("email = ?", "budi@example.com").
+		UpdateColumn("created_at", time.Now().Add(-2*time.Hour)).Error)
+	assert.Nil(t, guestBook.Submit(ctx, &GuestBook{Name: "Budi", Email: "budi@example.com", Message: "Halo"}))
+}
+
+func TestGuestBookRateLimitConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newConcurrentTestDB(t)
+	ctx := context.Background()
+	guestBook := NewGuestBookService(db, RateLimitCheck{Limit: 3, Window: time.Hour})
+
+	var wg sync.WaitGroup
+	var stored, limited int64
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := guestBook.Submit(ctx, &GuestBook{Name: "Budi", Email: "budi@example.com", Message: "Halo"})
+			switch {
+			case err == nil:
+				atomic.AddInt64(&stored, 1)
+			case errors.Is(err, ErrRateLimited):
+				atomic.AddInt64(&limited, 1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(3), stored)
+	assert.Equal(t, int64(5), limited)
+}
+
+func TestGuestBookCustomCheck(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	shouting := SpamCheckFunc(func(ctx context.Context, db *gorm.DB, entry *GuestBook) (string, error) {
+		if entry.Message == strings.ToUpper(entry.Message) {
+			return "shouting", nil
+		}
+		return "", nil
+	})
+	guestBook := NewGuestBookService(db, shouting)
+
+	entry := &GuestBook{Name: "Budi", Email: "budi@example.com", Message: "HALO"}
+	assert.Nil(t, guestBook.Submit(ctx, entry))
+	assert.Equal(t, GuestBookRejected, entry.Status)
+	assert.Equal(t, "shouting", entry.SpamReason)
+}
+
+func TestGuestBookModeration(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	guestBook := NewGuestBookService(db)
+	repo := NewGuestBookRepository(db)
+
+	var entries []*GuestBook
+	for _, message := range []string{"first", "second", "third", "free money!"} {
+		entry := &GuestBook{Name: "Budi", Email: message[:1] + "@example.com", Message: message}
+		assert.Nil(t, guestBook.Submit(ctx, entry))
+		entries = append(entries, entry)
+	}
+
+	pending, err := repo.ListPending(ctx, pagination.Params{Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, guestBookMessages(pending.Items))
+
+	approved, err := guestBook.Approve(ctx, entries[1].ID, "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, GuestBookApproved, approved.Status)
+	assert.Equal(t, "admin", approved.ModeratedBy)
+	assert.NotNil(t, approved.ModeratedAt)
+
+	rejected, err := guestBook.Reject(ctx, entries[0].ID, "admin", "off topic")
+	assert.Nil(t, err)
+	assert.Equal(t, GuestBookRejected, rejected.Status)
+	assert.Equal(t, "off topic", rejected.SpamReason)
+
+	// a false positive can still be approved
+	_, err = guestBook.Approve(ctx, entries[3].ID, "admin")
+	assert.Nil(t, err)
+
+	pending, err = repo.ListPending(ctx, pagination.Params{Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"third"}, guestBookMessages(pending.Items))
+
+	published, err := repo.ListApproved(ctx, pagination.Params{Limit: 10})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"second", "free money!"}, guestBookMessages(published.Items))
+
+	_, err = guestBook.Approve(ctx, 404, "admin")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func guestBookMessages(entries []GuestBook) []string {
+	messages := make([]string, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
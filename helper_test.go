@@ -0,0 +1,189 @@
+package golang_gorm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"golang-gorm/fixture"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"gorm.io/gorm"
+)
+
+// testConfig is the connection the suite runs against: an in-memory SQLite
+// database unless DB_CONFIG or the DB_* variables point it somewhere else.
+func testConfig(t *testing.T) Config {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Driver = "sqlite"
+	cfg.Database = ":memory:"
+
+	if path := os.Getenv("DB_CONFIG"); path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cfg.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+var testSchemaSeq int64
+
+// newTestDB hands the test a database of its own: migrated, seeded with
+// testFixtures and thrown away when the test ends. Tests using it can run
+// alone, in any order and in parallel.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, newTestSchema(t, testConfig(t)))
+}
+
+// newConcurrentTestDB is newTestDB for tests whose statements must really
+// run side by side. The in-memory SQLite database lives on a single
+// connection, so it is swapped for a file in WAL mode that the whole pool
+// can share; server databases are used as they are.
+func newConcurrentTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	cfg := testConfig(t)
+	if !cfg.IsMemory() {
+		return newTestDB(t)
+	}
+	cfg.Database = filepath.Join(t.TempDir(), "test.db")
+	params := map[string]string{"_journal_mode": "WAL"}
+	for key, value := range cfg.Params {
+		params[key] = value
+	}
+	cfg.Params = params
+	return openTestDB(t, cfg)
+}
+
+// openTestDB opens cfg, migrates it and inserts testFixtures.
+func openTestDB(t *testing.T, cfg Config) *gorm.DB {
+	t.Helper()
+
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Table("sample").AutoMigrate(&Sample{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testFixtures.Insert(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// newLegacyTestDB hands the test an empty database migrated only up to
+// version, to seed rows the way older releases stored them before running
+// the remaining migrations over them.
+func newLegacyTestDB(t *testing.T, version int64) *gorm.DB {
+	t.Helper()
+
+	db, err := Open(newTestSchema(t, testConfig(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	var older []migrate.Migration
+	for _, migration := range migrations.All() {
+		if migration.Version <= version {
+			older = append(older, migration)
+		}
+	}
+	migrator, err := migrate.New(db, older...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// newTestSchema points cfg at an empty database created for this test alone.
+// In-memory SQLite is empty on every Open already; server databases get a
+// uniquely named database (MySQL) or schema (PostgreSQL) that is dropped on
+// cleanup, so cfg must use the individual connection fields, not DSN.
+func newTestSchema(t *testing.T, cfg Config) Config {
+	t.Helper()
+
+	if cfg.IsMemory() {
+		return cfg
+	}
+
+	switch cfg.driver() {
+	case "sqlite":
+		cfg.Database = filepath.Join(t.TempDir(), "test.db")
+		return cfg
+	case "mysql", "postgres":
+	default:
+		t.Fatalf("no test schema support for driver %q", cfg.Driver)
+	}
+
+	admin, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	name := fmt.Sprintf("test_%d_%d", os.Getpid(), atomic.AddInt64(&testSchemaSeq, 1))
+	params := make(map[string]string, len(cfg.Params)+1)
+	for key, value := range cfg.Params {
+		params[key] = value
+	}
+
+	if cfg.driver() == "mysql" {
+		if err := admin.Exec("CREATE DATABASE " + name).Error; err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Exec("DROP DATABASE " + name) })
+		cfg.Database = name
+	} else {
+		if err := admin.Exec("CREATE SCHEMA " + name).Error; err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Exec("DROP SCHEMA " + name + " CASCADE") })
+		params["search_path"] = name
+	}
+	cfg.Params = params
+
+	return cfg
+}
+
+// testFixtures are the rows every test starts from, see testdata/fixtures.
+var testFixtures = func() *fixture.Set {
+	set, err := fixture.Load("testdata/fixtures")
+	if err != nil {
+		panic(err)
+	}
+	return set
+}()
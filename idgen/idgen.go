@@ -0,0 +1,177 @@
+// Package idgen generates unique, time-ordered string ids.
+//
+// Every Generator here is safe for concurrent use and monotonic: an id
+// sorts, as a plain string, after every id the same Generator returned
+// before it, even when many are made within one millisecond or the clock
+// steps back.
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Generator interface {
+	NewID() string
+}
+
+func randomUint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("idgen: reading random bytes: " + err.Error())
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// clock returns the current time in milliseconds, never less than last.
+func clock(now func() time.Time, last uint64) uint64 {
+	ms := uint64(now().UnixMilli())
+	if ms < last {
+		return last
+	}
+	return ms
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates 26 character ULIDs: a 48 bit millisecond timestamp and 80
+// random bits in Crockford base32. Ids from the same millisecond increment
+// the random part instead of drawing a new one.
+type ULID struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	ms     uint64
+	randHi uint16
+	randLo uint64
+}
+
+func NewULID() *ULID {
+	return &ULID{now: time.Now}
+}
+
+func (g *ULID) NewID() string {
+	g.mu.Lock()
+	ms := clock(g.now, g.ms)
+	if ms == g.ms {
+		g.randLo++
+		if g.randLo == 0 {
+			g.randHi++
+			if g.randHi == 0 {
+				// 2^80 ids in one millisecond: borrow the next one
+				ms++
+			}
+		}
+	}
+	if ms != g.ms {
+		g.ms = ms
+		g.randHi, g.randLo = uint16(randomUint64()), randomUint64()
+	}
+	hi, lo := g.ms<<16|uint64(g.randHi), g.randLo
+	g.mu.Unlock()
+
+	var dst [26]byte
+	for i := len(dst) - 1; i >= 0; i-- {
+		dst[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(dst[:])
+}
+
+// UUIDv7 generates RFC 9562 version 7 UUIDs in their lowercase hex form. The
+// 74 bits after the millisecond timestamp start random and count up within
+// a millisecond.
+type UUIDv7 struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	ms      uint64
+	counter uint64 // rand_a, 12 bits
+	random  uint64 // rand_b, 62 bits
+}
+
+func NewUUIDv7() *UUIDv7 {
+	return &UUIDv7{now: time.Now}
+}
+
+const (
+	randAMask = 1<<12 - 1
+	randBMask = 1<<62 - 1
+)
+
+func (g *UUIDv7) NewID() string {
+	g.mu.Lock()
+	ms := clock(g.now, g.ms)
+	if ms == g.ms {
+		g.random = (g.random + 1) & randBMask
+		if g.random == 0 {
+			g.counter = (g.counter + 1) & randAMask
+			if g.counter == 0 {
+				ms++
+			}
+		}
+	}
+	if ms != g.ms {
+		g.ms = ms
+		// leave headroom so the counter rarely has to borrow a millisecond
+		g.counter = randomUint64() & (randAMask >> 1)
+		g.random = randomUint64() & randBMask
+	}
+	hi := g.ms<<16 | 0x7<<12 | g.counter
+	lo := uint64(0b10)<<62 | g.random
+	g.mu.Unlock()
+
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&(1<<48-1))
+}
+
+// Snowflake epoch, node and sequence layout: 41 bits of milliseconds since
+// SnowflakeEpoch, 10 bits of node id and a 12 bit per-millisecond sequence.
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	MaxSnowflakeNode = 1<<10 - 1
+	snowflakeSeqMask = 1<<12 - 1
+)
+
+var ErrInvalidNode = errors.New("snowflake node id out of range")
+
+// Snowflake generates Twitter-style snowflake ids, formatted as 19 zero
+// padded decimal digits so that string order matches numeric order. Every
+// process generating ids for the same table needs its own node id.
+type Snowflake struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	node uint64
+	ms   uint64
+	seq  uint64
+}
+
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("%w: %d not in 0..%d", ErrInvalidNode, node, MaxSnowflakeNode)
+	}
+	return &Snowflake{now: time.Now, node: uint64(node)}, nil
+}
+
+func (g *Snowflake) NewID() string {
+	g.mu.Lock()
+	ms := clock(func() time.Time {
+		return time.UnixMilli(g.now().Sub(SnowflakeEpoch).Milliseconds())
+	}, g.ms)
+	if ms == g.ms {
+		g.seq = (g.seq + 1) & snowflakeSeqMask
+		if g.seq == 0 {
+			ms++
+		}
+	} else {
+		g.seq = 0
+	}
+	g.ms = ms
+	id := g.ms<<22 | g.node<<12 | g.seq
+	g.mu.Unlock()
+
+	return fmt.Sprintf("%019d", id)
+}
@@ -0,0 +1,113 @@
+package idgen
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stepClock returns each time in turn and then keeps returning the last.
+func stepClock(times ...time.Time) func() time.Time {
+	var mu sync.Mutex
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now := times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return now
+	}
+}
+
+func generators(t *testing.T, now func() time.Time) map[string]Generator {
+	snowflake, err := NewSnowflake(7)
+	assert.Nil(t, err)
+	ulid, uuid := NewULID(), NewUUIDv7()
+	ulid.now, uuid.now, snowflake.now = now, now, now
+	return map[string]Generator{"ulid": ulid, "uuidv7": uuid, "snowflake": snowflake}
+}
+
+func TestFormat(t *testing.T) {
+	formats := map[string]*regexp.Regexp{
+		"ulid":      regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+		"uuidv7":    regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		"snowflake": regexp.MustCompile(`^[0-9]{19}$`),
+	}
+	for name, generator := range generators(t, time.Now) {
+		assert.Regexp(t, formats[name], generator.NewID(), name)
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	// the same millisecond many times, then the clock steps back
+	times := []time.Time{base}
+	for i := 0; i < 5000; i++ {
+		times = append(times, base)
+	}
+	times = append(times, base.Add(-time.Second), base.Add(time.Millisecond))
+
+	for name, generator := range generators(t, stepClock(times...)) {
+		ids := make([]string, 0, len(times)+10)
+		for i := 0; i < len(times)+10; i++ {
+			ids = append(ids, generator.NewID())
+		}
+		assert.True(t, sort.StringsAreSorted(ids), name)
+		seen := map[string]bool{}
+		for _, id := range ids {
+			assert.False(t, seen[id], name)
+			seen[id] = true
+		}
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	for name, generator := range generators(t, time.Now) {
+		var (
+			mu  sync.Mutex
+			all = map[string]bool{}
+			wg  sync.WaitGroup
+		)
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ids := make([]string, 2000)
+				for i := range ids {
+					ids[i] = generator.NewID()
+				}
+				assert.True(t, sort.StringsAreSorted(ids), name)
+
+				mu.Lock()
+				defer mu.Unlock()
+				for _, id := range ids {
+					assert.False(t, all[id], name)
+					all[id] = true
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 16000, len(all), name)
+	}
+}
+
+func TestSnowflakeNode(t *testing.T) {
+	_, err := NewSnowflake(MaxSnowflakeNode + 1)
+	assert.True(t, errors.Is(err, ErrInvalidNode))
+	_, err = NewSnowflake(-1)
+	assert.True(t, errors.Is(err, ErrInvalidNode))
+
+	a, err := NewSnowflake(1)
+	assert.Nil(t, err)
+	b, err := NewSnowflake(2)
+	assert.Nil(t, err)
+	now := stepClock(SnowflakeEpoch.Add(time.Hour))
+	a.now, b.now = now, now
+	assert.NotEqual(t, a.NewID(), b.NewID())
+}
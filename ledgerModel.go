@@ -0,0 +1,36 @@
+package golang_gorm
+
+import "time"
+
+// LedgerEntry is one side of a journal. Wallet lines carry WalletId; lines
+// against the outside world (cash, opening balances) carry Account instead.
+// Exactly one of Debit and Credit is set, in minor units of Currency; a
+// credit adds to a wallet balance.
+type LedgerEntry struct {
+	ID          int64     `gorm:"primary_key;column:id;autoIncrement"`
+	JournalId   string    `gorm:"column:journal_id;size:100;index"`
+	WalletId    *string   `gorm:"column:wallet_id;index"`
+	Account     string    `gorm:"column:account;size:100"`
+	Debit       int64     `gorm:"column:debit;not null;default:0"`
+	Credit      int64     `gorm:"column:credit;not null;default:0"`
+	Currency    string    `gorm:"column:currency;size:3;not null;default:IDR"`
+	Description string    `gorm:"column:description"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	Wallet      *Wallet   `gorm:"foreignKey:wallet_id;references:id"`
+}
+
+func (e *LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// LedgerJournal records that a journal was posted. Its primary key keeps a
+// journal from being posted twice, even by two transactions at once.
+type LedgerJournal struct {
+	ID          string    `gorm:"primaryKey;column:id;size:100"`
+	Description string    `gorm:"column:description"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (j *LedgerJournal) TableName() string {
+	return "ledger_journals"
+}
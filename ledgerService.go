@@ -0,0 +1,296 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnbalancedJournal = errors.New("journal debits and credits differ")
+	ErrInvalidJournal    = errors.New("invalid journal")
+	ErrDuplicateJournal  = errors.New("journal already posted")
+	ErrNegativeBalance   = errors.New("opening balance cannot be negative")
+)
+
+// OpeningBalanceAccount is the outside account opening balances are booked
+// against.
+const OpeningBalanceAccount = "opening_balance"
+
+// JournalLine moves money in or out of one wallet or outside account. Set
+// WalletId or Account, and Debit or Credit. A wallet's lines are in the
+// wallet's currency.
+type JournalLine struct {
+	WalletId string
+	Account  string
+	Debit    money.Money
+	Credit   money.Money
+}
+
+// Journal is a set of lines whose debits and credits add up to the same
+// amount in every currency, posted all at once or not at all.
+type Journal struct {
+	ID          string
+	Description string
+	Lines       []JournalLine
+}
+
+type LedgerService struct {
+	uow *UnitOfWork
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{uow: NewUnitOfWork(db)}
+}
+
+// Post writes the journal's entries and applies them to the balance of
+// every wallet involved. A LedgerService built on a transaction posts
+// inside it.
+func (s *LedgerService) Post(ctx context.Context, journal Journal) error {
+	if err := journal.validate(); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+
+		err := tx.Create(&LedgerJournal{ID: journal.ID, Description: journal.Description}).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: %s: %w", ErrDuplicateJournal, journal.ID, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		currencies, err := walletCurrencies(tx, journal)
+		if err != nil {
+			return err
+		}
+
+		entries := make([]LedgerEntry, len(journal.Lines))
+		changes := map[string]money.Money{}
+		for i, line := range journal.Lines {
+			entries[i] = LedgerEntry{
+				JournalId:   journal.ID,
+				Account:     line.Account,
+				Debit:       line.Debit.Amount,
+				Credit:      line.Credit.Amount,
+				Currency:    line.currency(),
+				Description: journal.Description,
+			}
+			if line.WalletId == "" {
+				continue
+			}
+			walletID := line.WalletId
+			entries[i].WalletId = &walletID
+			if line.currency() != currencies[walletID] {
+				return fmt.Errorf("%w: journal %s moves %s in wallet %s, which holds %s",
+					money.ErrCurrencyMismatch, journal.ID, line.currency(), walletID, currencies[walletID])
+			}
+			change, ok := changes[walletID]
+			if !ok {
+				change = money.New(0, currencies[walletID])
+			}
+			if line.Debit.IsZero() {
+				change, err = change.Add(line.Credit)
+			} else {
+				change, err = change.Sub(line.Debit)
+			}
+			if err != nil {
+				return err
+			}
+			changes[walletID] = change
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return err
+		}
+
+		walletIDs := make([]string, 0, len(changes))
+		for walletID := range changes {
+			walletIDs = append(walletIDs, walletID)
+		}
+		sort.Strings(walletIDs)
+		for _, walletID := range walletIDs {
+			// Wallet.BalanceAmount is read-only to GORM after create, so the
+			// ledger is the one place that changes it
+			result := tx.Table("wallets").Where("id = ?", walletID).Updates(map[string]interface{}{
+				"balance":    gorm.Expr("balance + ?", changes[walletID].Amount),
+				"updated_at": tx.NowFunc(),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+			}
+		}
+		return nil
+	})
+}
+
+// walletCurrencies returns the currency of every wallet the journal moves
+// money in or out of.
+func walletCurrencies(tx *gorm.DB, journal Journal) (map[string]string, error) {
+	var ids []string
+	for _, line := range journal.Lines {
+		if line.WalletId != "" {
+			ids = append(ids, line.WalletId)
+		}
+	}
+	var wallets []Wallet
+	if err := tx.Select("id", "currency").Where("id IN ?", ids).Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]string, len(wallets))
+	for _, wallet := range wallets {
+		currencies[wallet.ID] = wallet.Currency
+	}
+	for _, id := range ids {
+		if _, ok := currencies[id]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, id)
+		}
+	}
+	return currencies, nil
+}
+
+// OpenWallet creates wallet and books its balance as an opening balance, so
+// the ledger accounts for the money the wallet starts with.
+func (s *LedgerService) OpenWallet(ctx context.Context, wallet *Wallet) error {
+	if wallet.BalanceAmount < 0 {
+		return ErrNegativeBalance
+	}
+
+	opening := wallet.BalanceAmount
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		wallet.BalanceAmount = 0
+		if err := uow.Wallets().Create(ctx, wallet); err != nil {
+			return err
+		}
+		if opening == 0 {
+			return nil
+		}
+		amount := money.New(opening, wallet.Currency)
+		return NewLedgerService(uow.DB()).Post(ctx, Journal{
+			ID:          "opening:" + wallet.ID,
+			Description: "opening balance",
+			Lines: []JournalLine{
+				{WalletId: wallet.ID, Credit: amount},
+				{Account: OpeningBalanceAccount, Debit: amount},
+			},
+		})
+	})
+	wallet.BalanceAmount = opening
+	return err
+}
+
+func (j Journal) validate() error {
+	if j.ID == "" {
+		return fmt.Errorf("%w: missing id", ErrInvalidJournal)
+	}
+	if len(j.Lines) < 2 {
+		return fmt.Errorf("%w %s: needs at least two lines", ErrInvalidJournal, j.ID)
+	}
+
+	// what was debited minus what was credited, per currency
+	balances := map[string]money.Money{}
+	var currencies []string
+	for i, line := range j.Lines {
+		if (line.WalletId == "") == (line.Account == "") {
+			return fmt.Errorf("%w %s: line %d needs either a wallet or an account", ErrInvalidJournal, j.ID, i)
+		}
+		if line.Debit.IsNegative() || line.Credit.IsNegative() || line.Debit.IsZero() == line.Credit.IsZero() {
+			return fmt.Errorf("%w %s: line %d needs either a positive debit or a positive credit", ErrInvalidJournal, j.ID, i)
+		}
+		currency := line.currency()
+		if !money.Known(currency) {
+			return fmt.Errorf("%w %s: line %d: %w %q", ErrInvalidJournal, j.ID, i, money.ErrUnknownCurrency, currency)
+		}
+
+		balance, ok := balances[currency]
+		if !ok {
+			balance = money.New(0, currency)
+			currencies = append(currencies, currency)
+		}
+		var err error
+		if line.Debit.IsZero() {
+			balance, err = balance.Sub(line.Credit)
+		} else {
+			balance, err = balance.Add(line.Debit)
+		}
+		if err != nil {
+			return fmt.Errorf("%w %s: %w", ErrInvalidJournal, j.ID, err)
+		}
+		balances[currency] = balance
+	}
+	for _, currency := range currencies {
+		if balance := balances[currency]; !balance.IsZero() {
+			return fmt.Errorf("%w: %s debits exceed credits by %s", ErrUnbalancedJournal, j.ID, balance)
+		}
+	}
+	return nil
+}
+
+// currency is the currency of the line's debit or credit.
+func (l JournalLine) currency() string {
+	if l.Debit.IsZero() {
+		return l.Credit.Currency
+	}
+	return l.Debit.Currency
+}
+
+// BalanceMismatch is a wallet whose stored balance differs from its ledger.
+type BalanceMismatch struct {
+	WalletId      string
+	Balance       int64
+	LedgerBalance int64
+}
+
+// Reconciliation is what Reconcile found; it is empty when the wallets and
+// the ledger agree.
+type Reconciliation struct {
+	Mismatches         []BalanceMismatch
+	UnbalancedJournals []string
+}
+
+func (r Reconciliation) Clean() bool {
+	return len(r.Mismatches) == 0 && len(r.UnbalancedJournals) == 0
+}
+
+// Reconcile recomputes every wallet balance from the ledger and reports the
+// wallets that disagree, along with journals that do not balance in every
+// currency. Entries in another currency than their wallet's do not count
+// toward its balance.
+func (s *LedgerService) Reconcile(ctx context.Context) (Reconciliation, error) {
+	var report Reconciliation
+	db := s.uow.DB().WithContext(ctx)
+
+	err := db.Model(&Wallet{}).
+		Select("wallets.id as wallet_id", "wallets.balance", "coalesce(sum(ledger_entries.credit - ledger_entries.debit), 0) as ledger_balance").
+		Joins("left join ledger_entries on ledger_entries.wallet_id = wallets.id and ledger_entries.currency = wallets.currency").
+		Group("wallets.id, wallets.balance").
+		Having("wallets.balance <> coalesce(sum(ledger_entries.credit - ledger_entries.debit), 0)").
+		Order("wallets.id").
+		Scan(&report.Mismatches).Error
+	if err != nil {
+		return report, err
+	}
+
+	var unbalanced []string
+	err = db.Model(&LedgerEntry{}).
+		Group("journal_id, currency").
+		Having("sum(debit) <> sum(credit)").
+		Order("journal_id").
+		Pluck("journal_id", &unbalanced).Error
+	for _, journalID := range unbalanced {
+		// a journal off in two currencies is listed once
+		if n := len(report.UnbalancedJournals); n == 0 || report.UnbalancedJournals[n-1] != journalID {
+			report.UnbalancedJournals = append(report.UnbalancedJournals, journalID)
+		}
+	}
+	return report, err
+}
@@ -0,0 +1,280 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"golang-gorm/money"
+)
+
+func TestLedgerPost(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	err := ledger.Post(ctx, Journal{
+		ID:          "deposit:1",
+		Description: "cash deposit",
+		Lines: []JournalLine{
+			{Account: "cash", Debit: money.New(250000, "IDR")},
+			{WalletId: "1", Credit: money.New(200000, "IDR")},
+			{WalletId: "2", Credit: money.New(50000, "IDR")},
+		},
+	})
+	assert.Nil(t, err)
+
+	wallets := NewWalletRepository(db)
+	wallet, err := wallets.FindByID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1200000), wallet.BalanceAmount)
+	wallet, err = wallets.FindByID(ctx, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5050000), wallet.BalanceAmount)
+
+	var entries []LedgerEntry
+	assert.Nil(t, db.Where("journal_id = ?", "deposit:1").Order("id").Find(&entries).Error)
+	assert.Len(t, entries, 3)
+	for _, entry := range entries {
+		assert.Equal(t, "IDR", entry.Currency)
+	}
+
+	for _, id := range []string{"deposit:1", "opening:1"} {
+		err = ledger.Post(ctx, Journal{
+			ID:    id,
+			Lines: []JournalLine{{Account: "cash", Debit: money.New(1, "IDR")}, {WalletId: "1", Credit: money.New(1, "IDR")}},
+		})
+		assert.True(t, errors.Is(err, ErrDuplicateJournal), id)
+	}
+
+	report, err := ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report)
+}
+
+func TestLedgerPostInvalid(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	err := ledger.Post(ctx, Journal{
+		ID:    "bad:1",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "IDR")}, {WalletId: "1", Credit: money.New(99, "IDR")}},
+	})
+	assert.True(t, errors.Is(err, ErrUnbalancedJournal))
+
+	err = ledger.Post(ctx, Journal{
+		ID:    "bad:2",
+		Lines: []JournalLine{{Account: "cash", WalletId: "1", Debit: money.New(100, "IDR")}, {WalletId: "2", Credit: money.New(100, "IDR")}},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidJournal))
+
+	err = ledger.Post(ctx, Journal{
+		ID:    "bad:3",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "IDR")}, {WalletId: "99", Credit: money.New(100, "IDR")}},
+	})
+	assert.NotNil(t, err)
+
+	// the same number in two currencies does not balance
+	err = ledger.Post(ctx, Journal{
+		ID:    "bad:4",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "USD")}, {WalletId: "1", Credit: money.New(100, "IDR")}},
+	})
+	assert.True(t, errors.Is(err, ErrUnbalancedJournal), err)
+
+	// wallet 1 holds rupiah
+	err = ledger.Post(ctx, Journal{
+		ID:    "bad:5",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "USD")}, {WalletId: "1", Credit: money.New(100, "USD")}},
+	})
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch), err)
+
+	err = ledger.Post(ctx, Journal{
+		ID:    "bad:6",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "XXX")}, {Account: "bank", Credit: money.New(100, "XXX")}},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidJournal), err)
+
+	var count int64
+	err = db.Model(&LedgerEntry{}).Where("journal_id like ?", "bad:%").Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	_, err := NewTransferService(db).Transfer(ctx, "2", "3", 1000000, "order-1")
+	assert.Nil(t, err)
+
+	report, err := ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report)
+
+	// Save cannot overwrite the balance the ledger keeps
+	wallet, err := NewWalletRepository(db).FindByID(ctx, "3")
+	assert.Nil(t, err)
+	wallet.BalanceAmount = 0
+	assert.Nil(t, db.Save(wallet).Error)
+	report, err = ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report)
+
+	err = db.Exec("update wallets set balance = ? where id = ?", 0, "3").Error
+	assert.Nil(t, err)
+	err = db.Create(&LedgerEntry{JournalId: "broken", Account: "cash", Debit: 10}).Error
+	assert.Nil(t, err)
+
+	report, err = ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []BalanceMismatch{{WalletId: "3", Balance: 0, LedgerBalance: 4000000}}, report.Mismatches)
+	assert.Equal(t, []string{"broken"}, report.UnbalancedJournals)
+
+	// the same number in two currencies balances neither, and a wallet
+	// entry in another currency than its wallet's does not count
+	walletID := "4"
+	err = db.Create(&[]LedgerEntry{
+		{JournalId: "mixed", WalletId: &walletID, Credit: 100, Currency: "USD"},
+		{JournalId: "mixed", Account: "cash", Debit: 100, Currency: "IDR"},
+	}).Error
+	assert.Nil(t, err)
+	report, err = ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []BalanceMismatch{{WalletId: "3", Balance: 0, LedgerBalance: 4000000}}, report.Mismatches)
+	assert.Equal(t, []string{"broken", "mixed"}, report.UnbalancedJournals)
+}
+
+func TestLedgerPostConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newConcurrentTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	// the same journal posted by several transactions at once goes
+	// through exactly once
+	var wg sync.WaitGroup
+	var posted, duplicates int64
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := ledger.Post(ctx, Journal{
+				ID:    "deposit:1",
+				Lines: []JournalLine{{Account: "cash", Debit: money.New(100, "IDR")}, {WalletId: "1", Credit: money.New(100, "IDR")}},
+			})
+			switch {
+			case err == nil:
+				atomic.AddInt64(&posted, 1)
+			case errors.Is(err, ErrDuplicateJournal):
+				atomic.AddInt64(&duplicates, 1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), posted)
+	assert.Equal(t, int64(7), duplicates)
+
+	wallet, err := NewWalletRepository(db).FindByID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000100), wallet.BalanceAmount)
+	report, err := ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, report.Clean(), report)
+}
+
+func TestAddLedgerCurrenciesMigration(t *testing.T) {
+	t.Parallel()
+	db := newLegacyTestDB(t, 14)
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, row := range []struct {
+		table  string
+		values map[string]interface{}
+	}{
+		{"users", map[string]interface{}{"id": "legacy-1", "first_name": "Asep", "created_at": now, "updated_at": now}},
+		{"wallets", map[string]interface{}{"id": "rupiah", "user_id": "legacy-1", "balance": 0, "currency": "IDR", "created_at": now, "updated_at": now}},
+		{"wallets", map[string]interface{}{"id": "dollars", "user_id": "legacy-1", "balance": 0, "currency": "USD", "created_at": now, "updated_at": now}},
+		{"exchange_rates", map[string]interface{}{"id": 1, "base": "USD", "quote": "IDR", "rate": "15000", "effective_at": now, "created_at": now}},
+		{"conversions", map[string]interface{}{"idempotency_key": "c1", "from_wallet_id": "dollars", "to_wallet_id": "rupiah", "from_amount": 100, "from_currency": "USD", "to_amount": 15000, "to_currency": "IDR", "exchange_rate_id": 1, "rate": "15000", "created_at": now}},
+	} {
+		assert.Nil(t, db.Table(row.table).Create(row.values).Error, row.table)
+	}
+	for _, entry := range []map[string]interface{}{
+		{"journal_id": "opening:dollars", "wallet_id": "dollars", "credit": 500},
+		{"journal_id": "opening:dollars", "account": "opening_balance", "debit": 500},
+		{"journal_id": "opening:rupiah", "wallet_id": "rupiah", "credit": 500},
+		{"journal_id": "opening:rupiah", "account": "opening_balance", "debit": 500},
+		{"journal_id": "conversion:c1", "wallet_id": "dollars", "debit": 100},
+		{"journal_id": "conversion:c1", "account": "exchange", "credit": 100},
+		{"journal_id": "conversion:c1", "account": "exchange", "debit": 15000},
+		{"journal_id": "conversion:c1", "wallet_id": "rupiah", "credit": 15000},
+	} {
+		entry["description"], entry["created_at"] = "legacy", now
+		assert.Nil(t, db.Table("ledger_entries").Create(entry).Error)
+	}
+
+	migrator, err := migrate.New(db, migrations.All()...)
+	assert.Nil(t, err)
+	_, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	var currencies []string
+	assert.Nil(t, db.Model(&LedgerEntry{}).Order("id").Pluck("currency", &currencies).Error)
+	assert.Equal(t, []string{"USD", "USD", "IDR", "IDR", "USD", "USD", "IDR", "IDR"}, currencies)
+
+	var journals []string
+	assert.Nil(t, db.Model(&LedgerJournal{}).Order("id").Pluck("id", &journals).Error)
+	assert.Equal(t, []string{"conversion:c1", "opening:dollars", "opening:rupiah"}, journals)
+
+	err = NewLedgerService(db).Post(ctx, Journal{
+		ID:    "opening:rupiah",
+		Lines: []JournalLine{{Account: "cash", Debit: money.New(1, "IDR")}, {WalletId: "rupiah", Credit: money.New(1, "IDR")}},
+	})
+	assert.True(t, errors.Is(err, ErrDuplicateJournal), err)
+}
+
+func TestLedgerOpenWallet(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	wallet := &Wallet{UserId: "5", BalanceAmount: 750000}
+	assert.Nil(t, ledger.OpenWallet(ctx, wallet))
+	assert.Equal(t, int64(750000), wallet.BalanceAmount)
+
+	stored, err := NewWalletRepository(db).FindByUserAndCurrency(ctx, "5", DefaultCurrency)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(750000), stored.BalanceAmount)
+
+	empty := &Wallet{UserId: "6"}
+	assert.Nil(t, ledger.OpenWallet(ctx, empty))
+	assert.Equal(t, money.New(0, DefaultCurrency), empty.Balance())
+
+	err = ledger.OpenWallet(ctx, &Wallet{UserId: "8", Currency: "XXX"})
+	assert.True(t, errors.Is(err, money.ErrUnknownCurrency))
+
+	reconciliation, err := ledger.Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, reconciliation.Clean())
+
+	err = ledger.OpenWallet(ctx, &Wallet{UserId: "7", BalanceAmount: -1})
+	assert.True(t, errors.Is(err, ErrNegativeBalance))
+	exists, err := NewWalletRepository(db).Count(ctx, Where("user_id = ?", "7"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+}
@@ -0,0 +1,31 @@
+package golang_gorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserLikeProduct is a row of the user_like_product join table behind
+// User.LikeProducts and Product.LikeByUsers.
+type UserLikeProduct struct {
+	ProductId string    `gorm:"primaryKey;column:product_id"`
+	UserId    string    `gorm:"primaryKey;column:user_id"`
+	LikedAt   time.Time `gorm:"column:liked_at;autoCreateTime"`
+	Product   *Product  `gorm:"foreignKey:product_id;references:id"`
+	User      *User     `gorm:"foreignKey:user_id;references:id"`
+}
+
+func (l *UserLikeProduct) TableName() string {
+	return "user_like_product"
+}
+
+// setupJoinTables makes the many2many associations go through
+// UserLikeProduct, so likes added with Association().Append get a liked_at
+// as well.
+func setupJoinTables(db *gorm.DB) error {
+	if err := db.SetupJoinTable(&User{}, "LikeProducts", &UserLikeProduct{}); err != nil {
+		return err
+	}
+	return db.SetupJoinTable(&Product{}, "LikeByUsers", &UserLikeProduct{})
+}
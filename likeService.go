@@ -0,0 +1,127 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrProductNotFound = errors.New("product not found")
+)
+
+// LikeService manages which users like which products. Liking twice or
+// unliking a product that is not liked is not an error, so clients can
+// retry freely.
+type LikeService struct {
+	uow *UnitOfWork
+}
+
+func NewLikeService(db *gorm.DB) *LikeService {
+	return &LikeService{uow: NewUnitOfWork(db)}
+}
+
+// Like records that the user likes the product and reports whether it is
+// a new like; liking again keeps the original liked_at.
+func (s *LikeService) Like(ctx context.Context, userID, productID string) (bool, error) {
+	var liked bool
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		if exists, err := uow.Users().Exists(ctx, userID); err != nil {
+			return err
+		} else if !exists {
+			return ErrUserNotFound
+		}
+		if exists, err := uow.Products().Exists(ctx, productID); err != nil {
+			return err
+		} else if !exists {
+			return ErrProductNotFound
+		}
+
+		result := uow.DB().WithContext(ctx).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&UserLikeProduct{UserId: userID, ProductId: productID})
+		liked = result.RowsAffected > 0
+		return result.Error
+	})
+	return liked, err
+}
+
+// Unlike removes the like and reports whether there was one.
+func (s *LikeService) Unlike(ctx context.Context, userID, productID string) (bool, error) {
+	result := s.uow.DB().WithContext(ctx).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Delete(&UserLikeProduct{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *LikeService) IsLiked(ctx context.Context, userID, productID string) (bool, error) {
+	var count int64
+	err := s.uow.DB().WithContext(ctx).Model(&UserLikeProduct{}).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListLikedProducts pages through the user's likes, latest first, with
+// Product loaded.
+func (s *LikeService) ListLikedProducts(ctx context.Context, userID string, params pagination.Params) (pagination.Page[UserLikeProduct], error) {
+	db := s.uow.DB().WithContext(ctx).Where("user_id = ?", userID).Preload("Product")
+	return pagination.Keyset[UserLikeProduct](db, params,
+		pagination.Key{Column: "liked_at", Desc: true},
+		pagination.Key{Column: "product_id", Desc: true},
+	)
+}
+
+// ListLikers pages through the likes of the product, latest first, with
+// User loaded.
+func (s *LikeService) ListLikers(ctx context.Context, productID string, params pagination.Params) (pagination.Page[UserLikeProduct], error) {
+	db := s.uow.DB().WithContext(ctx).Where("product_id = ?", productID).Preload("User")
+	return pagination.Keyset[UserLikeProduct](db, params,
+		pagination.Key{Column: "liked_at", Desc: true},
+		pagination.Key{Column: "user_id", Desc: true},
+	)
+}
+
+// LikeCount is the number of users who like the product.
+func (s *LikeService) LikeCount(ctx context.Context, productID string) (int64, error) {
+	var count int64
+	err := s.uow.DB().WithContext(ctx).Model(&UserLikeProduct{}).Where("product_id = ?", productID).Count(&count).Error
+	return count, err
+}
+
+// LikeCounts is LikeCount for several products at once. Products nobody
+// likes are in the map with 0.
+func (s *LikeService) LikeCounts(ctx context.Context, productIDs ...string) (map[string]int64, error) {
+	var rows []struct {
+		ProductId string
+		Likes     int64
+	}
+	err := s.uow.DB().WithContext(ctx).Model(&UserLikeProduct{}).
+		Select("product_id, count(*) as likes").
+		Where("product_id IN ?", productIDs).
+		Group("product_id").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(productIDs))
+	for _, productID := range productIDs {
+		counts[productID] = 0
+	}
+	for _, row := range rows {
+		counts[row.ProductId] = row.Likes
+	}
+	return counts, nil
+}
+
+// LikedCount is the number of products the user likes.
+func (s *LikeService) LikedCount(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := s.uow.DB().WithContext(ctx).Model(&UserLikeProduct{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
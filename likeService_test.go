@@ -0,0 +1,113 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/pagination"
+)
+
+func TestLikeService(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	likes := NewLikeService(db)
+
+	liked, err := likes.Like(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.True(t, liked)
+
+	var first UserLikeProduct
+	assert.Nil(t, db.Take(&first, "user_id = ? AND product_id = ?", "3", "P002").Error)
+	assert.False(t, first.LikedAt.IsZero())
+
+	// liking again changes nothing
+	liked, err = likes.Like(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.False(t, liked)
+	var again UserLikeProduct
+	assert.Nil(t, db.Take(&again, "user_id = ? AND product_id = ?", "3", "P002").Error)
+	assert.True(t, first.LikedAt.Equal(again.LikedAt))
+
+	isLiked, err := likes.IsLiked(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.True(t, isLiked)
+
+	unliked, err := likes.Unlike(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.True(t, unliked)
+	unliked, err = likes.Unlike(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.False(t, unliked)
+
+	isLiked, err = likes.IsLiked(ctx, "3", "P002")
+	assert.Nil(t, err)
+	assert.False(t, isLiked)
+
+	_, err = likes.Like(ctx, "404", "P002")
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+	_, err = likes.Like(ctx, "3", "P404")
+	assert.True(t, errors.Is(err, ErrProductNotFound))
+}
+
+func TestLikeServiceLists(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	likes := NewLikeService(db)
+
+	for _, userID := range []string{"3", "4", "5"} {
+		_, err := likes.Like(ctx, userID, "P001")
+		assert.Nil(t, err)
+	}
+	_, err := likes.Like(ctx, "1", "P002")
+	assert.Nil(t, err)
+
+	page, err := likes.ListLikers(ctx, "P001", pagination.Params{Limit: 3})
+	assert.Nil(t, err)
+	assert.Len(t, page.Items, 3)
+	assert.True(t, page.HasNext)
+	// the likes just made come before the fixture likes from 2024
+	assert.Equal(t, "User 5", page.Items[0].User.Name.FirstName)
+
+	page, err = likes.ListLikers(ctx, "P001", pagination.Params{Limit: 3, Cursor: page.NextCursor})
+	assert.Nil(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, "2", page.Items[0].UserId)
+	assert.Equal(t, "1", page.Items[1].UserId)
+	assert.Equal(t, time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC), page.Items[1].LikedAt.UTC())
+
+	products, err := likes.ListLikedProducts(ctx, "1", pagination.Params{})
+	assert.Nil(t, err)
+	assert.Len(t, products.Items, 2)
+	assert.Equal(t, "Orange", products.Items[0].Product.Name)
+	assert.Equal(t, "Apple", products.Items[1].Product.Name)
+
+	count, err := likes.LikeCount(ctx, "P001")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), count)
+
+	counts, err := likes.LikeCounts(ctx, "P001", "P002", "P404")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"P001": 5, "P002": 1, "P404": 0}, counts)
+
+	count, err = likes.LikedCount(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestAssociationAppendSetsLikedAt(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+
+	product := Product{ID: "P002"}
+	user := User{ID: "6"}
+	assert.Nil(t, db.Model(&product).Association("LikeByUsers").Append(&user))
+
+	var like UserLikeProduct
+	assert.Nil(t, db.Take(&like, "user_id = ? AND product_id = ?", "6", "P002").Error)
+	assert.WithinDuration(t, time.Now(), like.LikedAt, time.Minute)
+}
@@ -0,0 +1,157 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(t, err)
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+var files = fstest.MapFS{
+	"sql/0001_create_books.up.sql":   {Data: []byte("-- books\nCREATE TABLE books (\n  id integer primary key,\n  title text\n);\nINSERT INTO books (id, title) VALUES (1, 'Belajar GORM');\n")},
+	"sql/0001_create_books.down.sql": {Data: []byte("DROP TABLE books;\n")},
+	"sql/0002_add_author.up.sql":     {Data: []byte("ALTER TABLE books ADD COLUMN author text;\n")},
+	"sql/0002_add_author.down.sql":   {Data: []byte("ALTER TABLE books DROP COLUMN author;\n")},
+	"sql/README.md":                  {Data: []byte("ignored")},
+}
+
+func newMigrator(t *testing.T, db *gorm.DB) *Migrator {
+	migrations, err := LoadSQL(files, "sql")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(migrations))
+
+	migrations = append(migrations, Migration{
+		Version: 3,
+		Name:    "create_readers",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE readers (id integer primary key)").Error
+		},
+	})
+
+	migrator, err := New(db, migrations...)
+	assert.Nil(t, err)
+	return migrator
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	migrator := newMigrator(t, db)
+
+	done, err := migrator.Up(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(done))
+	assert.True(t, db.Migrator().HasColumn("books", "author"))
+	assert.True(t, db.Migrator().HasTable("readers"))
+
+	done, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(done))
+
+	_, err = migrator.Down(ctx, 1)
+	assert.ErrorIs(t, err, ErrNoDown)
+
+	db.Exec("DELETE FROM schema_migrations WHERE version = 3")
+	done, err = migrator.Down(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), done[0].Version)
+	assert.False(t, db.Migrator().HasColumn("books", "author"))
+
+	statuses, err := migrator.Status(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(statuses))
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+
+	done, err = migrator.Down(ctx, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(done))
+	assert.False(t, db.Migrator().HasTable("books"))
+
+	_, err = migrator.Down(ctx, 0)
+	assert.ErrorIs(t, err, ErrInvalidSteps)
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	migrator, err := New(db, Migration{
+		Version: 1,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE half (id integer)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("THIS IS NOT SQL").Error
+		},
+	})
+	assert.Nil(t, err)
+
+	_, err = migrator.Up(ctx)
+	assert.NotNil(t, err)
+	assert.False(t, db.Migrator().HasTable("half"))
+
+	pending, err := migrator.Pending(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pending))
+}
+
+func TestStatusMissing(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	migrator := newMigrator(t, db)
+
+	_, err := migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	short, err := New(db, Migration{Version: 1, Name: "create_books", Up: func(*gorm.DB) error { return nil }})
+	assert.Nil(t, err)
+	statuses, err := short.Status(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(statuses))
+	assert.False(t, statuses[0].Missing)
+	assert.True(t, statuses[2].Missing)
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	migrator := newMigrator(t, db)
+
+	var out bytes.Buffer
+	err := migrator.DryRun(ctx, &out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "-- 1 create_books\nCREATE TABLE books")
+	assert.Contains(t, out.String(), "-- 3 create_readers\nCREATE TABLE readers (id integer primary key);")
+	assert.False(t, db.Migrator().HasTable("books"))
+}
+
+func TestNewRejectsDuplicates(t *testing.T) {
+	up := func(*gorm.DB) error { return nil }
+	_, err := New(nil, Migration{Version: 1, Name: "a", Up: up}, Migration{Version: 1, Name: "b", Up: up})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	_, err = New(nil, Migration{Version: 1, Name: "a"})
+	assert.NotNil(t, err)
+}
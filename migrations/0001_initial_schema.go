@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// The snapshot types below keep the Go names of the models they were taken
+// from because GORM derives foreign key names from them.
+
+type User struct {
+	ID         string    `gorm:"primaryKey;column:id"`
+	Password   string    `gorm:"column:password"`
+	FirstName  string    `gorm:"column:first_name"`
+	MiddleName string    `gorm:"column:middle_name"`
+	LastName   string    `gorm:"column:last_name"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+	Wallet     Wallet    `gorm:"foreignKey:user_id;references:id"`
+	Addresses  []Address `gorm:"foreignKey:user_id;references:id"`
+}
+
+type UserLog struct {
+	ID        int    `gorm:"primaryKey;column:id;autoIncrement"`
+	UserId    string `gorm:"column:user_id"`
+	Action    string `gorm:"column:action"`
+	CreatedAt int64  `gorm:"column:created_at"`
+}
+
+type Wallet struct {
+	ID        string    `gorm:"primaryKey;column:id"`
+	UserId    string    `gorm:"column:user_id"`
+	Balance   int64     `gorm:"column:balance"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+type Address struct {
+	ID        int64     `gorm:"primaryKey;column:id;autoIncrement"`
+	UserId    string    `gorm:"column:user_id"`
+	Address   string    `gorm:"column:address"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+type Product struct {
+	ID        string    `gorm:"primaryKey;column:id"`
+	Name      string    `gorm:"column:name"`
+	Price     int64     `gorm:"column:price"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+type UserLikeProduct struct {
+	ProductId string  `gorm:"primaryKey;column:product_id"`
+	UserId    string  `gorm:"primaryKey;column:user_id"`
+	Product   Product `gorm:"foreignKey:product_id;references:id"`
+	User      User    `gorm:"foreignKey:user_id;references:id"`
+}
+
+type Todo struct {
+	gorm.Model
+	UserId      string `gorm:"column:user_id"`
+	Title       string `gorm:"column:title"`
+	Description string `gorm:"column:description"`
+}
+
+type GuestBook struct {
+	ID        int64     `gorm:"primaryKey;column:id;autoIncrement"`
+	Name      string    `gorm:"column:name"`
+	Email     string    `gorm:"column:email"`
+	Message   string    `gorm:"column:message"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+var initialSchema = migrate.Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		err := tx.Migrator().CreateTable(&User{}, &UserLog{}, &Wallet{}, &Address{}, &Product{})
+		if err != nil {
+			return err
+		}
+		err = tx.Table("user_like_product").Migrator().CreateTable(&UserLikeProduct{})
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().CreateTable(&Todo{}, &GuestBook{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			&GuestBook{},
+			&Todo{},
+			"user_like_product",
+			&Product{},
+			&Address{},
+			&Wallet{},
+			&UserLog{},
+			&User{},
+		)
+	},
+}
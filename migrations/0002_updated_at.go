@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// Until this migration UpdatedAt was mapped onto created_at in every model
+// but Todo, so these tables never had an updated_at column.
+
+type updatedAt struct {
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+type updatedAtMilli struct {
+	UpdatedAt int64 `gorm:"column:updated_at"`
+}
+
+var updatedAtTables = []string{"users", "wallets", "addresses", "products", "guest_books"}
+
+var addUpdatedAt = migrate.Migration{
+	Version: 2,
+	Name:    "add_updated_at",
+	Up: func(tx *gorm.DB) error {
+		for _, table := range updatedAtTables {
+			if err := tx.Table(table).Migrator().AddColumn(&updatedAt{}, "UpdatedAt"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Table("user_logs").Migrator().AddColumn(&updatedAtMilli{}, "UpdatedAt"); err != nil {
+			return err
+		}
+
+		for _, table := range append(updatedAtTables, "user_logs") {
+			err := tx.Table(table).Session(&gorm.Session{AllowGlobalUpdate: true}).
+				Update("updated_at", gorm.Expr("created_at")).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Table("user_logs").Migrator().DropColumn(&updatedAtMilli{}, "UpdatedAt"); err != nil {
+			return err
+		}
+		for _, table := range updatedAtTables {
+			if err := tx.Table(table).Migrator().DropColumn(&updatedAt{}, "UpdatedAt"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+type Transfer struct {
+	ID             int64     `gorm:"primaryKey;column:id;autoIncrement"`
+	IdempotencyKey string    `gorm:"column:idempotency_key;size:100;uniqueIndex"`
+	FromWalletId   string    `gorm:"column:from_wallet_id"`
+	ToWalletId     string    `gorm:"column:to_wallet_id"`
+	Amount         int64     `gorm:"column:amount"`
+	CreatedAt      time.Time `gorm:"column:created_at"`
+	FromWallet     *Wallet   `gorm:"foreignKey:from_wallet_id;references:id"`
+	ToWallet       *Wallet   `gorm:"foreignKey:to_wallet_id;references:id"`
+}
+
+var createTransfers = migrate.Migration{
+	Version: 3,
+	Name:    "create_transfers",
+	Up: func(tx *gorm.DB) error {
+		return tx.Migrator().CreateTable(&Transfer{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&Transfer{})
+	},
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+type LedgerEntry struct {
+	ID          int64     `gorm:"primaryKey;column:id;autoIncrement"`
+	JournalId   string    `gorm:"column:journal_id;size:100;index"`
+	WalletId    *string   `gorm:"column:wallet_id;index"`
+	Account     string    `gorm:"column:account;size:100"`
+	Debit       int64     `gorm:"column:debit;not null;default:0"`
+	Credit      int64     `gorm:"column:credit;not null;default:0"`
+	Description string    `gorm:"column:description"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	Wallet      *Wallet   `gorm:"foreignKey:wallet_id;references:id"`
+}
+
+// openingBalanceAccount is the account the balances wallets already had
+// before the ledger existed are booked against.
+const openingBalanceAccount = "opening_balance"
+
+var createLedgerEntries = migrate.Migration{
+	Version: 4,
+	Name:    "create_ledger_entries",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&LedgerEntry{}); err != nil {
+			return err
+		}
+
+		var wallets []Wallet
+		if err := tx.Where("balance <> ?", 0).Order("id").Find(&wallets).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, wallet := range wallets {
+			journalID := "opening:" + wallet.ID
+			walletID := wallet.ID
+			entries := []LedgerEntry{
+				{JournalId: journalID, WalletId: &walletID, CreatedAt: now, Description: "opening balance"},
+				{JournalId: journalID, Account: openingBalanceAccount, CreatedAt: now, Description: "opening balance"},
+			}
+			if wallet.Balance > 0 {
+				entries[0].Credit, entries[1].Debit = wallet.Balance, wallet.Balance
+			} else {
+				entries[0].Debit, entries[1].Credit = -wallet.Balance, -wallet.Balance
+			}
+			if err := tx.Create(&entries).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&LedgerEntry{})
+	},
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"golang-gorm/migrate"
+	"golang-gorm/password"
+	"gorm.io/gorm"
+)
+
+type userPassword struct {
+	ID       string `gorm:"primaryKey;column:id"`
+	Password string `gorm:"column:password"`
+}
+
+// Passwords used to be stored as typed. This hashes them with bcrypt at the
+// default cost; Authenticate moves them to a different PasswordHasher later.
+var hashPasswords = migrate.Migration{
+	Version: 5,
+	Name:    "hash_passwords",
+	Up: func(tx *gorm.DB) error {
+		var users []userPassword
+		err := tx.Table("users").Where("password <> ?", "").FindInBatches(&users, 100, func(batch *gorm.DB, _ int) error {
+			for _, user := range users {
+				if password.IsHash(user.Password) {
+					continue
+				}
+				hash, err := password.Bcrypt{}.Hash(user.Password)
+				if err != nil {
+					return err
+				}
+				err = tx.Table("users").Where("id = ?", user.ID).Update("password", hash).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+		return err
+	},
+	// the hashes keep working, there is nothing to revert
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// auditLog adds the columns the audit plugin fills to UserLog.
+type auditLog struct {
+	Table    string `gorm:"column:table_name;size:100;index:idx_user_logs_record"`
+	RecordId string `gorm:"column:record_id;size:191;index:idx_user_logs_record"`
+	Changes  string `gorm:"column:changes;type:text"`
+}
+
+var auditLogColumns = []string{"Table", "RecordId", "Changes"}
+
+var addAuditLog = migrate.Migration{
+	Version: 6,
+	Name:    "add_audit_log",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("user_logs").Migrator()
+		for _, field := range auditLogColumns {
+			if err := migrator.AddColumn(&auditLog{}, field); err != nil {
+				return err
+			}
+		}
+		return migrator.CreateIndex(&auditLog{}, "idx_user_logs_record")
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Table("user_logs").Migrator()
+		if err := migrator.DropIndex(&auditLog{}, "idx_user_logs_record"); err != nil {
+			return err
+		}
+		for _, field := range auditLogColumns {
+			if err := migrator.DropColumn(&auditLog{}, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
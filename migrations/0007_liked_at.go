@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// likedAt is when a user liked a product. Likes made before this migration
+// get the time it ran.
+type likedAt struct {
+	LikedAt time.Time `gorm:"column:liked_at"`
+}
+
+var addLikedAt = migrate.Migration{
+	Version: 7,
+	Name:    "add_liked_at",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Table("user_like_product").Migrator().AddColumn(&likedAt{}, "LikedAt"); err != nil {
+			return err
+		}
+		return tx.Table("user_like_product").Session(&gorm.Session{AllowGlobalUpdate: true}).
+			Update("liked_at", tx.NowFunc()).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Table("user_like_product").Migrator().DropColumn(&likedAt{}, "LikedAt")
+	},
+}
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// todoDomain adds workflow and ordering columns to todos.
+type todoDomain struct {
+	Status   string     `gorm:"column:status;size:20;not null;default:open"`
+	Priority int        `gorm:"column:priority;not null;default:0"`
+	DueAt    *time.Time `gorm:"column:due_at;index:idx_todos_due_at"`
+	Position int64      `gorm:"column:position;not null;default:0"`
+}
+
+var todoDomainColumns = []string{"Status", "Priority", "DueAt", "Position"}
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey;column:id;autoIncrement"`
+	UserId    string    `gorm:"column:user_id;size:191;not null;uniqueIndex:idx_tags_user_name"`
+	Name      string    `gorm:"column:name;size:50;not null;uniqueIndex:idx_tags_user_name"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+type TodoTag struct {
+	TodoId uint `gorm:"primaryKey;column:todo_id"`
+	TagId  uint `gorm:"primaryKey;column:tag_id"`
+	Todo   Todo `gorm:"foreignKey:todo_id;references:id"`
+	Tag    Tag  `gorm:"foreignKey:tag_id;references:id"`
+}
+
+var addTodoDomain = migrate.Migration{
+	Version: 8,
+	Name:    "add_todo_domain",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("todos").Migrator()
+		for _, field := range todoDomainColumns {
+			if err := migrator.AddColumn(&todoDomain{}, field); err != nil {
+				return err
+			}
+		}
+		if err := migrator.CreateIndex(&todoDomain{}, "idx_todos_due_at"); err != nil {
+			return err
+		}
+
+		// number every user's todos in the order they were created
+		var todos []struct {
+			ID     uint
+			UserId string
+		}
+		err := tx.Table("todos").Select("id", "user_id").Order("user_id, created_at, id").Find(&todos).Error
+		if err != nil {
+			return err
+		}
+		position := map[string]int64{}
+		for _, todo := range todos {
+			position[todo.UserId]++
+			err := tx.Table("todos").Where("id = ?", todo.ID).Update("position", position[todo.UserId]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Migrator().CreateTable(&Tag{}); err != nil {
+			return err
+		}
+		return tx.Table("todo_tags").Migrator().CreateTable(&TodoTag{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable("todo_tags", &Tag{}); err != nil {
+			return err
+		}
+		migrator := tx.Table("todos").Migrator()
+		if err := migrator.DropIndex(&todoDomain{}, "idx_todos_due_at"); err != nil {
+			return err
+		}
+		for _, field := range todoDomainColumns {
+			if err := migrator.DropColumn(&todoDomain{}, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// guestBookModeration adds the moderation columns to guest_books. Entries
+// written before it were shown without moderation, so they start out
+// approved.
+type guestBookModeration struct {
+	Status      string     `gorm:"column:status;size:20;not null;default:pending;index:idx_guest_books_status,priority:1"`
+	SpamReason  string     `gorm:"column:spam_reason;size:255"`
+	ModeratedBy string     `gorm:"column:moderated_by;size:191"`
+	ModeratedAt *time.Time `gorm:"column:moderated_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;index:idx_guest_books_status,priority:2"`
+}
+
+var guestBookModerationColumns = []string{"Status", "SpamReason", "ModeratedBy", "ModeratedAt"}
+
+var addGuestBookModeration = migrate.Migration{
+	Version: 9,
+	Name:    "add_guest_book_moderation",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("guest_books").Migrator()
+		for _, field := range guestBookModerationColumns {
+			if err := migrator.AddColumn(&guestBookModeration{}, field); err != nil {
+				return err
+			}
+		}
+		if err := migrator.CreateIndex(&guestBookModeration{}, "idx_guest_books_status"); err != nil {
+			return err
+		}
+		return tx.Table("guest_books").Session(&gorm.Session{AllowGlobalUpdate: true}).
+			Update("status", "approved").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Table("guest_books").Migrator()
+		if err := migrator.DropIndex(&guestBookModeration{}, "idx_guest_books_status"); err != nil {
+			return err
+		}
+		for _, field := range guestBookModerationColumns {
+			if err := migrator.DropColumn(&guestBookModeration{}, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
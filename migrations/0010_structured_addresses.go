@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// structuredAddress splits addresses into their parts. The free-text
+// address becomes the street line and, until its user fills in the parts,
+// the city too, so existing rows can still be searched by city and
+// geocoded. The country so far has always been Indonesia. Each user's
+// oldest address becomes their primary one.
+type structuredAddress struct {
+	ID         int64  `gorm:"column:id"`
+	UserId     string `gorm:"column:user_id"`
+	Label      string `gorm:"column:label;size:50"`
+	City       string `gorm:"column:city;size:100;index:idx_addresses_city"`
+	Province   string `gorm:"column:province;size:100"`
+	PostalCode string `gorm:"column:postal_code;size:20"`
+	Country    string `gorm:"column:country;size:2"`
+	IsPrimary  bool   `gorm:"column:is_primary;not null;default:false"`
+}
+
+var structuredAddressColumns = []string{"Label", "City", "Province", "PostalCode", "Country", "IsPrimary"}
+
+var structureAddresses = migrate.Migration{
+	Version: 10,
+	Name:    "structure_addresses",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("addresses").Migrator()
+		if err := migrator.RenameColumn(&structuredAddress{}, "address", "street"); err != nil {
+			return err
+		}
+		for _, field := range structuredAddressColumns {
+			if err := migrator.AddColumn(&structuredAddress{}, field); err != nil {
+				return err
+			}
+		}
+		if err := migrator.CreateIndex(&structuredAddress{}, "idx_addresses_city"); err != nil {
+			return err
+		}
+
+		err := tx.Table("addresses").Session(&gorm.Session{AllowGlobalUpdate: true}).
+			Update("country", "ID").Error
+		if err != nil {
+			return err
+		}
+		err = tx.Table("addresses").Where("city IS NULL").
+			Update("city", gorm.Expr("substr(street, 1, 100)")).Error
+		if err != nil {
+			return err
+		}
+		var addresses []structuredAddress
+		err = tx.Table("addresses").Select("id", "user_id").Order("user_id, id").Find(&addresses).Error
+		if err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, address := range addresses {
+			if seen[address.UserId] {
+				continue
+			}
+			seen[address.UserId] = true
+			err := tx.Table("addresses").Where("id = ?", address.ID).Update("is_primary", true).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Table("addresses").Migrator()
+		if err := migrator.DropIndex(&structuredAddress{}, "idx_addresses_city"); err != nil {
+			return err
+		}
+		for _, field := range structuredAddressColumns {
+			if err := migrator.DropColumn(&structuredAddress{}, field); err != nil {
+				return err
+			}
+		}
+		return migrator.RenameColumn(&structuredAddress{}, "street", "address")
+	},
+}
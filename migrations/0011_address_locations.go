@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// addressLocation adds the coordinates of addresses. Existing rows start
+// out without them; the re-geocoding job fills them in.
+type addressLocation struct {
+	Latitude     *float64   `gorm:"column:latitude;index:idx_addresses_location,priority:1"`
+	Longitude    *float64   `gorm:"column:longitude;index:idx_addresses_location,priority:2"`
+	GeocodedAt   *time.Time `gorm:"column:geocoded_at"`
+	GeocodeError string     `gorm:"column:geocode_error;size:255"`
+}
+
+var addressLocationColumns = []string{"Latitude", "Longitude", "GeocodedAt", "GeocodeError"}
+
+var addAddressLocations = migrate.Migration{
+	Version: 11,
+	Name:    "add_address_locations",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("addresses").Migrator()
+		for _, field := range addressLocationColumns {
+			if err := migrator.AddColumn(&addressLocation{}, field); err != nil {
+				return err
+			}
+		}
+		return migrator.CreateIndex(&addressLocation{}, "idx_addresses_location")
+	},
+	// Down drops the columns with plain ALTER TABLE: the migrator's SQLite
+	// DropColumn rebuilds the table and loses idx_addresses_city on the way.
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Table("addresses").Migrator().DropIndex(&addressLocation{}, "idx_addresses_location"); err != nil {
+			return err
+		}
+		for _, column := range []string{"latitude", "longitude", "geocoded_at", "geocode_error"} {
+			err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "addresses"}, clause.Column{Name: column}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currency gives wallet balances and product prices their currency. Every
+// amount so far was in rupiah.
+type currency struct {
+	Currency string `gorm:"column:currency;size:3;not null;default:IDR"`
+}
+
+var addCurrencies = migrate.Migration{
+	Version: 12,
+	Name:    "add_currencies",
+	Up: func(tx *gorm.DB) error {
+		for _, table := range []string{"wallets", "products"} {
+			if err := tx.Table(table).Migrator().AddColumn(&currency{}, "Currency"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// Down drops the columns with plain ALTER TABLE: the migrator's SQLite
+	// DropColumn rebuilds the table, which orphans the ledger lines,
+	// transfers and likes pointing at it.
+	Down: func(tx *gorm.DB) error {
+		for _, table := range []string{"wallets", "products"} {
+			err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: "currency"}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// walletOwner lets a user hold one wallet per currency rather than a
+// single wallet.
+type walletOwner struct {
+	UserId   string `gorm:"column:user_id;uniqueIndex:idx_wallets_user_currency"`
+	Currency string `gorm:"column:currency;size:3;not null;default:IDR;uniqueIndex:idx_wallets_user_currency"`
+}
+
+type ExchangeRate struct {
+	ID          int64     `gorm:"primaryKey;column:id;autoIncrement"`
+	Base        string    `gorm:"column:base;size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	Quote       string    `gorm:"column:quote;size:3;not null;uniqueIndex:idx_exchange_rates_pair"`
+	Rate        string    `gorm:"column:rate;type:varchar(40);not null"`
+	EffectiveAt time.Time `gorm:"column:effective_at;not null;uniqueIndex:idx_exchange_rates_pair"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+}
+
+type Conversion struct {
+	ID             int64         `gorm:"primaryKey;column:id;autoIncrement"`
+	IdempotencyKey string        `gorm:"column:idempotency_key;size:100;uniqueIndex"`
+	FromWalletId   string        `gorm:"column:from_wallet_id"`
+	ToWalletId     string        `gorm:"column:to_wallet_id"`
+	FromAmount     int64         `gorm:"column:from_amount"`
+	FromCurrency   string        `gorm:"column:from_currency;size:3"`
+	ToAmount       int64         `gorm:"column:to_amount"`
+	ToCurrency     string        `gorm:"column:to_currency;size:3"`
+	ExchangeRateId int64         `gorm:"column:exchange_rate_id"`
+	Rate           string        `gorm:"column:rate;type:varchar(40)"`
+	CreatedAt      time.Time     `gorm:"column:created_at"`
+	FromWallet     *Wallet       `gorm:"foreignKey:from_wallet_id;references:id"`
+	ToWallet       *Wallet       `gorm:"foreignKey:to_wallet_id;references:id"`
+	ExchangeRate   *ExchangeRate `gorm:"foreignKey:exchange_rate_id;references:id"`
+}
+
+var multiCurrencyWallets = migrate.Migration{
+	Version: 13,
+	Name:    "multi_currency_wallets",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Table("wallets").Migrator().CreateIndex(&walletOwner{}, "idx_wallets_user_currency"); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateTable(&ExchangeRate{}, &Conversion{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(&Conversion{}, &ExchangeRate{}); err != nil {
+			return err
+		}
+		return tx.Table("wallets").Migrator().DropIndex(&walletOwner{}, "idx_wallets_user_currency")
+	},
+}
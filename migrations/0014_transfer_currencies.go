@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"golang-gorm/migrate"
+	"golang-gorm/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transferAmount is a transfer's amount with its currency, as text written
+// by money.Money, such as "USD 12.34". Transfers used to keep bare minor
+// units of whatever their wallets held.
+type transferAmount struct {
+	AmountText string `gorm:"column:amount_text;type:varchar(40)"`
+}
+
+type transferMinorUnits struct {
+	AmountMinor int64 `gorm:"column:amount_minor"`
+}
+
+type legacyTransfer struct {
+	ID       int64
+	Amount   int64
+	Currency string
+}
+
+type currencyTransfer struct {
+	ID     int64
+	Amount money.Money
+}
+
+// The new column is filled in beside the old one and renamed over it with
+// plain ALTER TABLE: the migrator's SQLite DropColumn and RenameColumn
+// rebuild the table and lose its idempotency key index.
+var storeTransferCurrencies = migrate.Migration{
+	Version: 14,
+	Name:    "store_transfer_currencies",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Table("transfers").Migrator().AddColumn(&transferAmount{}, "AmountText"); err != nil {
+			return err
+		}
+		// a transfer is in the currency of the wallet it was sent from
+		var transfers []legacyTransfer
+		err := tx.Table("transfers").
+			Select("transfers.id, transfers.amount, wallets.currency").
+			Joins("JOIN wallets ON wallets.id = transfers.from_wallet_id").
+			FindInBatches(&transfers, 100, func(batch *gorm.DB, _ int) error {
+				for _, transfer := range transfers {
+					amount := money.New(transfer.Amount, transfer.Currency)
+					err := tx.Table("transfers").Where("id = ?", transfer.ID).Update("amount_text", amount).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+		if err != nil {
+			return err
+		}
+		return replaceColumn(tx, "transfers", "amount", "amount_text")
+	},
+	// Down keeps the minor units and forgets their currency.
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Table("transfers").Migrator().AddColumn(&transferMinorUnits{}, "AmountMinor"); err != nil {
+			return err
+		}
+		var transfers []currencyTransfer
+		err := tx.Table("transfers").Select("id, amount").FindInBatches(&transfers, 100, func(batch *gorm.DB, _ int) error {
+			for _, transfer := range transfers {
+				err := tx.Table("transfers").Where("id = ?", transfer.ID).Update("amount_minor", transfer.Amount.Amount).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceColumn(tx, "transfers", "amount", "amount_minor")
+	},
+}
+
+// replaceColumn drops column from table and gives its name to replacement.
+func replaceColumn(tx *gorm.DB, table, column, replacement string) error {
+	err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec("ALTER TABLE ? RENAME COLUMN ? TO ?", clause.Table{Name: table}, clause.Column{Name: replacement}, clause.Column{Name: column}).Error
+}
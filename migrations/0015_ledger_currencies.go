@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerJournal is one posted journal. Its primary key is what keeps a
+// journal from being posted twice.
+type LedgerJournal struct {
+	ID          string    `gorm:"primaryKey;column:id;size:100"`
+	Description string    `gorm:"column:description"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+}
+
+// entryCurrency is the currency of a ledger entry's debit or credit. Every
+// amount was in rupiah until wallets got a currency.
+type entryCurrency struct {
+	Currency string `gorm:"column:currency;size:3;not null;default:IDR"`
+}
+
+type journalCurrency struct {
+	JournalId string
+	Currency  string
+}
+
+type conversionJournal struct {
+	IdempotencyKey string
+	FromCurrency   string
+	ToCurrency     string
+}
+
+var addLedgerCurrencies = migrate.Migration{
+	Version: 15,
+	Name:    "add_ledger_currencies",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&LedgerJournal{}); err != nil {
+			return err
+		}
+		err := tx.Exec("INSERT INTO ? (id, description, created_at) SELECT journal_id, MIN(description), MIN(created_at) FROM ? GROUP BY journal_id",
+			clause.Table{Name: "ledger_journals"}, clause.Table{Name: "ledger_entries"}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Table("ledger_entries").Migrator().AddColumn(&entryCurrency{}, "Currency"); err != nil {
+			return err
+		}
+		// a journal moving money in a wallet of another currency is in that
+		// currency throughout, conversions aside
+		var journals []journalCurrency
+		err = tx.Table("ledger_entries").
+			Distinct("ledger_entries.journal_id", "wallets.currency").
+			Joins("JOIN wallets ON wallets.id = ledger_entries.wallet_id").
+			Where("wallets.currency <> ?", "IDR").
+			Find(&journals).Error
+		if err != nil {
+			return err
+		}
+		for _, journal := range journals {
+			err := tx.Table("ledger_entries").Where("journal_id = ?", journal.JournalId).Update("currency", journal.Currency).Error
+			if err != nil {
+				return err
+			}
+		}
+		// a conversion debits the sent currency from one wallet and
+		// credits it to the exchange account, then the other way round
+		// for the currency received
+		var conversions []conversionJournal
+		if err := tx.Table("conversions").Select("idempotency_key", "from_currency", "to_currency").Find(&conversions).Error; err != nil {
+			return err
+		}
+		for _, conversion := range conversions {
+			journal := tx.Table("ledger_entries").Where("journal_id = ?", "conversion:"+conversion.IdempotencyKey).Session(&gorm.Session{})
+			err := journal.
+				Where("(wallet_id IS NOT NULL AND debit > 0) OR (wallet_id IS NULL AND credit > 0)").
+				Update("currency", conversion.FromCurrency).Error
+			if err != nil {
+				return err
+			}
+			err = journal.
+				Where("(wallet_id IS NOT NULL AND credit > 0) OR (wallet_id IS NULL AND debit > 0)").
+				Update("currency", conversion.ToCurrency).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// Down drops the column with plain ALTER TABLE: the migrator's SQLite
+	// DropColumn rebuilds the table and loses its indexes.
+	Down: func(tx *gorm.DB) error {
+		err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "ledger_entries"}, clause.Column{Name: "currency"}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(&LedgerJournal{})
+	},
+}
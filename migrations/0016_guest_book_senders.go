@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"golang-gorm/migrate"
+	"gorm.io/gorm"
+)
+
+// GuestBookSender is a row per email address that has signed the guest
+// book, for submissions from one address to lock while they count its
+// recent entries.
+type GuestBookSender struct {
+	Email     string    `gorm:"primaryKey;column:email;size:191"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+var addGuestBookSenders = migrate.Migration{
+	Version: 16,
+	Name:    "add_guest_book_senders",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&GuestBookSender{}); err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO guest_book_senders (email, created_at) SELECT email, MIN(created_at) FROM guest_books GROUP BY email").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&GuestBookSender{})
+	},
+}
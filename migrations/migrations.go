@@ -0,0 +1,31 @@
+// Package migrations holds the versioned schema of the application tables.
+//
+// Every migration declares the columns it creates with its own snapshot
+// types instead of the live models, so that changing a model later never
+// changes what an old migration does. New migrations get the next version
+// number and are added to All.
+package migrations
+
+import "golang-gorm/migrate"
+
+// All returns every migration, oldest first.
+func All() []migrate.Migration {
+	return []migrate.Migration{
+		initialSchema,
+		addUpdatedAt,
+		createTransfers,
+		createLedgerEntries,
+		hashPasswords,
+		addAuditLog,
+		addLikedAt,
+		addTodoDomain,
+		addGuestBookModeration,
+		structureAddresses,
+		addAddressLocations,
+		addCurrencies,
+		multiCurrencyWallets,
+		storeTransferCurrencies,
+		addLedgerCurrencies,
+		addGuestBookSenders,
+	}
+}
@@ -0,0 +1,43 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"gorm.io/gorm"
+)
+
+// Models lists every model owning a table, parents before children so
+// foreign keys can be created in order.
+func Models() []interface{} {
+	return []interface{}{
+		&User{},
+		&UserLog{},
+		&Wallet{},
+		&Address{},
+		&Product{},
+		&UserLikeProduct{},
+		&Todo{},
+		&Tag{},
+		&GuestBook{},
+		&GuestBookSender{},
+		&Transfer{},
+		&LedgerJournal{},
+		&LedgerEntry{},
+		&ExchangeRate{},
+		&Conversion{},
+	}
+}
+
+// Migrate applies every pending schema migration, creating the tables for
+// all models.
+func Migrate(db *gorm.DB) error {
+	migrator, err := migrate.New(db, migrations.All()...)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(context.Background())
+	return err
+}
@@ -0,0 +1,331 @@
+// Package money holds amounts of money as whole minor units, such as cents,
+// together with their ISO 4217 currency, so amounts are never rounded by
+// floating point and never added across currencies by mistake.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("money: unknown currency")
+	ErrCurrencyMismatch = errors.New("money: currencies differ")
+	ErrOverflow         = errors.New("money: amount out of range")
+	ErrInvalid          = errors.New("money: invalid amount")
+)
+
+// exponents holds the currencies Money accepts with the number of minor
+// units to their major unit as a power of ten. IDR is kept in whole
+// rupiah: sen have long been out of use.
+var exponents = map[string]int{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"AUD": 2,
+	"CNY": 2,
+	"THB": 2,
+	"KWD": 3,
+	"BHD": 3,
+}
+
+// Exponent returns how many decimal places the currency's amounts have.
+func Exponent(currency string) (int, error) {
+	exponent, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exponent, nil
+}
+
+// Known reports whether currency is one Money accepts.
+func Known(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// Money is an amount in the minor units of Currency. The zero Money has no
+// currency and stands for no amount at all.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) same(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+// Add returns m + other.
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.same(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %v + %v", ErrOverflow, m, other)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other.
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.same(other); err != nil {
+		return Money{}, err
+	}
+	difference := m.Amount - other.Amount
+	if (other.Amount > 0 && difference > m.Amount) || (other.Amount < 0 && difference < m.Amount) {
+		return Money{}, fmt.Errorf("%w: %v - %v", ErrOverflow, m, other)
+	}
+	return Money{Amount: difference, Currency: m.Currency}, nil
+}
+
+// Neg returns -m.
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: -(%v)", ErrOverflow, m)
+	}
+	return Money{Amount: -m.Amount, Currency: m.Currency}, nil
+}
+
+// Mul returns m * n.
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %v * %d", ErrOverflow, m, n)
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// Cmp compares m and other like strings.Compare.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.same(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Sum adds up amounts in currency; no amounts add up to zero.
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Money{Currency: currency}
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Rounding says which way a result between two minor units goes.
+type Rounding int
+
+const (
+	// HalfEven rounds to the nearest minor unit and halves to the even
+	// one, so rounding many results does not drift in one direction.
+	HalfEven Rounding = iota
+	// HalfUp rounds to the nearest minor unit and halves away from zero.
+	HalfUp
+	// Down rounds toward zero.
+	Down
+)
+
+// Div returns m / n rounded to a minor unit.
+func (m Money) Div(n int64, rounding Rounding) (Money, error) {
+	if n == 0 {
+		return Money{}, fmt.Errorf("%w: %v / 0", ErrInvalid, m)
+	}
+	return ratio(big.NewInt(m.Amount), big.NewInt(n), m.Currency, rounding)
+}
+
+// Average returns the mean of amounts in currency, rounded to a minor unit.
+// Averages round half to even, see HalfEven, unlike Div which rounds as
+// told. The sum is not limited to int64, so averaging never overflows.
+func Average(currency string, amounts ...Money) (Money, error) {
+	if len(amounts) == 0 {
+		return Money{Currency: currency}, nil
+	}
+	total := new(big.Int)
+	for _, amount := range amounts {
+		if amount.Currency != currency {
+			return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, currency, amount.Currency)
+		}
+		total.Add(total, big.NewInt(amount.Amount))
+	}
+	return ratio(total, big.NewInt(int64(len(amounts))), currency, HalfEven)
+}
+
+// ratio returns numerator / denominator rounded to a minor unit.
+func ratio(numerator, denominator *big.Int, currency string, rounding Rounding) (Money, error) {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() != 0 && rounding != Down {
+		// compare twice the remainder with the denominator, both positive
+		twice := new(big.Int).Abs(remainder)
+		twice.Lsh(twice, 1)
+		half := twice.Cmp(new(big.Int).Abs(denominator))
+		if half > 0 || (half == 0 && (rounding == HalfUp || quotient.Bit(0) == 1)) {
+			if numerator.Sign()*denominator.Sign() < 0 {
+				quotient.Sub(quotient, big.NewInt(1))
+			} else {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		}
+	}
+	if !quotient.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s / %s %s", ErrOverflow, numerator, denominator, currency)
+	}
+	return Money{Amount: quotient.Int64(), Currency: currency}, nil
+}
+
+// String formats m in major units after its currency, such as
+// "USD 12.34" or "IDR 50000".
+func (m Money) String() string {
+	if m == (Money{}) {
+		return "0"
+	}
+	exponent, err := Exponent(m.Currency)
+	if err != nil || exponent == 0 {
+		return m.Currency + " " + strconv.FormatInt(m.Amount, 10)
+	}
+
+	digits := strconv.FormatUint(absolute(m.Amount), 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	point := len(digits) - exponent
+	return m.Currency + " " + sign + digits[:point] + "." + digits[point:]
+}
+
+func absolute(n int64) uint64 {
+	if n < 0 {
+		return uint64(^n) + 1
+	}
+	return uint64(n)
+}
+
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// Parse reads money written by String. The amount may have fewer decimal
+// places than the currency, but not more.
+func Parse(s string) (Money, error) {
+	currency, amount, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if !decimalPattern.MatchString(amount) || len(fraction) > exponent {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	digits := whole + fraction + strings.Repeat("0", exponent-len(fraction))
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+		}
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// Value stores Money in a single column as text, see String. The zero
+// Money is NULL.
+func (m Money) Value() (driver.Value, error) {
+	if m == (Money{}) {
+		return nil, nil
+	}
+	if !Known(m.Currency) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, m.Currency)
+	}
+	return m.String(), nil
+}
+
+// Scan reads Money stored by Value.
+func (m *Money) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+type moneyJSON struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes m as {"amount": 1234, "currency": "USD"}, the amount
+// in minor units.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON reads money written by MarshalJSON. The currency must be
+// known; the amount must be a whole number of minor units.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var decoded moneyJSON
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&decoded); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !Known(decoded.Currency) {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, decoded.Currency)
+	}
+	*m = Money{Amount: decoded.Amount, Currency: decoded.Currency}
+	return nil
+}
@@ -0,0 +1,226 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArithmetic(t *testing.T) {
+	t.Parallel()
+
+	sum, err := New(150, "USD").Add(New(75, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, New(225, "USD"), sum)
+
+	difference, err := New(150, "USD").Sub(New(200, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, New(-50, "USD"), difference)
+	assert.True(t, difference.IsNegative())
+
+	product, err := New(-250, "IDR").Mul(4)
+	assert.Nil(t, err)
+	assert.Equal(t, New(-1000, "IDR"), product)
+
+	_, err = New(1, "USD").Add(New(1, "IDR"))
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+	_, err = New(1, "USD").Cmp(New(1, "IDR"))
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+
+	cmp, err := New(1, "USD").Cmp(New(2, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, -1, cmp)
+
+	total, err := Sum("IDR", New(1000000, "IDR"), New(5000000, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, New(6000000, "IDR"), total)
+	empty, err := Sum("IDR")
+	assert.Nil(t, err)
+	assert.Equal(t, New(0, "IDR"), empty)
+}
+
+func TestOverflow(t *testing.T) {
+	t.Parallel()
+
+	for _, err := range []error{
+		second(New(math.MaxInt64, "IDR").Add(New(1, "IDR"))),
+		second(New(math.MinInt64, "IDR").Add(New(-1, "IDR"))),
+		second(New(math.MinInt64, "IDR").Sub(New(1, "IDR"))),
+		second(New(math.MaxInt64, "IDR").Sub(New(-1, "IDR"))),
+		second(New(math.MinInt64, "IDR").Neg()),
+		second(New(math.MaxInt64/2+1, "IDR").Mul(2)),
+		second(New(math.MinInt64, "IDR").Mul(-1)),
+		second(New(math.MinInt64, "IDR").Div(-1, HalfEven)),
+		second(Sum("IDR", New(math.MaxInt64, "IDR"), New(1, "IDR"))),
+	} {
+		assert.True(t, errors.Is(err, ErrOverflow), err)
+	}
+
+	// the edges themselves are fine
+	edge, err := New(math.MaxInt64-1, "IDR").Add(New(1, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), edge.Amount)
+	edge, err = New(math.MinInt64+1, "IDR").Sub(New(1, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MinInt64), edge.Amount)
+}
+
+func second(_ Money, err error) error {
+	return err
+}
+
+func TestRounding(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		amount, n int64
+		rounding  Rounding
+		want      int64
+	}{
+		{5, 2, HalfEven, 2},
+		{7, 2, HalfEven, 4},
+		{-5, 2, HalfEven, -2},
+		{-7, 2, HalfEven, -4},
+		{5, 2, HalfUp, 3},
+		{-5, 2, HalfUp, -3},
+		{5, -2, HalfUp, -3},
+		{5, 2, Down, 2},
+		{-5, 2, Down, -2},
+		{10, 3, HalfEven, 3},
+		{20, 3, HalfEven, 7},
+		{-20, 3, HalfUp, -7},
+		{20, 3, Down, 6},
+	}
+	for _, c := range cases {
+		got, err := New(c.amount, "USD").Div(c.n, c.rounding)
+		assert.Nil(t, err)
+		assert.Equal(t, c.want, got.Amount, "%d / %d rounding %d", c.amount, c.n, c.rounding)
+	}
+
+	_, err := New(1, "USD").Div(0, HalfEven)
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
+
+func TestAverage(t *testing.T) {
+	t.Parallel()
+
+	avg, err := Average("IDR", New(1000000, "IDR"), New(5000000, "IDR"), New(3000000, "IDR"), New(3000000, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, New(3000000, "IDR"), avg)
+
+	// 2.5 and 3.5 cents round to the even cent
+	avg, err = Average("USD", New(2, "USD"), New(3, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), avg.Amount)
+	avg, err = Average("USD", New(3, "USD"), New(4, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), avg.Amount)
+
+	// the sum would overflow int64, the average does not
+	avg, err = Average("IDR", New(math.MaxInt64, "IDR"), New(math.MaxInt64, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), avg.Amount)
+
+	none, err := Average("IDR")
+	assert.Nil(t, err)
+	assert.Equal(t, New(0, "IDR"), none)
+
+	_, err = Average("IDR", New(1, "USD"))
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+}
+
+func TestStringAndParse(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]Money{
+		"USD 12.34":  New(1234, "USD"),
+		"USD 0.05":   New(5, "USD"),
+		"USD -0.05":  New(-5, "USD"),
+		"USD -12.00": New(-1200, "USD"),
+		"IDR 50000":  New(50000, "IDR"),
+		"KWD 1.250":  New(1250, "KWD"),
+	}
+	for text, m := range cases {
+		assert.Equal(t, text, m.String())
+		parsed, err := Parse(text)
+		assert.Nil(t, err, text)
+		assert.Equal(t, m, parsed)
+	}
+	assert.Equal(t, "IDR -9223372036854775808", New(math.MinInt64, "IDR").String())
+	assert.Equal(t, "USD -92233720368547758.08", New(math.MinInt64, "USD").String())
+
+	short, err := Parse("USD 12.5")
+	assert.Nil(t, err)
+	assert.Equal(t, New(1250, "USD"), short)
+
+	for _, text := range []string{"12.34", "USD 12.345", "USD .5", "USD +1", "USD 1e3", "IDR 1.5", "USD 12.", "IDR 99999999999999999999"} {
+		_, err := Parse(text)
+		assert.NotNil(t, err, text)
+	}
+	_, err = Parse("XXX 1")
+	assert.True(t, errors.Is(err, ErrUnknownCurrency))
+	_, err = Parse("IDR 99999999999999999999")
+	assert.True(t, errors.Is(err, ErrOverflow))
+}
+
+func TestScanValue(t *testing.T) {
+	t.Parallel()
+
+	value, err := New(1234, "USD").Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "USD 12.34", value)
+	value, err = Money{}.Value()
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	_, err = New(1, "XXX").Value()
+	assert.True(t, errors.Is(err, ErrUnknownCurrency))
+
+	var m Money
+	assert.Nil(t, m.Scan([]byte("IDR 50000")))
+	assert.Equal(t, New(50000, "IDR"), m)
+	assert.Nil(t, m.Scan(nil))
+	assert.Equal(t, Money{}, m)
+	assert.NotNil(t, m.Scan(int64(5)))
+
+	for _, original := range []Money{
+		{},
+		New(0, "USD"),
+		New(-5, "USD"),
+		New(1250, "KWD"),
+		New(math.MaxInt64, "IDR"),
+		New(math.MinInt64, "USD"),
+	} {
+		value, err := original.Value()
+		assert.Nil(t, err, original)
+		var scanned Money
+		assert.Nil(t, scanned.Scan(value), original)
+		assert.Equal(t, original, scanned)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(struct {
+		Price Money `json:"price"`
+	}{New(1234, "USD")})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"price":{"amount":1234,"currency":"USD"}}`, string(data))
+
+	var m Money
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":-50,"currency":"IDR"}`), &m))
+	assert.Equal(t, New(-50, "IDR"), m)
+
+	for _, invalid := range []string{
+		`{"amount":1,"currency":"usd"}`,
+		`{"amount":1}`,
+		`{"amount":1.5,"currency":"USD"}`,
+		`{"amount":1,"currency":"USD","extra":true}`,
+		`"USD 1.00"`,
+	} {
+		assert.NotNil(t, json.Unmarshal([]byte(invalid), &m), invalid)
+	}
+}
@@ -0,0 +1,161 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RateDecimals is how many decimal places a Rate keeps when written out.
+// Rates are read with at most this many.
+const RateDecimals = 12
+
+// Rate is how many major units of one currency one major unit of another
+// buys, such as 15500 rupiah to the dollar. It is an exact fraction, so
+// converting with a rate and with its inverse agree. The zero Rate is no
+// rate at all.
+type Rate struct {
+	r *big.Rat
+}
+
+// ParseRate reads a positive decimal rate such as "15500" or "0.0000645".
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	_, fraction, _ := strings.Cut(s, ".")
+	if !decimalPattern.MatchString(s) || strings.HasPrefix(s, "-") || len(fraction) > RateDecimals {
+		return Rate{}, fmt.Errorf("%w: rate %q", ErrInvalid, s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() == 0 {
+		return Rate{}, fmt.Errorf("%w: rate %q", ErrInvalid, s)
+	}
+	return Rate{r: r}, nil
+}
+
+// MustParseRate is ParseRate for rates known to be valid; it panics on an
+// invalid one.
+func MustParseRate(s string) Rate {
+	rate, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return rate
+}
+
+func (r Rate) IsZero() bool {
+	return r.r == nil
+}
+
+// Inverse returns the rate the other way round.
+func (r Rate) Inverse() Rate {
+	if r.r == nil {
+		return r
+	}
+	return Rate{r: new(big.Rat).Inv(r.r)}
+}
+
+// Cmp compares two rates, returning -1, 0 or +1. The zero Rate is smaller
+// than any other.
+func (r Rate) Cmp(other Rate) int {
+	switch {
+	case r.r == nil && other.r == nil:
+		return 0
+	case r.r == nil:
+		return -1
+	case other.r == nil:
+		return 1
+	}
+	return r.r.Cmp(other.r)
+}
+
+// String writes r as a decimal with no more places than it needs, up to
+// RateDecimals; an inverse rate may be rounded to fit.
+func (r Rate) String() string {
+	if r.r == nil {
+		return "0"
+	}
+	places := 0
+	scaled := new(big.Rat).Set(r.r)
+	for places < RateDecimals && !scaled.IsInt() {
+		scaled.Mul(scaled, big.NewRat(10, 1))
+		places++
+	}
+	return r.r.FloatString(places)
+}
+
+// Convert returns m in currency at rate, rounded to a minor unit of
+// currency. The rate is how many units of currency one unit of m's
+// currency buys.
+func (m Money) Convert(currency string, rate Rate, rounding Rounding) (Money, error) {
+	if rate.r == nil {
+		return Money{}, fmt.Errorf("%w: no rate from %s to %s", ErrInvalid, m.Currency, currency)
+	}
+	from, err := Exponent(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	to, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	numerator := new(big.Int).Mul(big.NewInt(m.Amount), rate.r.Num())
+	denominator := new(big.Int).Set(rate.r.Denom())
+	if to > from {
+		numerator.Mul(numerator, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil))
+	} else if from > to {
+		denominator.Mul(denominator, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(from-to)), nil))
+	}
+	return ratio(numerator, denominator, currency, rounding)
+}
+
+// Value stores a Rate as decimal text, see String. The zero Rate is NULL.
+func (r Rate) Value() (driver.Value, error) {
+	if r.r == nil {
+		return nil, nil
+	}
+	return r.String(), nil
+}
+
+// Scan reads a Rate stored by Value.
+func (r *Rate) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return fmt.Errorf("money: cannot scan %T into a rate", src)
+	}
+	parsed, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// MarshalJSON writes r as a decimal string, so no precision is lost to
+// floating point on the way.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("%w: rate %v", ErrInvalid, err)
+	}
+	parsed, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
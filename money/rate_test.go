@@ -0,0 +1,93 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"15500", "1.08", "0.0000645", "0.000000000001"} {
+		rate, err := ParseRate(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, s, rate.String())
+	}
+
+	for _, s := range []string{"", "0", "-1.5", "1e3", "abc", "0.0000000000001"} {
+		_, err := ParseRate(s)
+		assert.True(t, errors.Is(err, ErrInvalid), s)
+	}
+
+	assert.Equal(t, "0.25", MustParseRate("4").Inverse().String())
+	assert.Equal(t, "0.333333333333", MustParseRate("3").Inverse().String())
+	assert.Equal(t, 0, MustParseRate("1.50").Cmp(MustParseRate("1.5")))
+	assert.Equal(t, -1, Rate{}.Cmp(MustParseRate("1")))
+	assert.True(t, Rate{}.IsZero())
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	rupiah := MustParseRate("15500")
+
+	// USD 12.34 is IDR 191270
+	converted, err := New(1234, "USD").Convert("IDR", rupiah, HalfEven)
+	assert.Nil(t, err)
+	assert.Equal(t, New(191270, "IDR"), converted)
+
+	// and back again through the exact inverse
+	back, err := converted.Convert("USD", rupiah.Inverse(), HalfEven)
+	assert.Nil(t, err)
+	assert.Equal(t, New(1234, "USD"), back)
+
+	// IDR 100 is USD 0.00645..., which rounds to one cent or down to none
+	cents, err := New(100, "IDR").Convert("USD", rupiah.Inverse(), HalfEven)
+	assert.Nil(t, err)
+	assert.Equal(t, New(1, "USD"), cents)
+	cents, err = New(100, "IDR").Convert("USD", rupiah.Inverse(), Down)
+	assert.Nil(t, err)
+	assert.Equal(t, New(0, "USD"), cents)
+
+	// three decimal places to two
+	dinar, err := New(1005, "KWD").Convert("USD", MustParseRate("3.25"), HalfEven)
+	assert.Nil(t, err)
+	assert.Equal(t, New(327, "USD"), dinar)
+
+	_, err = New(math.MaxInt64, "USD").Convert("IDR", rupiah, HalfEven)
+	assert.True(t, errors.Is(err, ErrOverflow))
+	_, err = New(1, "USD").Convert("XXX", rupiah, HalfEven)
+	assert.True(t, errors.Is(err, ErrUnknownCurrency))
+	_, err = New(1, "USD").Convert("IDR", Rate{}, HalfEven)
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
+
+func TestRateScanValueJSON(t *testing.T) {
+	t.Parallel()
+
+	value, err := MustParseRate("1.0800").Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "1.08", value)
+	value, err = Rate{}.Value()
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	var r Rate
+	assert.Nil(t, r.Scan([]byte("15500")))
+	assert.Equal(t, 0, r.Cmp(MustParseRate("15500")))
+	assert.Nil(t, r.Scan(nil))
+	assert.True(t, r.IsZero())
+	assert.NotNil(t, r.Scan(15500.0))
+
+	data, err := json.Marshal(MustParseRate("0.0000645"))
+	assert.Nil(t, err)
+	assert.Equal(t, `"0.0000645"`, string(data))
+	assert.Nil(t, json.Unmarshal([]byte(`"1.08"`), &r))
+	assert.Equal(t, "1.08", r.String())
+	assert.NotNil(t, json.Unmarshal([]byte(`1.08`), &r))
+	assert.NotNil(t, json.Unmarshal([]byte(`"-1"`), &r))
+}
@@ -0,0 +1,331 @@
+// Package pagination pages through query results either by offset or by
+// keyset.
+//
+// Keyset pagination seeks past the last row of the previous page instead of
+// skipping rows, so it stays fast deep into a table and neither skips nor
+// repeats rows when others are inserted meanwhile. Offset pagination is
+// there for listings that need arbitrary page jumps.
+//
+// Both hand out opaque cursors: pass a Page's NextCursor or PrevCursor back
+// in Params.Cursor together with the same keys.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrUnknownKey    = errors.New("unknown pagination key")
+)
+
+// Key is a column the rows are ordered by.
+type Key struct {
+	Column string
+	Desc   bool
+}
+
+type Params struct {
+	// Limit is the page size, DefaultLimit when zero and at most MaxLimit.
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	// WithTotal also counts every matching row.
+	WithTotal bool `json:"with_total,omitempty"`
+}
+
+func (p Params) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultLimit
+	case p.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return p.Limit
+}
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Total is set when Params.WithTotal was.
+	Total      *int64 `json:"total,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+const (
+	modeOffset = "offset"
+	modeKeyset = "keyset"
+)
+
+type cursor struct {
+	Mode     string            `json:"m"`
+	Offset   int               `json:"o,omitempty"`
+	Backward bool              `json:"b,omitempty"`
+	Values   []json.RawMessage `json:"v,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value, mode string) (cursor, error) {
+	var c cursor
+	if value == "" {
+		return cursor{Mode: mode}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Mode != mode || c.Offset < 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func total[T any](db *gorm.DB, params Params) (*int64, error) {
+	if !params.WithTotal {
+		return nil, nil
+	}
+	var count int64
+	if err := db.Model(new(T)).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+func orderBy(keys []Key, backward bool) clause.OrderBy {
+	columns := make([]clause.OrderByColumn, len(keys))
+	for i, key := range keys {
+		columns[i] = clause.OrderByColumn{Column: column(key), Desc: key.Desc != backward}
+	}
+	return clause.OrderBy{Columns: columns}
+}
+
+func column(key Key) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: key.Column}
+}
+
+// Offset returns the page of db's rows, ordered by keys, that the cursor
+// points at.
+func Offset[T any](db *gorm.DB, params Params, keys ...Key) (Page[T], error) {
+	var page Page[T]
+	c, err := decodeCursor(params.Cursor, modeOffset)
+	if err != nil {
+		return page, err
+	}
+	db = db.Session(&gorm.Session{})
+	limit := params.limit()
+
+	if page.Total, err = total[T](db, params); err != nil {
+		return page, err
+	}
+
+	query := db.Offset(c.Offset).Limit(limit + 1)
+	if len(keys) > 0 {
+		query = query.Clauses(orderBy(keys, false))
+	}
+	if err := query.Find(&page.Items).Error; err != nil {
+		return page, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.HasNext = true
+		page.NextCursor = cursor{Mode: modeOffset, Offset: c.Offset + limit}.encode()
+	}
+	if c.Offset > 0 {
+		page.HasPrev = true
+		prev := c.Offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.PrevCursor = cursor{Mode: modeOffset, Offset: prev}.encode()
+	}
+	return page, nil
+}
+
+// Keyset returns the page of db's rows, ordered by keys, that the cursor
+// points at. The primary key is added as the last key when missing, so
+// every row has a distinct position.
+func Keyset[T any](db *gorm.DB, params Params, keys ...Key) (Page[T], error) {
+	var page Page[T]
+	c, err := decodeCursor(params.Cursor, modeKeyset)
+	if err != nil {
+		return page, err
+	}
+	db = db.Session(&gorm.Session{})
+	limit := params.limit()
+
+	fields, keys, err := keyFields(db, new(T), keys)
+	if err != nil {
+		return page, err
+	}
+	values, err := decodeValues(c.Values, fields)
+	if err != nil {
+		return page, err
+	}
+
+	if page.Total, err = total[T](db, params); err != nil {
+		return page, err
+	}
+
+	query := db.Clauses(orderBy(keys, c.Backward)).Limit(limit + 1)
+	if values != nil {
+		query = query.Where(seek(keys, values, !c.Backward))
+	}
+	if err := query.Find(&page.Items).Error; err != nil {
+		return page, err
+	}
+
+	more := len(page.Items) > limit
+	if more {
+		page.Items = page.Items[:limit]
+	}
+	if c.Backward {
+		for i, j := 0, len(page.Items)-1; i < j; i, j = i+1, j-1 {
+			page.Items[i], page.Items[j] = page.Items[j], page.Items[i]
+		}
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+
+	first, err := rowValues(db, fields, &page.Items[0])
+	if err != nil {
+		return page, err
+	}
+	last, err := rowValues(db, fields, &page.Items[len(page.Items)-1])
+	if err != nil {
+		return page, err
+	}
+
+	if c.Backward {
+		page.HasPrev = more
+		page.HasNext, err = exists[T](db, keys, last, true)
+	} else {
+		page.HasNext = more
+		if values != nil {
+			page.HasPrev, err = exists[T](db, keys, first, false)
+		}
+	}
+	if err != nil {
+		return page, err
+	}
+
+	if page.HasNext {
+		page.NextCursor = cursor{Mode: modeKeyset, Values: last}.encode()
+	}
+	if page.HasPrev {
+		page.PrevCursor = cursor{Mode: modeKeyset, Backward: true, Values: first}.encode()
+	}
+	return page, nil
+}
+
+func keyFields(db *gorm.DB, model interface{}, keys []Key) ([]*schema.Field, []Key, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, err
+	}
+
+	fields := make([]*schema.Field, 0, len(keys)+1)
+	for _, key := range keys {
+		field := stmt.Schema.LookUpField(key.Column)
+		if field == nil || field.DBName == "" {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownKey, key.Column)
+		}
+		fields = append(fields, field)
+	}
+
+	if primary := stmt.Schema.PrioritizedPrimaryField; primary != nil {
+		for _, field := range fields {
+			if field == primary {
+				return fields, keys, nil
+			}
+		}
+		desc := len(keys) > 0 && keys[len(keys)-1].Desc
+		keys = append(keys[:len(keys):len(keys)], Key{Column: primary.DBName, Desc: desc})
+		fields = append(fields, primary)
+	}
+	return fields, keys, nil
+}
+
+func decodeValues(raw []json.RawMessage, fields []*schema.Field) ([]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if len(raw) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(raw))
+	for i, field := range fields {
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(raw[i], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, nil
+}
+
+func rowValues(db *gorm.DB, fields []*schema.Field, row interface{}) ([]json.RawMessage, error) {
+	value := reflect.ValueOf(row).Elem()
+	values := make([]json.RawMessage, len(fields))
+	for i, field := range fields {
+		v, _ := field.ValueOf(db.Statement.Context, value)
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = data
+	}
+	return values, nil
+}
+
+// seek matches the rows after values in key order, or before them.
+func seek(keys []Key, values []interface{}, forward bool) clause.Expression {
+	ors := make([]clause.Expression, len(keys))
+	for i, key := range keys {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: column(keys[j]), Value: values[j]})
+		}
+		if forward != key.Desc {
+			ands = append(ands, clause.Gt{Column: column(key), Value: values[i]})
+		} else {
+			ands = append(ands, clause.Lt{Column: column(key), Value: values[i]})
+		}
+		ors[i] = clause.And(ands...)
+	}
+	// parenthesized, or gorm would OR it with the caller's conditions
+	return clause.Expr{SQL: "(?)", Vars: []interface{}{clause.Or(ors...)}}
+}
+
+func exists[T any](db *gorm.DB, keys []Key, raw []json.RawMessage, forward bool) (bool, error) {
+	fields, _, err := keyFields(db, new(T), keys)
+	if err != nil {
+		return false, err
+	}
+	values, err := decodeValues(raw, fields)
+	if err != nil {
+		return false, err
+	}
+	var rows []T
+	err = db.Where(seek(keys, values, forward)).Limit(1).Find(&rows).Error
+	return len(rows) > 0, err
+}
@@ -0,0 +1,149 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Item struct {
+	ID        int64 `gorm:"primaryKey"`
+	Score     int
+	CreatedAt time.Time
+}
+
+func openDB(t *testing.T, n int) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	assert.Nil(t, db.AutoMigrate(&Item{}))
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		// scores repeat so the primary key has to break ties
+		item := Item{ID: int64(i), Score: i % 4, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		assert.Nil(t, db.Create(&item).Error)
+	}
+	return db
+}
+
+func ids(items []Item) []int64 {
+	out := make([]int64, len(items))
+	for i, item := range items {
+		out[i] = item.ID
+	}
+	return out
+}
+
+func TestKeysetForwardAndBack(t *testing.T) {
+	db := openDB(t, 10)
+	keys := []Key{{Column: "score", Desc: true}}
+
+	var pages [][]int64
+	params := Params{Limit: 3, WithTotal: true}
+	for {
+		page, err := Keyset[Item](db, params, keys...)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), *page.Total)
+		assert.Equal(t, len(pages) > 0, page.HasPrev)
+		pages = append(pages, ids(page.Items))
+		if !page.HasNext {
+			assert.Equal(t, "", page.NextCursor)
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	// score desc, then id desc: 3s, 2s, 1s, 0s
+	assert.Equal(t, [][]int64{{7, 3, 10}, {6, 2, 9}, {5, 1, 8}, {4}}, pages)
+
+	page, err := Keyset[Item](db, params, keys...)
+	assert.Nil(t, err)
+	for i := len(pages) - 2; i >= 0; i-- {
+		assert.True(t, page.HasPrev)
+		page, err = Keyset[Item](db, Params{Limit: 3, Cursor: page.PrevCursor}, keys...)
+		assert.Nil(t, err)
+		assert.Equal(t, pages[i], ids(page.Items))
+		assert.True(t, page.HasNext)
+	}
+	assert.False(t, page.HasPrev)
+}
+
+func TestKeysetConcurrentInsert(t *testing.T) {
+	db := openDB(t, 6)
+	keys := []Key{{Column: "created_at"}}
+
+	page, err := Keyset[Item](db, Params{Limit: 3}, keys...)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids(page.Items))
+
+	// a row inserted at the front does not shift the next page
+	assert.Nil(t, db.Create(&Item{ID: 100, CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}).Error)
+
+	page, err = Keyset[Item](db, Params{Limit: 3, Cursor: page.NextCursor}, keys...)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{4, 5, 6}, ids(page.Items))
+	assert.False(t, page.HasNext)
+}
+
+func TestKeysetFiltered(t *testing.T) {
+	db := openDB(t, 10)
+
+	page, err := Keyset[Item](db.Where("score = ?", 1), Params{Limit: 2, WithTotal: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), *page.Total)
+	assert.Equal(t, []int64{1, 5}, ids(page.Items))
+
+	page, err = Keyset[Item](db.Where("score = ?", 1), Params{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{9}, ids(page.Items))
+	assert.Nil(t, page.Total)
+}
+
+func TestOffset(t *testing.T) {
+	db := openDB(t, 10)
+	keys := []Key{{Column: "id", Desc: true}}
+
+	page, err := Offset[Item](db, Params{Limit: 4, WithTotal: true}, keys...)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{10, 9, 8, 7}, ids(page.Items))
+	assert.Equal(t, int64(10), *page.Total)
+	assert.True(t, page.HasNext)
+	assert.False(t, page.HasPrev)
+
+	page, err = Offset[Item](db, Params{Limit: 4, Cursor: page.NextCursor}, keys...)
+	assert.Nil(t, err)
+	page, err = Offset[Item](db, Params{Limit: 4, Cursor: page.NextCursor}, keys...)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 1}, ids(page.Items))
+	assert.False(t, page.HasNext)
+
+	page, err = Offset[Item](db, Params{Limit: 4, Cursor: page.PrevCursor}, keys...)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{6, 5, 4, 3}, ids(page.Items))
+}
+
+func TestInvalid(t *testing.T) {
+	db := openDB(t, 3)
+
+	for _, value := range []string{"not base64!", "e30", fmt.Sprint(cursor{Mode: modeOffset}.encode())} {
+		_, err := Keyset[Item](db, Params{Cursor: value})
+		assert.True(t, errors.Is(err, ErrInvalidCursor), value)
+	}
+	_, err := Offset[Item](db, Params{Cursor: cursor{Mode: modeKeyset}.encode()})
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+
+	_, err = Keyset[Item](db, Params{}, Key{Column: "missing"})
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+
+	assert.Equal(t, DefaultLimit, Params{}.limit())
+	assert.Equal(t, MaxLimit, Params{Limit: 1000}.limit())
+}
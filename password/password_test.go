@@ -0,0 +1,50 @@
+package password
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBcrypt(t *testing.T) {
+	hasher := Bcrypt{Cost: 4}
+
+	hash, err := hasher.Hash("rahasia")
+	assert.Nil(t, err)
+	assert.True(t, IsHash(hash))
+	assert.Nil(t, Verify(hash, "rahasia"))
+	assert.True(t, errors.Is(Verify(hash, "knok"), ErrMismatch))
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, Bcrypt{Cost: 5}.NeedsRehash(hash))
+	assert.True(t, DefaultArgon2id().NeedsRehash(hash))
+}
+
+func TestArgon2id(t *testing.T) {
+	hasher := Argon2id{Time: 1, Memory: 1024, Threads: 1, KeyLength: 32, SaltLength: 16}
+
+	hash, err := hasher.Hash("rahasia")
+	assert.Nil(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$`, hash)
+	assert.True(t, IsHash(hash))
+	assert.Nil(t, Verify(hash, "rahasia"))
+	assert.True(t, errors.Is(Verify(hash, "knok"), ErrMismatch))
+
+	other, err := hasher.Hash("rahasia")
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, other)
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	stronger := hasher
+	stronger.Time = 2
+	assert.True(t, stronger.NeedsRehash(hash))
+	assert.True(t, Bcrypt{}.NeedsRehash(hash))
+}
+
+func TestPlaintext(t *testing.T) {
+	for _, value := range []string{"", "rahasia", "$2a$", "$argon2id$v=19$m=1,t=1,p=1$$"} {
+		assert.False(t, IsHash(value), value)
+		assert.True(t, errors.Is(Verify(value, value), ErrUnknownHash), value)
+	}
+}
@@ -0,0 +1,31 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidInterval = errors.New("interval must be positive")
+
+// runEvery calls run right away and then every interval until ctx is done,
+// which it returns as its error. run reports its own failures; the next
+// tick simply runs it again. runEvery returns ErrInvalidInterval at once
+// when interval is not positive, rather than let time.NewTicker panic.
+func runEvery(ctx context.Context, interval time.Duration, run func(ctx context.Context)) error {
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
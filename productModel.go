@@ -1,16 +1,41 @@
 package golang_gorm
 
-import "time"
+import (
+	"time"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
 
 type Product struct {
-	ID          string    `gorm:"primary_key;column:id"`
-	Name        string    `gorm:"column:name"`
-	Price       int64     `gorm:"column:price"`
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreatedTime"`
-	UpdatedAt   time.Time `gorm:"column:created_at;autoCreatedTime;autoUpdatedTime"`
+	ID   string `gorm:"primary_key;column:id"`
+	Name string `gorm:"column:name"`
+	// PriceAmount is the price in minor units of Currency; see Price.
+	PriceAmount int64     `gorm:"column:price"`
+	Currency    string    `gorm:"column:currency;size:3;not null;default:IDR"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
 	LikeByUsers []User    `gorm:"many2many:user_like_product;foreignKey:id;joinForeignKey:product_id;references:id;joinReferences:user_id"`
 }
 
+func (p *Product) BeforeCreate(db *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = IDGenerator.NewID()
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	return checkCurrency(p.Currency)
+}
+
+func (p *Product) Price() money.Money {
+	return money.New(p.PriceAmount, p.Currency)
+}
+
+func (p *Product) SetPrice(price money.Money) {
+	p.PriceAmount, p.Currency = price.Amount, price.Currency
+}
+
 func (p *Product) TableName() string {
 	return "products"
 }
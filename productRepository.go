@@ -0,0 +1,49 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"golang-gorm/money"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+type ProductRepository struct {
+	*Repository[Product]
+}
+
+func NewProductRepository(db *gorm.DB) *ProductRepository {
+	return &ProductRepository{NewRepository[Product](db)}
+}
+
+func (r *ProductRepository) WithTx(tx *gorm.DB) *ProductRepository {
+	return &ProductRepository{r.Repository.WithTx(tx)}
+}
+
+// FindByPriceRange returns the products priced from min to max, both
+// inclusive, in their currency. min and max must be in the same currency.
+func (r *ProductRepository) FindByPriceRange(ctx context.Context, min, max money.Money) ([]Product, error) {
+	if _, err := min.Cmp(max); err != nil {
+		return nil, err
+	}
+	return r.FindAll(ctx,
+		Where("currency = ? and price between ? and ?", min.Currency, min.Amount, max.Amount),
+		OrderBy("price, id"),
+	)
+}
+
+// FindLikedBy returns the products the user likes.
+func (r *ProductRepository) FindLikedBy(ctx context.Context, userID string) ([]Product, error) {
+	var products []Product
+	err := r.DB(ctx).
+		Joins("join user_like_product on user_like_product.product_id = products.id").
+		Where("user_like_product.user_id = ?", userID).
+		Order("products.id").
+		Find(&products).Error
+	return products, err
+}
+
+// List pages through the products by name.
+func (r *ProductRepository) List(ctx context.Context, params pagination.Params) (pagination.Page[Product], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "name"}})
+}
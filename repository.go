@@ -0,0 +1,162 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scope narrows a query, the same way BrokeWalletBalance does. See
+// scopes.go for the parameterized ones and how to combine them.
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// Repository is the CRUD every model shares. It runs on db unless WithTx
+// bound it to a transaction.
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// WithTx returns a copy of the repository that runs on tx. A nil tx keeps
+// the current connection.
+func (r *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	if tx == nil {
+		return r
+	}
+	return &Repository[T]{db: tx}
+}
+
+// DB returns the connection bound to ctx, for queries the repository does
+// not cover.
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.DB(ctx).Create(entity).Error
+}
+
+// FindByID returns gorm.ErrRecordNotFound when no row has the id.
+func (r *Repository[T]) FindByID(ctx context.Context, id interface{}, scopes ...Scope) (*T, error) {
+	entity := new(T)
+	err := r.DB(ctx).Scopes(scopes...).Where(byPrimaryKey(id)).Take(entity).Error
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (r *Repository[T]) FindAll(ctx context.Context, scopes ...Scope) ([]T, error) {
+	var entities []T
+	err := r.DB(ctx).Scopes(scopes...).Find(&entities).Error
+	return entities, err
+}
+
+// Update saves every field of entity.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	return r.DB(ctx).Save(entity).Error
+}
+
+// UpdateFields changes only the given columns of the row with id and
+// returns gorm.ErrRecordNotFound when there is no such row.
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, fields map[string]interface{}) error {
+	result := r.DB(ctx).Model(new(T)).Where(byPrimaryKey(id)).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// MySQL counts only the rows it changed, so a row that already held
+	// these values looks the same as a missing one
+	exists, err := r.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the row with id (soft deleting models that support it)
+// and returns gorm.ErrRecordNotFound when there is no such row.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	result := r.DB(ctx).Where(byPrimaryKey(id)).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *Repository[T]) Exists(ctx context.Context, id interface{}) (bool, error) {
+	count, err := r.Count(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where(byPrimaryKey(id))
+	})
+	return count > 0, err
+}
+
+func (r *Repository[T]) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	var count int64
+	err := r.DB(ctx).Model(new(T)).Scopes(scopes...).Count(&count).Error
+	return count, err
+}
+
+// Page returns a keyset page of the rows matching scopes, ordered by keys.
+func (r *Repository[T]) Page(ctx context.Context, params pagination.Params, keys []pagination.Key, scopes ...Scope) (pagination.Page[T], error) {
+	return pagination.Keyset[T](r.DB(ctx).Scopes(scopes...), params, keys...)
+}
+
+// PageByOffset is Page for listings that need to jump to arbitrary pages.
+func (r *Repository[T]) PageByOffset(ctx context.Context, params pagination.Params, keys []pagination.Key, scopes ...Scope) (pagination.Page[T], error) {
+	return pagination.Offset[T](r.DB(ctx).Scopes(scopes...), params, keys...)
+}
+
+// Named builds the scope registered for T under name, see RegisterScope.
+func (r *Repository[T]) Named(name string, args ...string) (Scope, error) {
+	return NamedScope[T](name, args...)
+}
+
+func byPrimaryKey(id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}, Value: id}
+}
+
+// Where, OrderBy, Limit, Offset and Preload are scopes for FindAll and Count.
+
+func Where(query interface{}, args ...interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	}
+}
+
+func OrderBy(order string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order(order)
+	}
+}
+
+func Limit(limit int) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Limit(limit)
+	}
+}
+
+func Offset(offset int) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset(offset)
+	}
+}
+
+func Preload(association string, args ...interface{}) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload(association, args...)
+	}
+}
@@ -0,0 +1,220 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/money"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+func TestRepositoryCRUD(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewProductRepository(db)
+
+	err := repo.Create(ctx, &Product{ID: "P010", Name: "Mango", PriceAmount: 40000})
+	assert.Nil(t, err)
+
+	product, err := repo.FindByID(ctx, "P010")
+	assert.Nil(t, err)
+	assert.Equal(t, "Mango", product.Name)
+
+	product.PriceAmount = 45000
+	assert.Nil(t, repo.Update(ctx, product))
+	assert.Nil(t, repo.UpdateFields(ctx, "P010", map[string]interface{}{"name": "Mango Harum Manis"}))
+	// writing the values a row already holds is not a missing row
+	assert.Nil(t, repo.UpdateFields(ctx, "P010", map[string]interface{}{"name": "Mango Harum Manis"}))
+
+	product, err = repo.FindByID(ctx, "P010")
+	assert.Nil(t, err)
+	assert.Equal(t, "Mango Harum Manis", product.Name)
+	assert.Equal(t, int64(45000), product.PriceAmount)
+
+	exists, err := repo.Exists(ctx, "P010")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	assert.Nil(t, repo.Delete(ctx, "P010"))
+	_, err = repo.FindByID(ctx, "P010")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	assert.True(t, errors.Is(repo.Delete(ctx, "P010"), gorm.ErrRecordNotFound))
+	assert.True(t, errors.Is(repo.UpdateFields(ctx, "P010", map[string]interface{}{"price": 1}), gorm.ErrRecordNotFound))
+
+	exists, err = repo.Exists(ctx, "P010")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestRepositoryFindAll(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	users, err := repo.FindAll(ctx, Where("first_name like ?", "User%"), OrderBy("id"), Limit(3), Offset(1))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(users))
+	assert.Equal(t, "3", users[0].ID)
+
+	count, err := repo.Count(ctx, Where("password = ?", "rahasia"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), count)
+
+	user, err := repo.FindWithRelations(ctx, "2")
+	assert.Nil(t, err)
+	assert.Len(t, user.Wallets, 1)
+	assert.Equal(t, money.New(5000000, "IDR"), user.Wallets[0].Balance())
+	assert.Equal(t, 2, len(user.Addresses))
+
+	users, err = repo.FindByWalletBalanceAbove(ctx, money.New(2000000, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(users))
+	assert.Len(t, users[0].Wallets, 1)
+
+	users, err = repo.FindByWalletBalanceAbove(ctx, money.New(0, "USD"))
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+
+	users, err = repo.FindLikersOf(ctx, "P001")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+
+	products := NewProductRepository(db)
+	mango := &Product{ID: "P010", Name: "Mango"}
+	mango.SetPrice(money.New(40000, "USD"))
+	assert.Nil(t, products.Create(ctx, mango))
+
+	inRupiah, err := products.FindByPriceRange(ctx, money.New(30000, "IDR"), money.New(50000, "IDR"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(inRupiah))
+	assert.Equal(t, "P002", inRupiah[0].ID)
+	inDollars, err := products.FindByPriceRange(ctx, money.New(30000, "USD"), money.New(50000, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(inDollars))
+	assert.Equal(t, "P010", inDollars[0].ID)
+	_, err = products.FindByPriceRange(ctx, money.New(30000, "IDR"), money.New(50000, "USD"))
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+}
+
+func TestRepositoryWithTx(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewTodoRepository(db)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := repo.WithTx(tx).Create(ctx, &Todo{UserId: "1", Title: "Rolled back"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+
+	todos, err := repo.SearchTitle(ctx, "1", "Rolled")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(todos))
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return repo.WithTx(tx).Create(ctx, &Todo{UserId: "1", Title: "Committed"})
+	})
+	assert.Nil(t, err)
+
+	todos, err = repo.FindByUserID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(todos))
+}
+
+func TestWalletRepository(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewWalletRepository(db)
+
+	assert.Nil(t, repo.Create(ctx, &Wallet{ID: "10", UserId: "1", Currency: "USD"}))
+	wallets, err := repo.FindByUserID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Len(t, wallets, 2)
+	assert.Equal(t, money.New(1000000, "IDR"), wallets[0].Balance())
+	assert.Equal(t, money.New(0, "USD"), wallets[1].Balance())
+
+	wallet, err := repo.FindByUserAndCurrency(ctx, "1", "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "10", wallet.ID)
+	_, err = repo.FindByUserAndCurrency(ctx, "1", "EUR")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	// one wallet per currency
+	err = repo.Create(ctx, &Wallet{ID: "11", UserId: "1", Currency: "USD"})
+	assert.True(t, errors.Is(err, gorm.ErrDuplicatedKey), err)
+
+	sultans, err := repo.FindSultan(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(sultans))
+
+	stats, err := repo.Stats(ctx, "IDR")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), stats.Count)
+	assert.Equal(t, money.New(12000000, "IDR"), stats.Total)
+	assert.Equal(t, money.New(1000000, "IDR"), stats.Min)
+	assert.Equal(t, money.New(5000000, "IDR"), stats.Max)
+	assert.Equal(t, money.New(3000000, "IDR"), stats.Average)
+
+	stats, err = repo.Stats(ctx, "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, WalletStats{Count: 1, Total: money.New(0, "USD"), Min: money.New(0, "USD"), Max: money.New(0, "USD"), Average: money.New(0, "USD")}, stats)
+
+	_, err = repo.Stats(ctx, "XXX")
+	assert.True(t, errors.Is(err, money.ErrUnknownCurrency))
+}
+
+func TestRepositoryPage(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	users := NewUserRepository(db)
+
+	var seen []string
+	params := pagination.Params{Limit: 4, WithTotal: true}
+	for {
+		page, err := users.List(ctx, params)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(9), *page.Total)
+		for _, user := range page.Items {
+			seen = append(seen, user.ID)
+		}
+		if !page.HasNext {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}, seen)
+
+	guestBooks := NewGuestBookRepository(db)
+	for i := 0; i < 3; i++ {
+		err := guestBooks.Create(ctx, &GuestBook{Name: "Tamu", Email: "tamu@example.com", Message: strconv.Itoa(i)})
+		assert.Nil(t, err)
+	}
+	entries, err := guestBooks.List(ctx, pagination.Params{Limit: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries.Items))
+	assert.True(t, entries.HasNext)
+	entries, err = guestBooks.List(ctx, pagination.Params{Limit: 2, Cursor: entries.NextCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries.Items))
+	assert.True(t, entries.HasPrev)
+
+	products, err := NewProductRepository(db).PageByOffset(ctx, pagination.Params{Limit: 1}, []pagination.Key{{Column: "price"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "Orange", products.Items[0].Name)
+
+	todos, err := NewTodoRepository(db).ListByUserID(ctx, "1", pagination.Params{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(todos.Items))
+	assert.False(t, todos.HasNext)
+}
@@ -0,0 +1,307 @@
+package golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// And applies every scope in turn, so all of their conditions must hold.
+func And(scopes ...Scope) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, scope := range scopes {
+			db = scope(db)
+		}
+		return db
+	}
+}
+
+// Or matches the rows any of the scopes matches. Only the WHERE conditions
+// of the scopes are combined; joins, ordering and limits they add are
+// dropped, so scopes meant for Or filter through subqueries. Or with no
+// scopes, or with a scope that has no conditions, matches every row.
+func Or(scopes ...Scope) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		branches := make([]clause.Expression, 0, len(scopes))
+		for _, scope := range scopes {
+			conditions := whereOf(db, scope)
+			if len(conditions) == 0 {
+				return db
+			}
+			branches = append(branches, clause.And(conditions...))
+		}
+		if len(branches) == 0 {
+			return db
+		}
+		// parenthesized so the OR cannot swallow conditions added elsewhere
+		return db.Where(clause.Expr{SQL: "(?)", Vars: []interface{}{clause.Or(branches...)}})
+	}
+}
+
+// Not matches the rows scope does not. Like Or it only keeps the WHERE
+// conditions of scope.
+func Not(scope Scope) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		conditions := whereOf(db, scope)
+		if len(conditions) == 0 {
+			return db
+		}
+		return db.Where(clause.Not(clause.And(conditions...)))
+	}
+}
+
+func whereOf(db *gorm.DB, scope Scope) []clause.Expression {
+	tx := scope(db.Session(&gorm.Session{NewDB: true}))
+	if tx.Error != nil && tx.Error != db.Error {
+		db.AddError(tx.Error)
+	}
+	if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		return where.Exprs
+	}
+	return nil
+}
+
+func column(name string) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: name}
+}
+
+// contains is a LIKE pattern matching q anywhere, with the wildcards in q
+// escaped by "!", which no driver treats specially inside string literals.
+func contains(q string) string {
+	q = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(q)
+	return "%" + q + "%"
+}
+
+// BalanceBetween matches wallets in the currency of min and max holding min
+// to max, both inclusive. Bounds in different currencies fail the query
+// with money.ErrCurrencyMismatch.
+func BalanceBetween(min, max money.Money) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if _, err := min.Cmp(max); err != nil {
+			db.AddError(err)
+			return db
+		}
+		return db.Where(clause.And(
+			clause.Eq{Column: column("currency"), Value: min.Currency},
+			clause.Gte{Column: column("balance"), Value: min.Amount},
+			clause.Lte{Column: column("balance"), Value: max.Amount},
+		))
+	}
+}
+
+// CreatedBetween matches rows created from from up to, not including, to.
+func CreatedBetween(from, to time.Time) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.And(
+			clause.Gte{Column: column("created_at"), Value: from},
+			clause.Lt{Column: column("created_at"), Value: to},
+		))
+	}
+}
+
+// CreatedWithin matches rows created during the period before the query
+// runs.
+func CreatedWithin(period time.Duration) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Gte{Column: column("created_at"), Value: time.Now().Add(-period)})
+	}
+}
+
+// NameContains matches users whose first, middle or last name contains q.
+func NameContains(q string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		pattern := contains(q)
+		names := make([]clause.Expression, 0, 3)
+		for _, name := range []string{"first_name", "middle_name", "last_name"} {
+			names = append(names, clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column(name), pattern}})
+		}
+		return db.Where(clause.Expr{SQL: "(?)", Vars: []interface{}{clause.Or(names...)}})
+	}
+}
+
+// HasAddressIn matches users with an address in a city whose name
+// contains city.
+func HasAddressIn(city string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM addresses WHERE addresses.user_id = ? AND addresses.city LIKE ? ESCAPE '!')",
+			Vars: []interface{}{column("id"), contains(city)},
+		})
+	}
+}
+
+// HasPrimaryAddressIn matches users whose primary address is in city,
+// ignoring case.
+func HasPrimaryAddressIn(city string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM addresses WHERE addresses.user_id = ? AND addresses.is_primary = ? AND LOWER(addresses.city) = ?)",
+			Vars: []interface{}{column("id"), true, strings.ToLower(city)},
+		})
+	}
+}
+
+// LikedProduct matches users who like the product.
+func LikedProduct(productID string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM user_like_product WHERE user_like_product.user_id = ? AND user_like_product.product_id = ?)",
+			Vars: []interface{}{column("id"), productID},
+		})
+	}
+}
+
+var (
+	ErrUnknownScope = errors.New("unknown scope")
+	ErrScopeArgs    = errors.New("invalid scope arguments")
+)
+
+// ScopeBuilder makes a named scope from the arguments it was referenced
+// with, which arrive as strings because they usually come from a URL.
+type ScopeBuilder func(args ...string) (Scope, error)
+
+var namedScopes = struct {
+	sync.RWMutex
+	models map[reflect.Type]map[string]ScopeBuilder
+}{models: map[reflect.Type]map[string]ScopeBuilder{}}
+
+// RegisterScope makes build available as the scope name of model T.
+// Registering an existing name replaces it.
+func RegisterScope[T any](name string, build ScopeBuilder) {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+
+	namedScopes.Lock()
+	defer namedScopes.Unlock()
+	if namedScopes.models[model] == nil {
+		namedScopes.models[model] = map[string]ScopeBuilder{}
+	}
+	namedScopes.models[model][name] = build
+}
+
+// NamedScope builds the scope registered for T under name.
+func NamedScope[T any](name string, args ...string) (Scope, error) {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+
+	namedScopes.RLock()
+	build, ok := namedScopes.models[model][name]
+	namedScopes.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q for %s", ErrUnknownScope, name, model.Name())
+	}
+
+	scope, err := build(args...)
+	if err != nil {
+		return nil, fmt.Errorf("scope %s: %w", name, err)
+	}
+	return scope, nil
+}
+
+// ParseNamedScope builds a scope of T from a reference such as "sultan" or
+// "balance_between:0,50000".
+func ParseNamedScope[T any](ref string) (Scope, error) {
+	name, rawArgs, found := strings.Cut(ref, ":")
+	var args []string
+	if found {
+		args = strings.Split(rawArgs, ",")
+	}
+	return NamedScope[T](name, args...)
+}
+
+// ScopeNames lists the scopes registered for T.
+func ScopeNames[T any]() []string {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+
+	namedScopes.RLock()
+	defer namedScopes.RUnlock()
+	names := make([]string, 0, len(namedScopes.models[model]))
+	for name := range namedScopes.models[model] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fixed returns a builder for a scope that takes no arguments.
+func Fixed(scope Scope) ScopeBuilder {
+	return func(args ...string) (Scope, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%w: takes none, got %d", ErrScopeArgs, len(args))
+		}
+		return scope, nil
+	}
+}
+
+func stringArg(build func(string) Scope) ScopeBuilder {
+	return func(args ...string) (Scope, error) {
+		if len(args) != 1 || args[0] == "" {
+			return nil, fmt.Errorf("%w: takes one value, got %d", ErrScopeArgs, len(args))
+		}
+		return build(args[0]), nil
+	}
+}
+
+func durationArg(build func(time.Duration) Scope) ScopeBuilder {
+	return func(args ...string) (Scope, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: takes a duration, got %d values", ErrScopeArgs, len(args))
+		}
+		period, err := time.ParseDuration(args[0])
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("%w: %q is not a positive duration", ErrScopeArgs, args[0])
+		}
+		return build(period), nil
+	}
+}
+
+// moneyRangeArgs reads a currency followed by min and max in its minor
+// units, such as "IDR,0,50000".
+func moneyRangeArgs(build func(min, max money.Money) Scope) ScopeBuilder {
+	return func(args ...string) (Scope, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("%w: takes a currency, min and max, got %d values", ErrScopeArgs, len(args))
+		}
+		currency := args[0]
+		if !money.Known(currency) {
+			return nil, fmt.Errorf("%w: unknown currency %q", ErrScopeArgs, currency)
+		}
+		min, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: min %q is not an integer", ErrScopeArgs, args[1])
+		}
+		max, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: max %q is not an integer", ErrScopeArgs, args[2])
+		}
+		return build(money.New(min, currency), money.New(max, currency)), nil
+	}
+}
+
+func init() {
+	RegisterScope[Wallet]("broke", Fixed(BrokeWalletBalance))
+	RegisterScope[Wallet]("sultan", Fixed(SultanWalletBalance))
+	RegisterScope[Wallet]("balance_between", moneyRangeArgs(BalanceBetween))
+	RegisterScope[Wallet]("created_within", durationArg(CreatedWithin))
+
+	RegisterScope[User]("name_contains", stringArg(NameContains))
+	RegisterScope[User]("has_address_in", stringArg(HasAddressIn))
+	RegisterScope[User]("primary_address_in", stringArg(HasPrimaryAddressIn))
+	RegisterScope[User]("liked_product", stringArg(LikedProduct))
+	RegisterScope[User]("created_within", durationArg(CreatedWithin))
+
+	RegisterScope[Product]("created_within", durationArg(CreatedWithin))
+	RegisterScope[Address]("created_within", durationArg(CreatedWithin))
+	RegisterScope[Todo]("created_within", durationArg(CreatedWithin))
+	RegisterScope[Todo]("overdue", Fixed(func(db *gorm.DB) *gorm.DB {
+		return Overdue(time.Now())(db)
+	}))
+	RegisterScope[GuestBook]("created_within", durationArg(CreatedWithin))
+}
@@ -0,0 +1,145 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+func userIDs(users []User) []string {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+func TestParameterizedScopes(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	users := NewUserRepository(db)
+	wallets := NewWalletRepository(db)
+
+	found, err := wallets.FindAll(ctx, BalanceBetween(money.New(1000000, "IDR"), money.New(3000000, "IDR")), OrderBy("id"))
+	assert.Nil(t, err)
+	assert.Len(t, found, 3)
+
+	// the same minor units in another currency are another amount
+	dollars := &Wallet{ID: "50", UserId: "5"}
+	dollars.SetBalance(money.New(2000000, "USD"))
+	assert.Nil(t, NewLedgerService(db).OpenWallet(ctx, dollars))
+	found, err = wallets.FindAll(ctx, BalanceBetween(money.New(1000000, "USD"), money.New(3000000, "USD")))
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "50", found[0].ID)
+	_, err = wallets.FindAll(ctx, BalanceBetween(money.New(0, "IDR"), money.New(3000000, "USD")))
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch), err)
+	_, err = wallets.FindAll(ctx, Or(BrokeWalletBalance, BalanceBetween(money.New(0, "IDR"), money.New(3000000, "USD"))))
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch), err)
+
+	byName, err := users.FindAll(ctx, NameContains("rahman"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, userIDs(byName))
+
+	// wildcards in the search text are matched literally
+	byName, err = users.FindAll(ctx, NameContains("%"))
+	assert.Nil(t, err)
+	assert.Empty(t, byName)
+
+	inBandung, err := users.FindAll(ctx, HasAddressIn("bandung"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, userIDs(inBandung))
+
+	likers, err := users.FindAll(ctx, LikedProduct("P001"), OrderBy("id"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, userIDs(likers))
+
+	recent, err := users.Count(ctx, CreatedWithin(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9), recent)
+
+	old, err := users.Count(ctx, CreatedBetween(time.Now().AddDate(-2, 0, 0), time.Now().AddDate(-1, 0, 0)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), old)
+}
+
+func TestComposeScopes(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	users := NewUserRepository(db)
+
+	either, err := users.FindAll(ctx, Or(HasAddressIn("Jakarta"), HasAddressIn("Surabaya")), OrderBy("id"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "3"}, userIDs(either))
+
+	// the OR stays inside its parentheses
+	both, err := users.FindAll(ctx, LikedProduct("P001"), Or(HasAddressIn("Jakarta"), HasAddressIn("Surabaya")))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, userIDs(both))
+
+	all, err := users.FindAll(ctx, And(LikedProduct("P001"), HasAddressIn("Padalarang")))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, userIDs(all))
+
+	others, err := users.Count(ctx, Not(Or(LikedProduct("P001"), NameContains("User 9"))))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), others)
+
+	wallets, err := NewWalletRepository(db).Count(ctx, Or(BrokeWalletBalance, SultanWalletBalance))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), wallets)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(Or(BalanceBetween(money.New(0, "IDR"), money.New(10, "IDR")), And(BalanceBetween(money.New(20, "IDR"), money.New(30, "IDR")), CreatedWithin(time.Hour)))).Find(&[]Wallet{})
+	})
+	assert.Contains(t, sql, "WHERE (((`wallets`.`currency` = \"IDR\" AND `wallets`.`balance` >= 0 AND `wallets`.`balance` <= 10) OR ((`wallets`.`currency` = \"IDR\" AND `wallets`.`balance` >= 20 AND `wallets`.`balance` <= 30) AND `wallets`.`created_at` >= ")
+}
+
+func TestNamedScopes(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	wallets := NewWalletRepository(db)
+
+	sultan, err := wallets.Named("sultan")
+	assert.Nil(t, err)
+	count, err := wallets.Count(ctx, sultan)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+
+	between, err := ParseNamedScope[Wallet]("balance_between:IDR,0,1000000")
+	assert.Nil(t, err)
+	count, err = wallets.Count(ctx, between)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	inCity, err := ParseNamedScope[User]("has_address_in:Bandung")
+	assert.Nil(t, err)
+	found, err := NewUserRepository(db).FindAll(ctx, inCity)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, userIDs(found))
+
+	_, err = wallets.Named("rich")
+	assert.True(t, errors.Is(err, ErrUnknownScope))
+	_, err = NamedScope[User]("broke")
+	assert.True(t, errors.Is(err, ErrUnknownScope))
+	_, err = ParseNamedScope[Wallet]("balance_between:0")
+	assert.True(t, errors.Is(err, ErrScopeArgs))
+	_, err = ParseNamedScope[Wallet]("balance_between:0,1000000")
+	assert.True(t, errors.Is(err, ErrScopeArgs))
+	_, err = ParseNamedScope[Wallet]("balance_between:XXX,0,1000000")
+	assert.True(t, errors.Is(err, ErrScopeArgs))
+	_, err = ParseNamedScope[Wallet]("sultan:1")
+	assert.True(t, errors.Is(err, ErrScopeArgs))
+	_, err = ParseNamedScope[Todo]("created_within:soon")
+	assert.True(t, errors.Is(err, ErrScopeArgs))
+
+	assert.Equal(t, []string{"balance_between", "broke", "created_within", "sultan"}, ScopeNames[Wallet]())
+}
@@ -1,16 +1,114 @@
 package golang_gorm
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+type TodoStatus string
+
+const (
+	TodoOpen       TodoStatus = "open"
+	TodoInProgress TodoStatus = "in_progress"
+	TodoDone       TodoStatus = "done"
+)
+
+// todoTransitions lists the statuses each status may move to. A done todo
+// has to be reopened before work on it starts again.
+var todoTransitions = map[TodoStatus][]TodoStatus{
+	TodoOpen:       {TodoInProgress, TodoDone},
+	TodoInProgress: {TodoOpen, TodoDone},
+	TodoDone:       {TodoOpen},
+}
+
+func (s TodoStatus) Valid() bool {
+	_, ok := todoTransitions[s]
+	return ok
+}
+
+// CanBecome reports whether a todo with status s may move to next.
+func (s TodoStatus) CanBecome(next TodoStatus) bool {
+	for _, allowed := range todoTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type TodoPriority int
+
+const (
+	PriorityNone TodoPriority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+func (p TodoPriority) Valid() bool {
+	return p >= PriorityNone && p <= PriorityHigh
+}
+
+var (
+	ErrInvalidStatus     = errors.New("invalid todo status")
+	ErrInvalidTransition = errors.New("todo cannot move to that status")
+	ErrInvalidPriority   = errors.New("invalid todo priority")
+	ErrInvalidTag        = errors.New("tag names must be 1 to 50 characters")
+)
+
+const maxTagLength = 50
+
 type Todo struct {
 	gorm.Model
-	UserId      string `gorm:"column:user_id"`
-	Title       string `gorm:"column:title"`
-	Description string `gorm:"column:description"`
+	UserId      string       `gorm:"column:user_id"`
+	Title       string       `gorm:"column:title"`
+	Description string       `gorm:"column:description"`
+	Status      TodoStatus   `gorm:"column:status;size:20;not null;default:open"`
+	Priority    TodoPriority `gorm:"column:priority;not null;default:0"`
+	DueAt       *time.Time   `gorm:"column:due_at;index:idx_todos_due_at"`
+	// Position orders the todos of a user; see TodoRepository.Move.
+	Position int64 `gorm:"column:position;not null;default:0"`
+	Tags     []Tag `gorm:"many2many:todo_tags"`
 }
 
 func (t *Todo) TableName() string {
 	return "todos"
 }
+
+// BeforeCreate opens the todo and puts it at the end of the user's list
+// unless the caller chose a status or position.
+func (t *Todo) BeforeCreate(tx *gorm.DB) error {
+	if t.Status == "" {
+		t.Status = TodoOpen
+	}
+	if !t.Status.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, t.Status)
+	}
+	if !t.Priority.Valid() {
+		return fmt.Errorf("%w: %d", ErrInvalidPriority, t.Priority)
+	}
+
+	if t.Position == 0 {
+		position, err := nextPosition(tx.Session(&gorm.Session{NewDB: true}), t.UserId)
+		if err != nil {
+			return err
+		}
+		t.Position = position
+	}
+	return nil
+}
+
+// Tag labels todos. Tags belong to a user and are unique by name per user.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey;column:id;autoIncrement"`
+	UserId    string    `gorm:"column:user_id;size:191;not null;uniqueIndex:idx_tags_user_name"`
+	Name      string    `gorm:"column:name;size:50;not null;uniqueIndex:idx_tags_user_name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (t *Tag) TableName() string {
+	return "tags"
+}
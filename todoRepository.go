@@ -0,0 +1,301 @@
+package golang_gorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TodoRepository struct {
+	*Repository[Todo]
+}
+
+func NewTodoRepository(db *gorm.DB) *TodoRepository {
+	return &TodoRepository{NewRepository[Todo](db)}
+}
+
+func (r *TodoRepository) WithTx(tx *gorm.DB) *TodoRepository {
+	return &TodoRepository{r.Repository.WithTx(tx)}
+}
+
+func (r *TodoRepository) FindByUserID(ctx context.Context, userID string) ([]Todo, error) {
+	return r.FindAll(ctx, Where("user_id = ?", userID), OrderBy("id"))
+}
+
+func (r *TodoRepository) SearchTitle(ctx context.Context, userID, text string) ([]Todo, error) {
+	return r.FindAll(ctx, Where("user_id = ? AND title like ?", userID, "%"+text+"%"), OrderBy("id"))
+}
+
+// ListByUserID pages through the user's todos, newest first.
+func (r *TodoRepository) ListByUserID(ctx context.Context, userID string, params pagination.Params) (pagination.Page[Todo], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "created_at", Desc: true}}, Where("user_id = ?", userID))
+}
+
+// findOwned returns the user's todo, or gorm.ErrRecordNotFound when the
+// todo belongs to someone else.
+func (r *TodoRepository) findOwned(ctx context.Context, userID string, id uint, scopes ...Scope) (*Todo, error) {
+	return r.FindByID(ctx, id, append([]Scope{Where("user_id = ?", userID)}, scopes...)...)
+}
+
+// SetStatus moves the todo to status. The move is checked against the
+// status the row has when it is written, so two racing updates cannot
+// make an invalid transition between them.
+func (r *TodoRepository) SetStatus(ctx context.Context, userID string, id uint, status TodoStatus) (*Todo, error) {
+	if !status.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	var from []TodoStatus
+	for candidate := range todoTransitions {
+		if candidate.CanBecome(status) {
+			from = append(from, candidate)
+		}
+	}
+	result := r.DB(ctx).Model(&Todo{}).
+		Where("id = ? AND user_id = ? AND status IN ?", id, userID, from).
+		Update("status", status)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	todo, err := r.findOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 && todo.Status != status {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, todo.Status, status)
+	}
+	return todo, nil
+}
+
+// SetPriority changes the todo's priority.
+func (r *TodoRepository) SetPriority(ctx context.Context, userID string, id uint, priority TodoPriority) error {
+	if !priority.Valid() {
+		return fmt.Errorf("%w: %d", ErrInvalidPriority, priority)
+	}
+	return r.updateOwned(ctx, userID, id, "priority", priority)
+}
+
+// SetDueAt changes the todo's due date; nil removes it.
+func (r *TodoRepository) SetDueAt(ctx context.Context, userID string, id uint, dueAt *time.Time) error {
+	return r.updateOwned(ctx, userID, id, "due_at", dueAt)
+}
+
+func (r *TodoRepository) updateOwned(ctx context.Context, userID string, id uint, column string, value interface{}) error {
+	result := r.DB(ctx).Model(&Todo{}).Where("id = ? AND user_id = ?", id, userID).Update(column, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Overdue matches todos past their due date at the given moment that are
+// not done yet.
+func Overdue(at time.Time) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.And(
+			clause.Lt{Column: column("due_at"), Value: at},
+			clause.Neq{Column: column("status"), Value: TodoDone},
+		))
+	}
+}
+
+// FindOverdue returns the user's overdue todos, most overdue first.
+func (r *TodoRepository) FindOverdue(ctx context.Context, userID string, at time.Time) ([]Todo, error) {
+	return r.FindAll(ctx, Where("user_id = ?", userID), Overdue(at), OrderBy("due_at, id"))
+}
+
+// FindDueBetween returns the user's todos due from from up to, not
+// including, to, soonest first.
+func (r *TodoRepository) FindDueBetween(ctx context.Context, userID string, from, to time.Time) ([]Todo, error) {
+	return r.FindAll(ctx, Where("user_id = ? AND due_at >= ? AND due_at < ?", userID, from, to), OrderBy("due_at, id"))
+}
+
+// FindOrdered returns the user's todos in their manual order.
+func (r *TodoRepository) FindOrdered(ctx context.Context, userID string) ([]Todo, error) {
+	return r.FindAll(ctx, Where("user_id = ?", userID), OrderBy("position, id"))
+}
+
+// lockTodos locks the user's todos, trashed ones included, until the
+// transaction ends and returns them in list order with their positions.
+// Whatever numbers the user's list takes this lock first. The user's row is
+// locked before them, so a user with no todos yet is locked all the same.
+func lockTodos(tx *gorm.DB, userID string) ([]Todo, error) {
+	if err := lockUser(tx, userID); err != nil {
+		return nil, err
+	}
+	var todos []Todo
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "position", "deleted_at").
+		Where("user_id = ?", userID).
+		Order("position, id").
+		Find(&todos).Error
+	return todos, err
+}
+
+// nextPosition locks the user's todos and returns the position after the
+// last one. Trashed todos count too, so the position is free whatever
+// gets restored later.
+func nextPosition(tx *gorm.DB, userID string) (int64, error) {
+	todos, err := lockTodos(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var last int64
+	for _, todo := range todos {
+		if todo.Position > last {
+			last = todo.Position
+		}
+	}
+	return last + 1, nil
+}
+
+// Move puts the todo at index, counted from 0, of the user's list and
+// renumbers the list 1..n. The user's todos stay locked until the move
+// commits, so concurrent moves apply one after the other. An index past
+// the end moves the todo to the end.
+func (r *TodoRepository) Move(ctx context.Context, userID string, id uint, index int) error {
+	return NewUnitOfWork(r.db).Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+		locked, err := lockTodos(tx, userID)
+		if err != nil {
+			return err
+		}
+		// trashed todos get a new position when they are restored
+		var todos []Todo
+		for _, todo := range locked {
+			if !todo.DeletedAt.Valid {
+				todos = append(todos, todo)
+			}
+		}
+
+		from := -1
+		for i, todo := range todos {
+			if todo.ID == id {
+				from = i
+				break
+			}
+		}
+		if from < 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(todos) {
+			index = len(todos) - 1
+		}
+
+		moved := todos[from]
+		todos = append(todos[:from], todos[from+1:]...)
+		todos = append(todos[:index], append([]Todo{moved}, todos[index:]...)...)
+
+		for i, todo := range todos {
+			position := int64(i + 1)
+			if todo.Position == position {
+				continue
+			}
+			err := tx.Model(&Todo{}).Where("id = ?", todo.ID).UpdateColumn("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindWithTags returns the user's todo with its tags loaded.
+func (r *TodoRepository) FindWithTags(ctx context.Context, userID string, id uint) (*Todo, error) {
+	return r.findOwned(ctx, userID, id, Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name")
+	}))
+}
+
+// AddTags tags the user's todo, creating the user's tags that do not
+// exist yet. Tags the todo already has are left alone.
+func (r *TodoRepository) AddTags(ctx context.Context, userID string, id uint, names ...string) error {
+	names, err := tagNames(names)
+	if err != nil {
+		return err
+	}
+
+	return NewUnitOfWork(r.db).Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+		todo, err := uow.Todos().findOwned(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		tags := make([]Tag, 0, len(names))
+		for _, name := range names {
+			tag := Tag{UserId: userID, Name: name}
+			err := tx.Where(Tag{UserId: userID, Name: name}).FirstOrCreate(&tag).Error
+			if err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+		return tx.Model(todo).Omit("Tags.*").Association("Tags").Append(tags)
+	})
+}
+
+// RemoveTags takes the named tags off the user's todo. The tags themselves
+// stay.
+func (r *TodoRepository) RemoveTags(ctx context.Context, userID string, id uint, names ...string) error {
+	names, err := tagNames(names)
+	if err != nil {
+		return err
+	}
+
+	return NewUnitOfWork(r.db).Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+		todo, err := uow.Todos().findOwned(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		var tags []Tag
+		if err := tx.Where("user_id = ? AND name IN ?", userID, names).Find(&tags).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return tx.Model(todo).Association("Tags").Delete(tags)
+	})
+}
+
+// tagNames trims names and drops repeats.
+func tagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || utf8.RuneCountInString(name) > maxTagLength {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// FindByTag returns the user's todos tagged name, in their manual order.
+func (r *TodoRepository) FindByTag(ctx context.Context, userID, name string) ([]Todo, error) {
+	return r.FindAll(ctx,
+		Where("todos.user_id = ?", userID),
+		Where("EXISTS (SELECT 1 FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id WHERE todo_tags.todo_id = todos.id AND tags.name = ?)", name),
+		OrderBy("todos.position, todos.id"),
+	)
+}
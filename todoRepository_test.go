@@ -0,0 +1,268 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func createTodos(t *testing.T, db *gorm.DB, userID string, titles ...string) []Todo {
+	t.Helper()
+	todos := make([]Todo, len(titles))
+	for i, title := range titles {
+		todos[i] = Todo{UserId: userID, Title: title}
+		assert.Nil(t, db.Create(&todos[i]).Error)
+	}
+	return todos
+}
+
+func titles(todos []Todo) []string {
+	out := make([]string, len(todos))
+	for i, todo := range todos {
+		out[i] = todo.Title
+	}
+	return out
+}
+
+func TestTodoStatus(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	todo := createTodos(t, db, "3", "write report")[0]
+	assert.Equal(t, TodoOpen, todo.Status)
+
+	updated, err := todos.SetStatus(ctx, "3", todo.ID, TodoInProgress)
+	assert.Nil(t, err)
+	assert.Equal(t, TodoInProgress, updated.Status)
+
+	updated, err = todos.SetStatus(ctx, "3", todo.ID, TodoDone)
+	assert.Nil(t, err)
+	assert.Equal(t, TodoDone, updated.Status)
+
+	_, err = todos.SetStatus(ctx, "3", todo.ID, TodoInProgress)
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+
+	// setting the status a todo already has is not a transition
+	updated, err = todos.SetStatus(ctx, "3", todo.ID, TodoDone)
+	assert.Nil(t, err)
+	assert.Equal(t, TodoDone, updated.Status)
+
+	updated, err = todos.SetStatus(ctx, "3", todo.ID, TodoOpen)
+	assert.Nil(t, err)
+	assert.Equal(t, TodoOpen, updated.Status)
+
+	_, err = todos.SetStatus(ctx, "3", todo.ID, "archived")
+	assert.True(t, errors.Is(err, ErrInvalidStatus))
+	_, err = todos.SetStatus(ctx, "4", todo.ID, TodoDone)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	err = db.Create(&Todo{UserId: "3", Title: "bad", Status: "archived"}).Error
+	assert.True(t, errors.Is(err, ErrInvalidStatus))
+	err = db.Create(&Todo{UserId: "3", Title: "bad", Priority: 9}).Error
+	assert.True(t, errors.Is(err, ErrInvalidPriority))
+}
+
+func TestTodoDueDatesAndPriority(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := createTodos(t, db, "3", "late", "later", "finished", "upcoming", "someday")
+	for i, offset := range []time.Duration{-48 * time.Hour, -time.Hour, -72 * time.Hour, 24 * time.Hour} {
+		due := now.Add(offset)
+		assert.Nil(t, todos.SetDueAt(ctx, "3", created[i].ID, &due))
+	}
+	_, err := todos.SetStatus(ctx, "3", created[2].ID, TodoDone)
+	assert.Nil(t, err)
+
+	overdue, err := todos.FindOverdue(ctx, "3", now)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"late", "later"}, titles(overdue))
+
+	due, err := todos.FindDueBetween(ctx, "3", now, now.Add(7*24*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"upcoming"}, titles(due))
+
+	assert.Nil(t, todos.SetDueAt(ctx, "3", created[0].ID, nil))
+	overdue, err = todos.FindOverdue(ctx, "3", now)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"later"}, titles(overdue))
+
+	assert.Nil(t, todos.SetPriority(ctx, "3", created[4].ID, PriorityHigh))
+	high, err := todos.FindAll(ctx, Where("user_id = ? AND priority = ?", "3", PriorityHigh))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"someday"}, titles(high))
+
+	assert.True(t, errors.Is(todos.SetPriority(ctx, "3", created[4].ID, 4), ErrInvalidPriority))
+	assert.True(t, errors.Is(todos.SetPriority(ctx, "4", created[4].ID, PriorityLow), gorm.ErrRecordNotFound))
+}
+
+func TestTodoMove(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	created := createTodos(t, db, "3", "a", "b", "c", "d")
+	createTodos(t, db, "4", "other")
+
+	assert.Nil(t, todos.Move(ctx, "3", created[3].ID, 0))
+	ordered, err := todos.FindOrdered(ctx, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "a", "b", "c"}, titles(ordered))
+
+	assert.Nil(t, todos.Move(ctx, "3", created[0].ID, 99))
+	ordered, err = todos.FindOrdered(ctx, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "b", "c", "a"}, titles(ordered))
+	for i, todo := range ordered {
+		assert.Equal(t, int64(i+1), todo.Position)
+	}
+
+	// a new todo goes to the end
+	createTodos(t, db, "3", "e")
+	ordered, err = todos.FindOrdered(ctx, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "b", "c", "a", "e"}, titles(ordered))
+
+	err = todos.Move(ctx, "4", created[0].ID, 0)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	// a todo restored after the list was renumbered goes to the end
+	assert.Nil(t, db.Delete(&Todo{}, created[1].ID).Error)
+	assert.Nil(t, todos.Move(ctx, "3", created[3].ID, 99))
+	_, err = NewTrashService(db).Restore(ctx, "3", created[1].ID)
+	assert.Nil(t, err)
+	ordered, err = todos.FindOrdered(ctx, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "a", "e", "d", "b"}, titles(ordered))
+	positions := map[int64]bool{}
+	for _, todo := range ordered {
+		assert.False(t, positions[todo.Position], todo.Title)
+		positions[todo.Position] = true
+	}
+}
+
+func TestTodoMoveConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	var names []string
+	for i := 0; i < 10; i++ {
+		names = append(names, fmt.Sprintf("todo %d", i))
+	}
+	created := createTodos(t, db, "3", names...)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(int64(worker)))
+			for i := 0; i < 10; i++ {
+				todo := created[random.Intn(len(created))]
+				if err := todos.Move(ctx, "3", todo.ID, random.Intn(len(created))); err != nil {
+					t.Error(err)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	ordered, err := todos.FindOrdered(ctx, "3")
+	assert.Nil(t, err)
+	assert.Len(t, ordered, len(created))
+	for i, todo := range ordered {
+		assert.Equal(t, int64(i+1), todo.Position)
+	}
+}
+
+func TestTodoCreateConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newConcurrentTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	// user 4 has no todos yet, so there is no todo row to lock
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			todo := &Todo{UserId: "4", Title: fmt.Sprintf("todo %d", worker)}
+			if err := todos.Create(ctx, todo); err != nil {
+				t.Error(err)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	ordered, err := todos.FindOrdered(ctx, "4")
+	assert.Nil(t, err)
+	assert.Len(t, ordered, 8)
+	for i, todo := range ordered {
+		assert.Equal(t, int64(i+1), todo.Position)
+	}
+
+	err = todos.Create(ctx, &Todo{UserId: "99", Title: "nobody's"})
+	assert.True(t, errors.Is(err, ErrUserNotFound), err)
+}
+
+func TestTodoTags(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	todos := NewTodoRepository(db)
+
+	created := createTodos(t, db, "3", "report", "slides")
+	assert.Nil(t, todos.AddTags(ctx, "3", created[0].ID, "work", " urgent ", "work"))
+	assert.Nil(t, todos.AddTags(ctx, "3", created[0].ID, "work"))
+	assert.Nil(t, todos.AddTags(ctx, "3", created[1].ID, "work"))
+
+	todo, err := todos.FindWithTags(ctx, "3", created[0].ID)
+	assert.Nil(t, err)
+	assert.Len(t, todo.Tags, 2)
+	assert.Equal(t, "urgent", todo.Tags[0].Name)
+	assert.Equal(t, "work", todo.Tags[1].Name)
+
+	var tags int64
+	assert.Nil(t, db.Model(&Tag{}).Where("user_id = ?", "3").Count(&tags).Error)
+	assert.Equal(t, int64(2), tags)
+
+	tagged, err := todos.FindByTag(ctx, "3", "work")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"report", "slides"}, titles(tagged))
+
+	assert.Nil(t, todos.RemoveTags(ctx, "3", created[0].ID, "work", "missing"))
+	tagged, err = todos.FindByTag(ctx, "3", "work")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"slides"}, titles(tagged))
+
+	// tags are per user
+	tagged, err = todos.FindByTag(ctx, "4", "urgent")
+	assert.Nil(t, err)
+	assert.Empty(t, tagged)
+	err = todos.AddTags(ctx, "4", created[0].ID, "mine")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	err = todos.AddTags(ctx, "3", created[0].ID, "")
+	assert.True(t, errors.Is(err, ErrInvalidTag))
+
+	// trashed todos take their tag links with them when emptied
+	assert.Nil(t, todos.Delete(ctx, created[0].ID))
+	emptied, err := NewTrashService(db).Empty(ctx, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), emptied)
+}
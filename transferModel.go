@@ -0,0 +1,23 @@
+package golang_gorm
+
+import (
+	"time"
+
+	"golang-gorm/money"
+)
+
+// Transfer is money moved between two wallets holding the same currency.
+type Transfer struct {
+	ID             int64       `gorm:"primary_key;column:id;autoIncrement"`
+	IdempotencyKey string      `gorm:"column:idempotency_key;size:100;uniqueIndex"`
+	FromWalletId   string      `gorm:"column:from_wallet_id"`
+	ToWalletId     string      `gorm:"column:to_wallet_id"`
+	Amount         money.Money `gorm:"column:amount;type:varchar(40)"`
+	CreatedAt      time.Time   `gorm:"column:created_at;autoCreateTime"`
+	FromWallet     *Wallet     `gorm:"foreignKey:from_wallet_id;references:id"`
+	ToWallet       *Wallet     `gorm:"foreignKey:to_wallet_id;references:id"`
+}
+
+func (t *Transfer) TableName() string {
+	return "transfers"
+}
@@ -0,0 +1,146 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidAmount         = errors.New("transfer amount must be positive")
+	ErrSameWallet            = errors.New("cannot transfer to the same wallet")
+	ErrMissingIdempotencyKey = errors.New("idempotency key is required")
+	ErrWalletNotFound        = errors.New("wallet not found")
+	ErrInsufficientBalance   = errors.New("insufficient balance")
+	ErrIdempotencyConflict   = errors.New("idempotency key was used for a different transfer")
+)
+
+type TransferService struct {
+	uow *UnitOfWork
+}
+
+func NewTransferService(db *gorm.DB) *TransferService {
+	return &TransferService{uow: NewUnitOfWork(db)}
+}
+
+// Transfer moves amount, in minor units of the wallets' currency, from one
+// wallet to another and records it under idempotencyKey. Both wallets must
+// hold the same currency. Repeating a transfer with the same key and
+// arguments returns the recorded transfer without moving money again.
+//
+// Both wallets are locked in id order, so two opposite transfers running at
+// the same time cannot deadlock each other.
+func (s *TransferService) Transfer(ctx context.Context, fromWalletID, toWalletID string, amount int64, idempotencyKey string) (*Transfer, error) {
+	switch {
+	case amount <= 0:
+		return nil, ErrInvalidAmount
+	case fromWalletID == toWalletID:
+		return nil, ErrSameWallet
+	case idempotencyKey == "":
+		return nil, ErrMissingIdempotencyKey
+	}
+
+	transfer := &Transfer{
+		IdempotencyKey: idempotencyKey,
+		FromWalletId:   fromWalletID,
+		ToWalletId:     toWalletID,
+	}
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+
+		previous, err := findTransfer(tx, idempotencyKey)
+		if err != nil || previous != nil {
+			transfer = previous
+			return err
+		}
+
+		wallets, err := lockWallets(tx, fromWalletID, toWalletID)
+		if err != nil {
+			return err
+		}
+		from, to := wallets[fromWalletID], wallets[toWalletID]
+		if from.Currency != to.Currency {
+			return fmt.Errorf("%w: wallet %s holds %s, wallet %s holds %s", money.ErrCurrencyMismatch, fromWalletID, from.Currency, toWalletID, to.Currency)
+		}
+		moved := money.New(amount, from.Currency)
+		if err := checkBalance(from, moved); err != nil {
+			return err
+		}
+		transfer.Amount = moved
+
+		err = NewLedgerService(tx).Post(ctx, Journal{
+			ID:          "transfer:" + idempotencyKey,
+			Description: fmt.Sprintf("transfer %s from %s to %s", moved, fromWalletID, toWalletID),
+			Lines: []JournalLine{
+				{WalletId: fromWalletID, Debit: moved},
+				{WalletId: toWalletID, Credit: moved},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Create(transfer).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// a concurrent call with the same key won the race
+		transfer, err = findTransfer(s.uow.DB().WithContext(ctx), idempotencyKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.FromWalletId != fromWalletID || transfer.ToWalletId != toWalletID || transfer.Amount.Amount != amount {
+		return nil, fmt.Errorf("%w: %s", ErrIdempotencyConflict, idempotencyKey)
+	}
+	return transfer, nil
+}
+
+func findTransfer(tx *gorm.DB, idempotencyKey string) (*Transfer, error) {
+	var transfer Transfer
+	err := tx.Where("idempotency_key = ?", idempotencyKey).Take(&transfer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// checkBalance fails when the wallet holds less than amount.
+func checkBalance(wallet Wallet, amount money.Money) error {
+	cmp, err := wallet.Balance().Cmp(amount)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("%w: wallet %s has %s, needs %s", ErrInsufficientBalance, wallet.ID, wallet.Balance(), amount)
+	}
+	return nil
+}
+
+func lockWallets(tx *gorm.DB, ids ...string) (map[string]Wallet, error) {
+	var wallets []Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id in ?", ids).
+		Order("id").
+		Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Wallet, len(wallets))
+	for _, wallet := range wallets {
+		byID[wallet.ID] = wallet
+	}
+	for _, id := range ids {
+		if _, ok := byID[id]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, id)
+		}
+	}
+	return byID, nil
+}
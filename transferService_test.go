@@ -0,0 +1,198 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/migrate"
+	"golang-gorm/migrations"
+	"golang-gorm/money"
+)
+
+func TestTransfer(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewTransferService(db)
+	wallets := NewWalletRepository(db)
+
+	transfer, err := service.Transfer(ctx, "2", "1", 500000, "order-1")
+	assert.Nil(t, err)
+	assert.NotZero(t, transfer.ID)
+	assert.Equal(t, money.New(500000, "IDR"), transfer.Amount)
+
+	again, err := service.Transfer(ctx, "2", "1", 500000, "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, transfer.ID, again.ID)
+	assert.Equal(t, transfer.Amount, again.Amount)
+
+	from, err := wallets.FindByID(ctx, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4500000), from.BalanceAmount)
+	to, err := wallets.FindByID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1500000), to.BalanceAmount)
+
+	count, err := NewRepository[Transfer](db).Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTransferErrors(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	service := NewTransferService(db)
+
+	_, err := service.Transfer(ctx, "1", "2", 0, "a")
+	assert.True(t, errors.Is(err, ErrInvalidAmount))
+
+	_, err = service.Transfer(ctx, "1", "1", 100, "a")
+	assert.True(t, errors.Is(err, ErrSameWallet))
+
+	_, err = service.Transfer(ctx, "1", "2", 100, "")
+	assert.True(t, errors.Is(err, ErrMissingIdempotencyKey))
+
+	_, err = service.Transfer(ctx, "1", "99", 100, "a")
+	assert.True(t, errors.Is(err, ErrWalletNotFound))
+
+	_, err = service.Transfer(ctx, "1", "2", 1000001, "a")
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+
+	_, err = service.Transfer(ctx, "1", "2", 100, "a")
+	assert.Nil(t, err)
+	_, err = service.Transfer(ctx, "1", "2", 200, "a")
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+
+	wallet, err := NewWalletRepository(db).FindByID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(999900), wallet.BalanceAmount)
+
+	dollars := &Wallet{ID: "50", UserId: "5"}
+	dollars.SetBalance(money.New(10000, "USD"))
+	assert.Nil(t, NewLedgerService(db).OpenWallet(ctx, dollars))
+	_, err = service.Transfer(ctx, "50", "1", 100, "b")
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+}
+
+func TestTransferConcurrent(t *testing.T) {
+	t.Parallel()
+	db := newConcurrentTestDB(t)
+	ctx := context.Background()
+	service := NewTransferService(db)
+	wallets := NewWalletRepository(db)
+
+	before, err := wallets.Stats(ctx, "IDR")
+	assert.Nil(t, err)
+
+	ids := []string{"1", "2", "3", "4"}
+	var wg sync.WaitGroup
+	var transferred int64
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(int64(worker)))
+			for i := 0; i < 25; i++ {
+				from := ids[random.Intn(len(ids))]
+				to := ids[random.Intn(len(ids))]
+				if from == to {
+					continue
+				}
+				amount := int64(random.Intn(1500000) + 1)
+				_, err := service.Transfer(ctx, from, to, amount, fmt.Sprintf("worker-%d-%d", worker, i))
+				if err == nil {
+					atomic.AddInt64(&transferred, 1)
+				} else if !errors.Is(err, ErrInsufficientBalance) {
+					t.Error(err)
+				}
+			}
+		}(worker)
+	}
+
+	// opposite transfers lock their wallets in the same order, so the
+	// workers never wait on each other for good
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("transfers deadlocked")
+	}
+
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	assert.Greater(t, sqlDB.Stats().OpenConnections, 1, "the transfers ran one at a time")
+
+	// no update was lost: the total is unchanged and every transfer that
+	// went through is in the ledger
+	after, err := wallets.Stats(ctx, "IDR")
+	assert.Nil(t, err)
+	assert.Equal(t, before.Total, after.Total)
+	assert.False(t, after.Min.IsNegative())
+	assert.Greater(t, transferred, int64(0))
+
+	var journals int64
+	assert.Nil(t, db.Model(&LedgerEntry{}).Where("journal_id LIKE ?", "transfer:worker-%").Distinct("journal_id").Count(&journals).Error)
+	assert.Equal(t, transferred, journals)
+	reconciliation, err := NewLedgerService(db).Reconcile(ctx)
+	assert.Nil(t, err)
+	assert.True(t, reconciliation.Clean(), reconciliation)
+}
+
+func TestStoreTransferCurrenciesMigration(t *testing.T) {
+	t.Parallel()
+	db := newLegacyTestDB(t, 13)
+	ctx := context.Background()
+
+	now := time.Now()
+	assert.Nil(t, db.Table("users").Create(map[string]interface{}{"id": "legacy-1", "first_name": "Asep", "created_at": now, "updated_at": now}).Error)
+	for _, wallet := range []map[string]interface{}{
+		{"id": "rupiah", "user_id": "legacy-1", "balance": 0, "currency": "IDR", "created_at": now, "updated_at": now},
+		{"id": "dollars", "user_id": "legacy-1", "balance": 0, "currency": "USD", "created_at": now, "updated_at": now},
+		{"id": "1", "user_id": "legacy-1", "balance": 0, "currency": "EUR", "created_at": now, "updated_at": now},
+		{"id": "2", "user_id": "legacy-1", "balance": 0, "currency": "GBP", "created_at": now, "updated_at": now},
+	} {
+		assert.Nil(t, db.Table("wallets").Create(wallet).Error)
+	}
+	for _, transfer := range []map[string]interface{}{
+		{"idempotency_key": "a", "from_wallet_id": "rupiah", "to_wallet_id": "1", "amount": 500000, "created_at": now},
+		{"idempotency_key": "b", "from_wallet_id": "dollars", "to_wallet_id": "2", "amount": 1234, "created_at": now},
+		{"idempotency_key": "c", "from_wallet_id": "1", "to_wallet_id": "rupiah", "amount": 750, "created_at": now},
+	} {
+		assert.Nil(t, db.Table("transfers").Create(transfer).Error)
+	}
+
+	all := migrations.All()
+	migrator, err := migrate.New(db, all...)
+	assert.Nil(t, err)
+	_, err = migrator.Up(ctx)
+	assert.Nil(t, err)
+
+	var transfers []Transfer
+	assert.Nil(t, db.Order("idempotency_key").Find(&transfers).Error)
+	assert.Equal(t, 3, len(transfers))
+	assert.Equal(t, money.New(500000, "IDR"), transfers[0].Amount)
+	assert.Equal(t, money.New(1234, "USD"), transfers[1].Amount)
+	assert.Equal(t, money.New(750, "EUR"), transfers[2].Amount)
+
+	var stored string
+	assert.Nil(t, db.Table("transfers").Where("idempotency_key = ?", "b").Pluck("amount", &stored).Error)
+	assert.Equal(t, "USD 12.34", stored)
+
+	_, err = migrator.Down(ctx, len(all)-13)
+	assert.Nil(t, err)
+	var amounts []int64
+	assert.Nil(t, db.Table("transfers").Order("idempotency_key").Pluck("amount", &amounts).Error)
+	assert.Equal(t, []int64{500000, 1234, 750}, amounts)
+}
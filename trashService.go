@@ -0,0 +1,139 @@
+package golang_gorm
+
+import (
+	"context"
+	"time"
+
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize bounds how many todos one DELETE of a purge removes, so a
+// large purge never holds the table for long.
+const purgeBatchSize = 500
+
+// TrashService is the trash bin of soft-deleted todos. Everything but the
+// purge acts on the todos of one user only.
+type TrashService struct {
+	uow *UnitOfWork
+	now func() time.Time
+}
+
+func NewTrashService(db *gorm.DB) *TrashService {
+	return &TrashService{uow: NewUnitOfWork(db), now: time.Now}
+}
+
+// trashed matches the user's soft-deleted todos.
+func (s *TrashService) trashed(ctx context.Context, userID string) *gorm.DB {
+	return s.uow.DB().WithContext(ctx).Unscoped().Model(&Todo{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+}
+
+// List pages through the user's trash, most recently deleted first.
+func (s *TrashService) List(ctx context.Context, userID string, params pagination.Params) (pagination.Page[Todo], error) {
+	return pagination.Keyset[Todo](s.trashed(ctx, userID), params, pagination.Key{Column: "deleted_at", Desc: true})
+}
+
+// Restore takes the todo out of the trash and puts it at the end of the
+// user's list, as its old place may have been taken since. It returns
+// gorm.ErrRecordNotFound when the user has no such todo in the trash.
+func (s *TrashService) Restore(ctx context.Context, userID string, todoID uint) (*Todo, error) {
+	var todo *Todo
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+		position, err := nextPosition(tx, userID)
+		if err != nil {
+			return err
+		}
+		result := tx.Unscoped().Model(&Todo{}).
+			Where("user_id = ? AND deleted_at IS NOT NULL AND id = ?", userID, todoID).
+			Updates(map[string]interface{}{"deleted_at": nil, "position": position})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		todo, err = uow.Todos().FindByID(ctx, todoID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// Empty deletes everything in the user's trash for good and returns how
+// many todos it removed.
+func (s *TrashService) Empty(ctx context.Context, userID string) (int64, error) {
+	var ids []uint
+	if err := s.trashed(ctx, userID).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	return s.destroy(ctx, ids)
+}
+
+// destroy hard deletes the todos with ids along with their tag links.
+func (s *TrashService) destroy(ctx context.Context, ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	err := s.uow.Do(ctx, func(uow *UnitOfWork) error {
+		tx := uow.DB()
+		if err := tx.Exec("DELETE FROM todo_tags WHERE todo_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&Todo{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// Purge deletes the todos of every user that have been in the trash for
+// longer than retention, and returns how many it removed.
+func (s *TrashService) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := s.now().Add(-retention)
+
+	var purged int64
+	for {
+		var ids []uint
+		err := s.uow.DB().WithContext(ctx).Unscoped().Model(&Todo{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Order("id").Limit(purgeBatchSize).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		deleted, err := s.destroy(ctx, ids)
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+}
+
+// TrashPurger runs Purge in the background.
+type TrashPurger struct {
+	Trash     *TrashService
+	Retention time.Duration
+	Interval  time.Duration
+	// OnPurge, when set, is told how many todos each purge removed, or
+	// why it failed.
+	OnPurge func(purged int64, err error)
+}
+
+// Run purges right away and then every Interval until ctx is done, which
+// it returns as its error. It returns ErrInvalidInterval at once when
+// Interval is not positive.
+func (p *TrashPurger) Run(ctx context.Context) error {
+	return runEvery(ctx, p.Interval, func(ctx context.Context) {
+		purged, err := p.Trash.Purge(ctx, p.Retention)
+		if p.OnPurge != nil && ctx.Err() == nil {
+			p.OnPurge(purged, err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+func trashTodos(t *testing.T, db *gorm.DB, userID string, titles ...string) []Todo {
+	t.Helper()
+	todos := make([]Todo, len(titles))
+	for i, title := range titles {
+		todos[i] = Todo{UserId: userID, Title: title}
+	}
+	assert.Nil(t, db.Create(&todos).Error)
+	for _, todo := range todos {
+		assert.Nil(t, db.Delete(&Todo{}, todo.ID).Error)
+	}
+	return todos
+}
+
+func TestTrashListAndRestore(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	trash := NewTrashService(db)
+
+	mine := trashTodos(t, db, "1", "groceries", "laundry")
+	theirs := trashTodos(t, db, "2", "homework")
+
+	page, err := trash.List(ctx, "1", pagination.Params{Limit: 2, WithTotal: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), *page.Total)
+	assert.Len(t, page.Items, 2)
+	page, err = trash.List(ctx, "1", pagination.Params{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err)
+	// the fixture todo was deleted long before the others
+	assert.Equal(t, []string{"Old todo"}, []string{page.Items[0].Title})
+
+	_, err = trash.Restore(ctx, "1", theirs[0].ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	restored, err := trash.Restore(ctx, "1", mine[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "groceries", restored.Title)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	// a todo that is not in the trash cannot be restored again
+	_, err = trash.Restore(ctx, "1", mine[0].ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	active, err := NewTodoRepository(db).FindByUserID(ctx, "1")
+	assert.Nil(t, err)
+	assert.Len(t, active, 2)
+}
+
+func TestTrashEmpty(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	trash := NewTrashService(db)
+
+	trashTodos(t, db, "1", "groceries")
+	trashTodos(t, db, "2", "homework", "chores")
+
+	// emptying inside a unit of work is undone with the unit
+	failed := errors.New("changed my mind")
+	err := NewUnitOfWork(db).Do(ctx, func(uow *UnitOfWork) error {
+		emptied, err := NewTrashService(uow.DB()).Empty(ctx, "2")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), emptied)
+		return failed
+	})
+	assert.True(t, errors.Is(err, failed))
+
+	emptied, err := trash.Empty(ctx, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), emptied)
+
+	var left int64
+	assert.Nil(t, db.Unscoped().Model(&Todo{}).Where("deleted_at IS NOT NULL").Count(&left).Error)
+	assert.Equal(t, int64(2), left)
+
+	// active todos are never emptied
+	count, err := NewTodoRepository(db).Count(ctx, Where("user_id = ?", "1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTrashPurge(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	trash := NewTrashService(db)
+
+	trashTodos(t, db, "2", "homework")
+
+	purged, err := trash.Purge(ctx, 30*24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var old int64
+	assert.Nil(t, db.Unscoped().Model(&Todo{}).Where("title = ?", "Old todo").Count(&old).Error)
+	assert.Equal(t, int64(0), old)
+
+	trash.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	purged, err = trash.Purge(ctx, 30*24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestTrashPurger(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan int64, 10)
+	purger := &TrashPurger{
+		Trash:     NewTrashService(db),
+		Retention: 24 * time.Hour,
+		Interval:  10 * time.Millisecond,
+		OnPurge: func(purged int64, err error) {
+			assert.Nil(t, err)
+			runs <- purged
+		},
+	}
+
+	done := make(chan error)
+	go func() { done <- purger.Run(ctx) }()
+
+	assert.Equal(t, int64(1), <-runs)
+	assert.Equal(t, int64(0), <-runs)
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		purger := &TrashPurger{Trash: NewTrashService(db), Interval: interval}
+		assert.True(t, errors.Is(purger.Run(context.Background()), ErrInvalidInterval))
+	}
+}
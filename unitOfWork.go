@@ -0,0 +1,88 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork hands out repositories that share one transaction. Do opens
+// the transaction; calling Do again inside it opens a nested unit backed by
+// a savepoint, so a failing nested unit only undoes its own writes.
+type UnitOfWork struct {
+	db          *gorm.DB
+	inTx        bool
+	afterCommit []func()
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Do runs fn in a unit of work. The unit commits when fn returns nil and
+// rolls back when it returns an error or panics; the panic is re-raised
+// after the rollback.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	unit := &UnitOfWork{inTx: true}
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		unit.db = tx
+		return fn(unit)
+	})
+	if err != nil {
+		return err
+	}
+
+	if u.inTx {
+		// nothing is committed until the outermost unit is
+		u.afterCommit = append(u.afterCommit, unit.afterCommit...)
+		return nil
+	}
+	for _, callback := range unit.afterCommit {
+		callback()
+	}
+	return nil
+}
+
+// AfterCommit registers fn to run once the outermost unit has committed.
+// It is dropped when the unit rolls back, and runs right away outside a
+// unit.
+func (u *UnitOfWork) AfterCommit(fn func()) {
+	if !u.inTx {
+		fn()
+		return
+	}
+	u.afterCommit = append(u.afterCommit, fn)
+}
+
+// DB returns the connection of the unit, the transaction inside Do.
+func (u *UnitOfWork) DB() *gorm.DB {
+	return u.db
+}
+
+func (u *UnitOfWork) Users() *UserRepository {
+	return NewUserRepository(u.db)
+}
+
+func (u *UnitOfWork) Wallets() *WalletRepository {
+	return NewWalletRepository(u.db)
+}
+
+func (u *UnitOfWork) Addresses() *AddressRepository {
+	return NewAddressRepository(u.db)
+}
+
+func (u *UnitOfWork) Products() *ProductRepository {
+	return NewProductRepository(u.db)
+}
+
+func (u *UnitOfWork) Todos() *TodoRepository {
+	return NewTodoRepository(u.db)
+}
+
+func (u *UnitOfWork) GuestBooks() *GuestBookRepository {
+	return NewGuestBookRepository(u.db)
+}
+
+func (u *UnitOfWork) ExchangeRates() *ExchangeRateRepository {
+	return NewExchangeRateRepository(u.db)
+}
@@ -0,0 +1,98 @@
+package golang_gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	committed := false
+	err := NewUnitOfWork(db).Do(ctx, func(uow *UnitOfWork) error {
+		if err := uow.Users().Create(ctx, &User{ID: "50", Password: "rahasia", Name: Name{FirstName: "User 50"}}); err != nil {
+			return err
+		}
+		uow.AfterCommit(func() { committed = true })
+		assert.False(t, committed)
+		return uow.Wallets().Create(ctx, &Wallet{ID: "50", UserId: "50", BalanceAmount: 100000})
+	})
+	assert.Nil(t, err)
+	assert.True(t, committed)
+
+	user, err := NewUserRepository(db).FindWithRelations(ctx, "50")
+	assert.Nil(t, err)
+	assert.Len(t, user.Wallets, 1)
+	assert.Equal(t, int64(100000), user.Wallets[0].BalanceAmount)
+}
+
+func TestUnitOfWorkRollback(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+	uow := NewUnitOfWork(db)
+
+	committed := false
+	err := uow.Do(ctx, func(uow *UnitOfWork) error {
+		if err := uow.Users().Create(ctx, &User{ID: "50", Password: "rahasia", Name: Name{FirstName: "User 50"}}); err != nil {
+			return err
+		}
+		uow.AfterCommit(func() { committed = true })
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+	assert.False(t, committed)
+
+	assert.Panics(t, func() {
+		_ = uow.Do(ctx, func(uow *UnitOfWork) error {
+			if err := uow.Users().Create(ctx, &User{ID: "51", Password: "rahasia", Name: Name{FirstName: "User 51"}}); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	})
+
+	count, err := NewUserRepository(db).Count(ctx, Where("id in ?", []string{"50", "51"}))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestUnitOfWorkNested(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var callbacks []string
+	err := NewUnitOfWork(db).Do(ctx, func(uow *UnitOfWork) error {
+		if err := uow.Products().Create(ctx, &Product{ID: "P010", Name: "Mango", PriceAmount: 40000}); err != nil {
+			return err
+		}
+
+		err := uow.Do(ctx, func(nested *UnitOfWork) error {
+			if err := nested.Products().Create(ctx, &Product{ID: "P011", Name: "Grape", PriceAmount: 60000}); err != nil {
+				return err
+			}
+			nested.AfterCommit(func() { callbacks = append(callbacks, "grape") })
+			return errors.New("no grapes")
+		})
+		assert.NotNil(t, err)
+
+		return uow.Do(ctx, func(nested *UnitOfWork) error {
+			nested.AfterCommit(func() { callbacks = append(callbacks, "melon") })
+			return nested.Products().Create(ctx, &Product{ID: "P012", Name: "Melon", PriceAmount: 70000})
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"melon"}, callbacks)
+
+	products, err := NewProductRepository(db).FindAll(ctx, Where("id in ?", []string{"P010", "P011", "P012"}), OrderBy("id"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(products))
+	assert.Equal(t, "P010", products[0].ID)
+	assert.Equal(t, "P012", products[1].ID)
+}
@@ -1,39 +1,132 @@
 package golang_gorm
 
 import (
+	"context"
+	"reflect"
 	"time"
 
+	"golang-gorm/audit"
+	"golang-gorm/idgen"
+	"golang-gorm/password"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
+// IDGenerator makes the ids of users, wallets and products created without
+// one.
+var IDGenerator idgen.Generator = idgen.NewULID()
+
+// PasswordHasher hashes User passwords when they are saved. Swapping it for
+// another algorithm or cost leaves existing hashes valid; Authenticate
+// rehashes them with the new settings on the next successful login.
+var PasswordHasher password.Hasher = password.Bcrypt{}
+
 type User struct {
 	ID           string    `gorm:"primaryKey;column:id;<-:create"`
 	Password     string    `gorm:"column:password"`
 	Name         Name      `gorm:"embedded"`
-	CreatedAt    time.Time `gorm:"column:created_at;autoCreatedTime;<-:create"`
-	UpdatedAt    time.Time `gorm:"column:created_at;autoCreatedTime;autoUpdatedTime"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
 	Information  string    `gorm:"-"`
-	Wallet       Wallet    `gorm:"foreignKey:user_id;references:id"`
+	Wallets      []Wallet  `gorm:"foreignKey:user_id;references:id;constraint:fk_users_wallet,"` // named from when a user had one Wallet
 	Addresses    []Address `gorm:"foreignKey:user_id;references:id"`
 	LikeProducts []Product `gorm:"many2many:user_like_product;foreignKey:id;joinForeignKey:user_id;references:id;joinReferences:product_id"`
 }
 
+// UserLog is what a user did. Entries written by the audit plugin also say
+// which row of which table changed and how; UserId is the actor.
 type UserLog struct {
-	ID        int    `gorm:"primaryKey;column:id;autoIncrement"`
-	UserId    string `gorm:"column:user_id"`
-	Action    string `gorm:"column:action"`
-	CreatedAt int64  `gorm:"column:created_at;autoCreatedTime:milli"`
-	UpdatedAt int64  `gorm:"column:created_at;autoCreatedTime:milli;autoUpdatedTime:milli"`
+	ID        int           `gorm:"primaryKey;column:id;autoIncrement"`
+	UserId    string        `gorm:"column:user_id"`
+	Action    string        `gorm:"column:action"`
+	Table     string        `gorm:"column:table_name;size:100;index:idx_user_logs_record"`
+	RecordId  string        `gorm:"column:record_id;size:191;index:idx_user_logs_record"`
+	Changes   audit.Changes `gorm:"column:changes;type:text;serializer:json"`
+	CreatedAt int64         `gorm:"column:created_at;autoCreateTime:milli"`
+	UpdatedAt int64         `gorm:"column:updated_at;autoCreateTime:milli;autoUpdateTime:milli"`
 }
 
 func (u *User) BeforeCreate(db *gorm.DB) error {
 	if u.ID == "" {
-		u.ID = "user-" + time.Now().Format("2006102150405")
+		u.ID = IDGenerator.NewID()
 	}
 
 	return nil
 }
 
+// hashPasswords hashes the plaintext passwords a Create, Save, Update or
+// Updates writes to users, whether the statement got them as a *User, a
+// User, a slice of users or a column map. It is a callback rather than a
+// User hook because GORM cannot call pointer hooks on a User passed by
+// value, as in db.Where(...).Updates(User{...}).
+func hashPasswords(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.Table != (&User{}).TableName() {
+		return
+	}
+
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		for _, key := range []string{"password", "Password"} {
+			if plain, ok := dest[key].(string); ok {
+				hash, err := hashPassword(plain)
+				if err != nil {
+					db.AddError(err)
+					return
+				}
+				dest[key] = hash
+			}
+		}
+		return
+	}
+
+	field := stmt.Schema.LookUpField("password")
+	value := reflect.ValueOf(stmt.Dest)
+	if value.Kind() == reflect.Struct {
+		// a User passed by value cannot be changed; write a hashed copy
+		copied := reflect.New(value.Type())
+		copied.Elem().Set(value)
+		stmt.Dest = copied.Interface()
+		value = copied
+	}
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			db.AddError(hashPasswordField(stmt.Context, field, reflect.Indirect(value.Index(i))))
+		}
+	case reflect.Struct:
+		db.AddError(hashPasswordField(stmt.Context, field, value))
+	}
+}
+
+func hashPasswordField(ctx context.Context, field *schema.Field, user reflect.Value) error {
+	if user.Kind() != reflect.Struct {
+		return nil
+	}
+	plain, _ := field.ValueOf(ctx, user)
+	hash, err := hashPassword(plain.(string))
+	if err != nil || hash == plain {
+		return err
+	}
+	return field.Set(ctx, user, hash)
+}
+
+// registerPasswordHashing installs hashPasswords ahead of every insert and
+// update.
+func registerPasswordHashing(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("app:hash_passwords", hashPasswords); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("app:hash_passwords", hashPasswords)
+}
+
+func hashPassword(plain string) (string, error) {
+	if plain == "" || password.IsHash(plain) {
+		return plain, nil
+	}
+	return PasswordHasher.Hash(plain)
+}
+
 func (u *User) TableName() string {
 	return "users"
 }
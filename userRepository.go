@@ -0,0 +1,66 @@
+package golang_gorm
+
+import (
+	"context"
+
+	"golang-gorm/money"
+	"golang-gorm/pagination"
+	"gorm.io/gorm"
+)
+
+type UserRepository struct {
+	*Repository[User]
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{NewRepository[User](db)}
+}
+
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{r.Repository.WithTx(tx)}
+}
+
+// FindWithRelations loads the user together with its wallets and
+// addresses.
+func (r *UserRepository) FindWithRelations(ctx context.Context, id string) (*User, error) {
+	return r.FindByID(ctx, id, Preload("Wallets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("wallets.currency")
+	}), Preload("Addresses"))
+}
+
+// FindByFirstName matches first_name against a LIKE pattern such as "User%".
+func (r *UserRepository) FindByFirstName(ctx context.Context, pattern string) ([]User, error) {
+	return r.FindAll(ctx, Where("first_name like ?", pattern), OrderBy("id"))
+}
+
+// FindByWalletBalanceAbove returns the users whose wallet in min's currency
+// holds more than min, with that wallet loaded as their only one.
+func (r *UserRepository) FindByWalletBalanceAbove(ctx context.Context, min money.Money) ([]User, error) {
+	return r.FindAll(ctx,
+		Where("EXISTS (SELECT 1 FROM wallets WHERE wallets.user_id = users.id AND wallets.currency = ? AND wallets.balance > ?)", min.Currency, min.Amount),
+		Preload("Wallets", "currency = ?", min.Currency),
+		OrderBy("users.id"),
+	)
+}
+
+// FindLikersOf returns the users who like the product.
+func (r *UserRepository) FindLikersOf(ctx context.Context, productID string) ([]User, error) {
+	var users []User
+	err := r.DB(ctx).
+		Joins("join user_like_product on user_like_product.user_id = users.id").
+		Where("user_like_product.product_id = ?", productID).
+		Order("users.id").
+		Find(&users).Error
+	return users, err
+}
+
+// FindByPrimaryCity returns the users whose primary address is in city,
+// ignoring case, with that address loaded as their only one.
+func (r *UserRepository) FindByPrimaryCity(ctx context.Context, city string) ([]User, error) {
+	return r.FindAll(ctx, HasPrimaryAddressIn(city), Preload("Addresses", "is_primary = ?", true), OrderBy("users.id"))
+}
+
+// List pages through the users in id order.
+func (r *UserRepository) List(ctx context.Context, params pagination.Params) (pagination.Page[User], error) {
+	return r.Page(ctx, params, []pagination.Key{{Column: "id"}})
+}
@@ -1,14 +1,53 @@
 package golang_gorm
 
-import "time"
+import (
+	"fmt"
+	"time"
 
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+// DefaultCurrency is the currency of wallets and products created without
+// one, and the column default of their currency.
+const DefaultCurrency = "IDR"
+
+// Wallet holds a user's money in one currency. A user has at most one
+// wallet per currency.
 type Wallet struct {
-	ID        string    `gorm:"primary_key;column:id"`
-	UserId    string    `gorm:"column:user_id"`
-	Balance   int64     `gorm:"column:balance"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreatedTime"`
-	UpdatedAt time.Time `gorm:"column:created_at;autoCreatedTime;autoUpdatedTime"`
-	User      *User     `gorm:"foreignKey:user_id;references:id"`
+	ID     string `gorm:"primary_key;column:id"`
+	UserId string `gorm:"column:user_id;uniqueIndex:idx_wallets_user_currency"`
+	// BalanceAmount is the balance in minor units of Currency; see Balance.
+	BalanceAmount int64     `gorm:"column:balance;<-:create"`
+	Currency      string    `gorm:"column:currency;size:3;not null;default:IDR;uniqueIndex:idx_wallets_user_currency"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	User          *User     `gorm:"foreignKey:user_id;references:id"`
+}
+
+func (w *Wallet) BeforeCreate(db *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = IDGenerator.NewID()
+	}
+	if w.Currency == "" {
+		w.Currency = DefaultCurrency
+	}
+	return checkCurrency(w.Currency)
+}
+
+func (w *Wallet) Balance() money.Money {
+	return money.New(w.BalanceAmount, w.Currency)
+}
+
+func (w *Wallet) SetBalance(balance money.Money) {
+	w.BalanceAmount, w.Currency = balance.Amount, balance.Currency
+}
+
+func checkCurrency(currency string) error {
+	if !money.Known(currency) {
+		return fmt.Errorf("%w: %q", money.ErrUnknownCurrency, currency)
+	}
+	return nil
 }
 
 func (w *Wallet) TableName() string {
@@ -0,0 +1,96 @@
+package golang_gorm
+
+import (
+	"context"
+	"fmt"
+
+	"golang-gorm/money"
+	"gorm.io/gorm"
+)
+
+type WalletRepository struct {
+	*Repository[Wallet]
+}
+
+func NewWalletRepository(db *gorm.DB) *WalletRepository {
+	return &WalletRepository{NewRepository[Wallet](db)}
+}
+
+func (r *WalletRepository) WithTx(tx *gorm.DB) *WalletRepository {
+	return &WalletRepository{r.Repository.WithTx(tx)}
+}
+
+// FindByUserID returns the user's wallets ordered by currency.
+func (r *WalletRepository) FindByUserID(ctx context.Context, userID string) ([]Wallet, error) {
+	return r.FindAll(ctx, Where("user_id = ?", userID), OrderBy("currency"))
+}
+
+// FindByUserAndCurrency returns gorm.ErrRecordNotFound when the user has no
+// wallet in currency.
+func (r *WalletRepository) FindByUserAndCurrency(ctx context.Context, userID, currency string) (*Wallet, error) {
+	var wallet Wallet
+	err := r.DB(ctx).Where("user_id = ? AND currency = ?", userID, currency).Take(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *WalletRepository) FindBroke(ctx context.Context) ([]Wallet, error) {
+	return r.FindAll(ctx, BrokeWalletBalance, OrderBy("id"))
+}
+
+func (r *WalletRepository) FindSultan(ctx context.Context) ([]Wallet, error) {
+	return r.FindAll(ctx, SultanWalletBalance, OrderBy("id"))
+}
+
+// WalletStats summarises the balances of the wallets in one currency.
+type WalletStats struct {
+	Count   int64
+	Total   money.Money
+	Min     money.Money
+	Max     money.Money
+	Average money.Money
+}
+
+// Stats summarises the balances of the wallets holding currency. The
+// average is rounded half to even to the currency's minor unit.
+func (r *WalletRepository) Stats(ctx context.Context, currency string) (WalletStats, error) {
+	if !money.Known(currency) {
+		return WalletStats{}, fmt.Errorf("%w: %q", money.ErrUnknownCurrency, currency)
+	}
+
+	var row struct {
+		Count int64
+		Total int64
+		Min   int64
+		Max   int64
+	}
+	err := r.DB(ctx).Model(&Wallet{}).
+		Select("count(*) as count", "coalesce(sum(balance), 0) as total", "coalesce(min(balance), 0) as min", "coalesce(max(balance), 0) as max").
+		Where("currency = ?", currency).
+		Take(&row).Error
+	if err != nil {
+		return WalletStats{}, err
+	}
+
+	stats := WalletStats{
+		Count:   row.Count,
+		Total:   money.New(row.Total, currency),
+		Min:     money.New(row.Min, currency),
+		Max:     money.New(row.Max, currency),
+		Average: money.New(0, currency),
+	}
+	if row.Count > 0 {
+		stats.Average, err = stats.Total.Div(row.Count, money.HalfEven)
+	}
+	return stats, err
+}
+
+func BrokeWalletBalance(db *gorm.DB) *gorm.DB {
+	return db.Where("balance =?", 0)
+}
+
+func SultanWalletBalance(db *gorm.DB) *gorm.DB {
+	return db.Where("balance >?", 2000000)
+}